@@ -0,0 +1,314 @@
+package archive
+
+import (
+	"archive/zip"
+	"dev.hackerman.me/artheon/veverse-shared/executable"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	ErrUnsafePath   = errors.New("archive entry path is outside of the destination directory")
+	ErrSizeExceeded = errors.New("archive size limit exceeded")
+)
+
+// ExtractProgress is reported after each written block and each extracted file
+type ExtractProgress struct {
+	Files      int    `json:"files"`
+	TotalFiles int    `json:"totalFiles"`
+	Skipped    int    `json:"skipped"` // files already extracted by a previous run
+	Bytes      int64  `json:"bytes"`
+	TotalBytes int64  `json:"totalBytes"`
+	Path       string `json:"path"` // current file
+}
+
+type ExtractZipOptions struct {
+	MaxSize     int64                 // total uncompressed size limit (optional)
+	MaxFileSize int64                 // uncompressed size limit of a single file (optional)
+	MaxFiles    int                   // number of entries limit (optional)
+	Resume      bool                  // skip files that already exist with the same size and checksum
+	OnProgress  func(ExtractProgress) // progress callback (optional)
+}
+
+// ExtractZipArchive extracts the zip archive into the destination directory. Entries must stay inside the destination,
+// file modes are preserved and executables (detected by executable.IsExecutable) get the executable bits even if the
+// archive was created on a system without them. Files are written to temporary files and renamed when complete, so an
+// interrupted extraction can be resumed.
+func ExtractZipArchive(archive, dest string, options ExtractZipOptions) error {
+	reader, err := zip.OpenReader(archive)
+	if err != nil {
+		return fmt.Errorf("failed to open zip archive: %w", err)
+	}
+	defer reader.Close()
+
+	dest, err = filepath.Abs(dest)
+	if err != nil {
+		return err
+	}
+
+	if options.MaxFiles > 0 && len(reader.File) > options.MaxFiles {
+		return fmt.Errorf("%w: %d entries", ErrSizeExceeded, len(reader.File))
+	}
+
+	var progress ExtractProgress
+
+	// check declared sizes and paths before writing anything
+	for _, f := range reader.File {
+		if _, err = extractPath(dest, f.Name); err != nil {
+			return err
+		}
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if options.MaxFileSize > 0 && f.UncompressedSize64 > uint64(options.MaxFileSize) {
+			return fmt.Errorf("%w: %s", ErrSizeExceeded, f.Name)
+		}
+		progress.TotalFiles++
+		progress.TotalBytes += int64(f.UncompressedSize64)
+		if options.MaxSize > 0 && progress.TotalBytes > options.MaxSize {
+			return fmt.Errorf("%w: %d bytes", ErrSizeExceeded, progress.TotalBytes)
+		}
+	}
+
+	report := func() {
+		if options.OnProgress != nil {
+			options.OnProgress(progress)
+		}
+	}
+
+	if err = os.MkdirAll(dest, 0755); err != nil {
+		return err
+	}
+
+	for _, f := range reader.File {
+		target, _ := extractPath(dest, f.Name)
+		info := f.FileInfo()
+
+		// symlinks extracted earlier must not redirect later entries outside of the destination
+		parent := filepath.Dir(target)
+		if info.IsDir() {
+			parent = target
+		}
+		if err = checkSymlinks(dest, parent); err != nil {
+			return fmt.Errorf("%w: %s", err, f.Name)
+		}
+
+		if info.IsDir() {
+			if err = os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+
+		progress.Path = f.Name
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			if err = extractSymlink(dest, target, f); err != nil {
+				return err
+			}
+			progress.Files++
+			progress.Bytes += int64(f.UncompressedSize64)
+			report()
+			continue
+		}
+
+		if options.Resume && isExtracted(target, f) {
+			progress.Files++
+			progress.Skipped++
+			progress.Bytes += int64(f.UncompressedSize64)
+			report()
+			continue
+		}
+
+		err = extractFile(target, f, func(n int64) {
+			progress.Bytes += n
+			report()
+		})
+		if err != nil {
+			return fmt.Errorf("failed to extract %s: %w", f.Name, err)
+		}
+
+		progress.Files++
+		report()
+	}
+
+	return nil
+}
+
+// extractPath returns the destination path of the entry, the entry must not leave the destination directory
+func extractPath(dest, name string) (string, error) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if name == "" || strings.HasPrefix(name, "/") || filepath.IsAbs(filepath.FromSlash(name)) || filepath.VolumeName(filepath.FromSlash(name)) != "" {
+		return "", fmt.Errorf("%w: %s", ErrUnsafePath, name)
+	}
+
+	target := filepath.Join(dest, filepath.FromSlash(name))
+	if target != dest && !strings.HasPrefix(target, dest+string(os.PathSeparator)) {
+		return "", fmt.Errorf("%w: %s", ErrUnsafePath, name)
+	}
+
+	return target, nil
+}
+
+// checkSymlinks returns ErrUnsafePath if any existing component of the path below the destination is a symlink
+func checkSymlinks(dest, path string) error {
+	rel, err := filepath.Rel(dest, path)
+	if err != nil || rel == "." {
+		return err
+	}
+
+	current := dest
+	for _, component := range strings.Split(rel, string(os.PathSeparator)) {
+		current = filepath.Join(current, component)
+		info, err := os.Lstat(current)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return ErrUnsafePath
+		}
+	}
+
+	return nil
+}
+
+func extractSymlink(dest, target string, f *zip.File) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	link, err := io.ReadAll(io.LimitReader(rc, 4096))
+	_ = rc.Close()
+	if err != nil {
+		return err
+	}
+
+	// link target must be relative and resolve inside the destination
+	resolved := filepath.FromSlash(string(link))
+	if filepath.IsAbs(resolved) || strings.HasPrefix(string(link), "/") {
+		return fmt.Errorf("%w: %s -> %s", ErrUnsafePath, f.Name, link)
+	}
+	resolved = filepath.Join(filepath.Dir(target), resolved)
+	if resolved != dest && !strings.HasPrefix(resolved, dest+string(os.PathSeparator)) {
+		return fmt.Errorf("%w: %s -> %s", ErrUnsafePath, f.Name, link)
+	}
+
+	if err = os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	_ = os.Remove(target)
+	return os.Symlink(string(link), target)
+}
+
+// isExtracted reports whether the file has been extracted already
+func isExtracted(target string, f *zip.File) bool {
+	info, err := os.Lstat(target)
+	if err != nil || !info.Mode().IsRegular() || uint64(info.Size()) != f.UncompressedSize64 {
+		return false
+	}
+
+	file, err := os.Open(target)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	h := crc32.NewIEEE()
+	if _, err = io.Copy(h, file); err != nil {
+		return false
+	}
+
+	return h.Sum32() == f.CRC32
+}
+
+func extractFile(target string, f *zip.File, onWrite func(int64)) (err error) {
+	if err = os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	temp := target + ".extracting"
+	out, err := os.OpenFile(temp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if out != nil {
+			_ = out.Close()
+		}
+		if err != nil {
+			_ = os.Remove(temp)
+		}
+	}()
+
+	// the declared size can not be trusted, read at most one byte more to detect a mismatch
+	var (
+		head   executable.HeadWriter
+		limit  = int64(f.UncompressedSize64)
+		writer = io.MultiWriter(out, &head, writeCounter(onWrite))
+	)
+	n, err := io.Copy(writer, io.LimitReader(rc, limit+1))
+	if err != nil {
+		return err
+	}
+	if n > limit {
+		return fmt.Errorf("%w: %s is larger than declared", ErrSizeExceeded, f.Name)
+	}
+
+	isExecutable, err := head.IsExecutable()
+	if err != nil {
+		return err
+	}
+
+	if err = out.Close(); err != nil {
+		out = nil
+		return err
+	}
+	out = nil
+
+	if err = os.Chmod(temp, fileMode(f, isExecutable)); err != nil {
+		return err
+	}
+
+	return os.Rename(temp, target)
+}
+
+// fileMode returns permissions of the entry, archives created on Windows have no Unix modes, so executables are
+// detected by their content
+func fileMode(f *zip.File, isExecutable bool) os.FileMode {
+	mode := f.Mode().Perm()
+	if mode == 0 || f.CreatorVersion>>8 != creatorUnix && f.CreatorVersion>>8 != creatorMacOSX {
+		mode = 0644
+	}
+	if isExecutable {
+		// read permission implies execute permission for the same class
+		mode |= (mode & 0444) >> 2
+	}
+	return mode
+}
+
+const (
+	creatorUnix   = 3
+	creatorMacOSX = 19
+)
+
+type writeCounter func(int64)
+
+func (w writeCounter) Write(p []byte) (int, error) {
+	w(int64(len(p)))
+	return len(p), nil
+}
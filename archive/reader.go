@@ -0,0 +1,172 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"github.com/klauspost/compress/zstd"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// Entry is a file, directory or symlink of the archive
+type Entry struct {
+	Name    string      // slash separated path inside the archive
+	Mode    fs.FileMode // permissions and type bits
+	Size    int64       // uncompressed size of regular files
+	ModTime time.Time   // modification time, set by the reader
+	Link    string      // symlink target
+}
+
+// Reader iterates over archive entries, Read reads the content of the current entry
+type Reader interface {
+	// Next advances to the next entry, io.EOF is returned at the end of the archive
+	Next() (*Entry, error)
+	io.Reader
+	// Close releases the decompressor, the underlying reader is not closed
+	Close() error
+}
+
+// NewReader creates an archive reader. Tar archives are read as a stream, zip archives need random access, so r must
+// implement io.ReaderAt and have a Size method (e.g. *bytes.Reader) or be an *os.File.
+func NewReader(r io.Reader, format Format) (Reader, error) {
+	switch format {
+	case FormatZip:
+		ra, ok := r.(io.ReaderAt)
+		if !ok {
+			return nil, fmt.Errorf("zip archive reader requires io.ReaderAt")
+		}
+		var size int64
+		switch s := r.(type) {
+		case interface{ Size() int64 }:
+			size = s.Size()
+		case *os.File:
+			info, err := s.Stat()
+			if err != nil {
+				return nil, err
+			}
+			size = info.Size()
+		default:
+			return nil, fmt.Errorf("zip archive reader requires the archive size")
+		}
+		zr, err := zip.NewReader(ra, size)
+		if err != nil {
+			return nil, err
+		}
+		return &zipReader{reader: zr}, nil
+	case FormatTar:
+		return &tarReader{reader: tar.NewReader(r)}, nil
+	case FormatTarGz:
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return &tarReader{reader: tar.NewReader(gr), closer: gr.Close}, nil
+	case FormatTarZst:
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return &tarReader{reader: tar.NewReader(zr), closer: func() error { zr.Close(); return nil }}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported archive format %s", format)
+}
+
+type zipReader struct {
+	reader  *zip.Reader
+	index   int
+	current io.ReadCloser
+}
+
+func (r *zipReader) Next() (*Entry, error) {
+	if r.current != nil {
+		_ = r.current.Close()
+		r.current = nil
+	}
+
+	if r.index >= len(r.reader.File) {
+		return nil, io.EOF
+	}
+
+	f := r.reader.File[r.index]
+	r.index++
+
+	entry := &Entry{Name: f.Name, Mode: f.Mode(), Size: int64(f.UncompressedSize64), ModTime: f.Modified}
+
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	if entry.Mode&fs.ModeSymlink != 0 {
+		link, err := io.ReadAll(io.LimitReader(rc, 4096))
+		_ = rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		entry.Link = string(link)
+		entry.Size = 0
+		return entry, nil
+	}
+
+	r.current = rc
+	return entry, nil
+}
+
+func (r *zipReader) Read(p []byte) (int, error) {
+	if r.current == nil {
+		return 0, io.EOF
+	}
+	return r.current.Read(p)
+}
+
+func (r *zipReader) Close() error {
+	if r.current != nil {
+		return r.current.Close()
+	}
+	return nil
+}
+
+type tarReader struct {
+	reader *tar.Reader
+	closer func() error
+}
+
+func (r *tarReader) Next() (*Entry, error) {
+	for {
+		header, err := r.reader.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		entry := &Entry{Name: header.Name, Mode: fs.FileMode(header.Mode).Perm(), Size: header.Size, ModTime: header.ModTime}
+		switch header.Typeflag {
+		case tar.TypeReg:
+		case tar.TypeDir:
+			entry.Mode |= fs.ModeDir
+		case tar.TypeSymlink:
+			entry.Mode |= fs.ModeSymlink
+			entry.Link = header.Linkname
+		default:
+			// skip hard links, devices and other special entries
+			continue
+		}
+
+		return entry, nil
+	}
+}
+
+func (r *tarReader) Read(p []byte) (int, error) {
+	return r.reader.Read(p)
+}
+
+func (r *tarReader) Close() error {
+	if r.closer != nil {
+		return r.closer()
+	}
+	return nil
+}
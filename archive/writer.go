@@ -0,0 +1,296 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
+	"github.com/klauspost/compress/zstd"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Format of the archive
+type Format string
+
+const (
+	FormatZip    Format = "zip"
+	FormatTar    Format = "tar"
+	FormatTarGz  Format = "tar.gz"
+	FormatTarZst Format = "tar.zst"
+)
+
+// FormatFromPath detects the archive format by the file extension
+func FormatFromPath(path string) (Format, error) {
+	name := strings.ToLower(path)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return FormatZip, nil
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return FormatTarGz, nil
+	case strings.HasSuffix(name, ".tar.zst"), strings.HasSuffix(name, ".tzst"):
+		return FormatTarZst, nil
+	case strings.HasSuffix(name, ".tar"):
+		return FormatTar, nil
+	}
+	return "", fmt.Errorf("unknown archive format of %s", path)
+}
+
+// DefaultModTime is the modification time of all entries, fixed times make archives of identical inputs identical
+var DefaultModTime = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+type WriterOptions struct {
+	Format  Format    // archive format (default: zip)
+	Store   bool      // store zip entries without compression
+	Level   int       // compression level, 1 (fastest) to 9 for deflate and gzip, 1 to 22 for zstd (default: format default)
+	ModTime time.Time // modification time of the entries (default: DefaultModTime)
+}
+
+// Writer writes entries to the archive stream
+type Writer interface {
+	// Add writes the entry, the content is read from r for regular files and ignored for directories and symlinks
+	Add(entry Entry, r io.Reader) error
+	// Close finishes the archive, the underlying writer is not closed
+	Close() error
+}
+
+// NewWriter creates an archive writer streaming to w
+func NewWriter(w io.Writer, options WriterOptions) (Writer, error) {
+	if options.Format == "" {
+		options.Format = FormatZip
+	}
+
+	if options.ModTime.IsZero() {
+		options.ModTime = DefaultModTime
+	}
+
+	switch options.Format {
+	case FormatZip:
+		zw := zip.NewWriter(w)
+		if options.Level != 0 {
+			if options.Level < flate.BestSpeed || options.Level > flate.BestCompression {
+				return nil, fmt.Errorf("invalid deflate level %d", options.Level)
+			}
+			zw.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+				return flate.NewWriter(out, options.Level)
+			})
+		}
+		return &zipWriter{writer: zw, options: options}, nil
+	case FormatTar:
+		return &tarWriter{writer: tar.NewWriter(w), options: options}, nil
+	case FormatTarGz:
+		level := gzip.DefaultCompression
+		if options.Level != 0 {
+			level = options.Level
+		}
+		gw, err := gzip.NewWriterLevel(w, level)
+		if err != nil {
+			return nil, err
+		}
+		return &tarWriter{writer: tar.NewWriter(gw), compressor: gw, options: options}, nil
+	case FormatTarZst:
+		level := zstd.SpeedDefault
+		if options.Level != 0 {
+			if options.Level < 1 || options.Level > 22 {
+				return nil, fmt.Errorf("invalid zstd level %d", options.Level)
+			}
+			level = zstd.EncoderLevelFromZstd(options.Level)
+		}
+		zw, err := zstd.NewWriter(w, zstd.WithEncoderLevel(level))
+		if err != nil {
+			return nil, err
+		}
+		return &tarWriter{writer: tar.NewWriter(zw), compressor: zw, options: options}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported archive format %s", options.Format)
+}
+
+// WriteArchive streams the archive of the files to w, files are relative to the base path and are sorted to produce
+// identical archives for identical inputs
+func WriteArchive(w io.Writer, basePath string, files []string, options WriterOptions) (err error) {
+	writer, err := NewWriter(w, options)
+	if err != nil {
+		return err
+	}
+
+	sorted := make([]string, len(files))
+	for i, f := range files {
+		sorted[i] = filepath.ToSlash(f)
+	}
+	sort.Strings(sorted)
+
+	for _, name := range sorted {
+		if err = addFile(writer, basePath, name); err != nil {
+			return fmt.Errorf("failed to add file %s to archive: %w", name, err)
+		}
+	}
+
+	return writer.Close()
+}
+
+// CreateArchive writes the archive of the files to the output file, the format is detected by the output extension
+// unless set in options
+func CreateArchive(output, basePath string, files []string, options WriterOptions) (err error) {
+	if options.Format == "" {
+		options.Format, err = FormatFromPath(output)
+		if err != nil {
+			return err
+		}
+	}
+
+	file, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := file.Close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	return WriteArchive(file, basePath, files, options)
+}
+
+func addFile(writer Writer, basePath, name string) error {
+	path := filepath.Join(basePath, filepath.FromSlash(name))
+
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+
+	entry := Entry{Name: name, Mode: info.Mode(), Size: info.Size()}
+
+	switch {
+	case info.Mode()&fs.ModeSymlink != 0:
+		entry.Link, err = os.Readlink(path)
+		if err != nil {
+			return err
+		}
+		entry.Link = filepath.ToSlash(entry.Link)
+		entry.Size = 0
+		return writer.Add(entry, nil)
+	case info.IsDir():
+		entry.Size = 0
+		return writer.Add(entry, nil)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return writer.Add(entry, file)
+}
+
+type zipWriter struct {
+	writer  *zip.Writer
+	options WriterOptions
+}
+
+func (w *zipWriter) Add(entry Entry, r io.Reader) error {
+	header := &zip.FileHeader{
+		Name:     entry.Name,
+		Method:   zip.Deflate,
+		Modified: w.options.ModTime,
+	}
+
+	if w.options.Store {
+		header.Method = zip.Store
+	}
+
+	header.SetMode(entry.Mode)
+
+	switch {
+	case entry.Mode&fs.ModeSymlink != 0:
+		header.Method = zip.Store
+		writer, err := w.writer.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(writer, entry.Link)
+		return err
+	case entry.Mode.IsDir():
+		header.Name = strings.TrimSuffix(header.Name, "/") + "/"
+		header.Method = zip.Store
+		_, err := w.writer.CreateHeader(header)
+		return err
+	}
+
+	writer, err := w.writer.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(writer, r)
+	return err
+}
+
+func (w *zipWriter) Close() error {
+	return w.writer.Close()
+}
+
+type tarWriter struct {
+	writer     *tar.Writer
+	compressor io.WriteCloser
+	options    WriterOptions
+}
+
+func (w *tarWriter) Add(entry Entry, r io.Reader) error {
+	header := &tar.Header{
+		Name:    entry.Name,
+		Mode:    int64(entry.Mode.Perm()),
+		ModTime: w.options.ModTime,
+		Size:    entry.Size,
+	}
+
+	switch {
+	case entry.Mode&fs.ModeSymlink != 0:
+		header.Typeflag = tar.TypeSymlink
+		header.Linkname = entry.Link
+		header.Size = 0
+	case entry.Mode.IsDir():
+		header.Typeflag = tar.TypeDir
+		header.Name = strings.TrimSuffix(header.Name, "/") + "/"
+		header.Size = 0
+	default:
+		header.Typeflag = tar.TypeReg
+	}
+
+	if err := w.writer.WriteHeader(header); err != nil {
+		return err
+	}
+
+	if header.Typeflag != tar.TypeReg {
+		return nil
+	}
+
+	n, err := io.Copy(w.writer, r)
+	if err != nil {
+		return err
+	}
+
+	if n != entry.Size {
+		return fmt.Errorf("entry %s size changed while writing: %d != %d", entry.Name, n, entry.Size)
+	}
+
+	return nil
+}
+
+func (w *tarWriter) Close() error {
+	if err := w.writer.Close(); err != nil {
+		return err
+	}
+	if w.compressor != nil {
+		return w.compressor.Close()
+	}
+	return nil
+}
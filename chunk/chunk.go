@@ -0,0 +1,191 @@
+// Package chunk implements content-defined chunking of large files (e.g. Unreal .pak files) and chunk-level patches, so
+// clients download only the chunks of a modified file they do not have locally.
+package chunk
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+const (
+	DefaultMinSize = 256 * 1024      // 256 KiB
+	DefaultAvgSize = 1024 * 1024     // 1 MiB
+	DefaultMaxSize = 4 * 1024 * 1024 // 4 MiB
+)
+
+// Options control the chunk sizes, chunk boundaries are stable only for the same options
+type Options struct {
+	MinSize int `json:"minSize"`
+	AvgSize int `json:"avgSize"` // must be a power of two, at least 2
+	MaxSize int `json:"maxSize"`
+}
+
+// DefaultOptions are used for release files
+var DefaultOptions = Options{MinSize: DefaultMinSize, AvgSize: DefaultAvgSize, MaxSize: DefaultMaxSize}
+
+func (o Options) validate() error {
+	if o.MinSize <= 0 || o.AvgSize <= 0 || o.MaxSize <= 0 {
+		return fmt.Errorf("chunk sizes must be positive")
+	}
+	if o.MinSize > o.AvgSize || o.AvgSize > o.MaxSize {
+		return fmt.Errorf("chunk sizes must satisfy min <= avg <= max")
+	}
+	if o.AvgSize < 2 || o.AvgSize&(o.AvgSize-1) != 0 {
+		return fmt.Errorf("average chunk size must be a power of two of at least 2")
+	}
+	return nil
+}
+
+// Chunk is a part of the file addressed by its content hash
+type Chunk struct {
+	Offset int64  `json:"offset"`
+	Size   int64  `json:"size"`
+	Hash   string `json:"hash"` // hex encoded sha256 of the chunk content
+}
+
+// Index lists chunks of the file in order, it is uploaded next to the file to allow patching
+type Index struct {
+	Options
+	Size   int64   `json:"size"`
+	Hash   string  `json:"hash"` // hex encoded sha256 of the whole file
+	Chunks []Chunk `json:"chunks"`
+}
+
+// Path returns the storage path of the chunk content, chunks are shared between files and releases
+func Path(hash string) string {
+	if len(hash) < 2 {
+		return "chunks/" + hash
+	}
+	return "chunks/" + hash[:2] + "/" + hash
+}
+
+// Split reads the file and splits it into content-defined chunks, inserting or removing data changes only the chunks
+// around the modification
+func Split(r io.Reader, o Options) (*Index, error) {
+	if err := o.validate(); err != nil {
+		return nil, err
+	}
+
+	var (
+		index   = &Index{Options: o}
+		reader  = bufio.NewReaderSize(r, o.MaxSize)
+		whole   = sha256.New()
+		buf     = make([]byte, 0, 2*o.MaxSize)
+		eof     = false
+		avgBits = bits.TrailingZeros(uint(o.AvgSize))
+		// normalized chunking, harder to cut before the average size and easier after it
+		maskS = uint64(1)<<(avgBits+1) - 1
+		maskL = uint64(1)<<(avgBits-1) - 1
+	)
+
+	for {
+		// keep at least a max chunk in the buffer so cut points do not depend on read sizes
+		for !eof && len(buf) < o.MaxSize {
+			n, err := reader.Read(buf[len(buf):cap(buf)])
+			buf = buf[:len(buf)+n]
+			if err == io.EOF {
+				eof = true
+			} else if err != nil {
+				return nil, err
+			}
+		}
+
+		if len(buf) == 0 {
+			break
+		}
+
+		cut := cutPoint(buf, o, maskS, maskL)
+		data := buf[:cut]
+
+		sum := sha256.Sum256(data)
+		whole.Write(data)
+		index.Chunks = append(index.Chunks, Chunk{Offset: index.Size, Size: int64(cut), Hash: hex.EncodeToString(sum[:])})
+		index.Size += int64(cut)
+
+		buf = buf[:copy(buf, buf[cut:])]
+	}
+
+	index.Hash = hex.EncodeToString(whole.Sum(nil))
+
+	return index, nil
+}
+
+func cutPoint(data []byte, o Options, maskS, maskL uint64) int {
+	n := len(data)
+	if n <= o.MinSize {
+		return n
+	}
+	if n > o.MaxSize {
+		n = o.MaxSize
+	}
+
+	normal := o.AvgSize
+	if normal > n {
+		normal = n
+	}
+
+	var (
+		fp uint64
+		i  = o.MinSize
+	)
+	for ; i < normal; i++ {
+		fp = (fp << 1) + gear[data[i]]
+		if fp&maskS == 0 {
+			return i + 1
+		}
+	}
+	for ; i < n; i++ {
+		fp = (fp << 1) + gear[data[i]]
+		if fp&maskL == 0 {
+			return i + 1
+		}
+	}
+
+	return n
+}
+
+// ReadIndex decodes the JSON chunk index and checks that chunks cover the whole file
+func ReadIndex(r io.Reader) (*Index, error) {
+	var index Index
+	if err := json.NewDecoder(r).Decode(&index); err != nil {
+		return nil, fmt.Errorf("failed to decode chunk index: %w", err)
+	}
+
+	var offset int64
+	for i, c := range index.Chunks {
+		if c.Offset != offset || c.Size <= 0 {
+			return nil, fmt.Errorf("invalid chunk %d at offset %d", i, c.Offset)
+		}
+		offset += c.Size
+	}
+
+	if offset != index.Size {
+		return nil, fmt.Errorf("chunks cover %d of %d bytes", offset, index.Size)
+	}
+
+	return &index, nil
+}
+
+// Write encodes the chunk index as JSON
+func (i *Index) Write(w io.Writer) error {
+	return json.NewEncoder(w).Encode(i)
+}
+
+// gear is the table of random values used by the rolling hash, it must never change as it defines chunk boundaries
+var gear = func() (table [256]uint64) {
+	// splitmix64 with a fixed seed
+	var state uint64 = 0x5645566572736521
+	for i := range table {
+		state += 0x9e3779b97f4a7c15
+		z := state
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		table[i] = z ^ (z >> 31)
+	}
+	return
+}()
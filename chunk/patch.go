@@ -0,0 +1,150 @@
+package chunk
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+)
+
+// Operation produces a chunk of the target file either from the local file or by downloading it
+type Operation struct {
+	Chunk       Chunk `json:"chunk"`       // target chunk
+	Local       bool  `json:"local"`       // chunk content is available in the local file
+	LocalOffset int64 `json:"localOffset"` // offset of the chunk content in the local file
+}
+
+// Plan lists operations required to build the target file from the local one
+type Plan struct {
+	Target       *Index      `json:"target"`
+	Operations   []Operation `json:"operations"`
+	DownloadSize int64       `json:"downloadSize"` // total size of the chunks to download
+	ReuseSize    int64       `json:"reuseSize"`    // total size of the chunks copied from the local file
+}
+
+// Downloads returns unique chunks that must be downloaded
+func (p *Plan) Downloads() []Chunk {
+	var (
+		seen   = map[string]bool{}
+		chunks []Chunk
+	)
+	for _, op := range p.Operations {
+		if op.Local || seen[op.Chunk.Hash] {
+			continue
+		}
+		seen[op.Chunk.Hash] = true
+		chunks = append(chunks, op.Chunk)
+	}
+	return chunks
+}
+
+// Diff compares the local file index with the target one, local may be nil if there is no local file
+func Diff(local *Index, target *Index) *Plan {
+	var (
+		plan      = &Plan{Target: target}
+		available = map[string]Chunk{}
+		scheduled = map[string]bool{}
+	)
+
+	if local != nil {
+		for _, c := range local.Chunks {
+			if _, ok := available[c.Hash]; !ok {
+				available[c.Hash] = c
+			}
+		}
+	}
+
+	for _, c := range target.Chunks {
+		if l, ok := available[c.Hash]; ok && l.Size == c.Size {
+			plan.Operations = append(plan.Operations, Operation{Chunk: c, Local: true, LocalOffset: l.Offset})
+			plan.ReuseSize += c.Size
+			continue
+		}
+
+		plan.Operations = append(plan.Operations, Operation{Chunk: c})
+		// repeated chunks are downloaded once
+		if !scheduled[c.Hash] {
+			scheduled[c.Hash] = true
+			plan.DownloadSize += c.Size
+		}
+	}
+
+	return plan
+}
+
+// Fetcher returns the content of the chunk, e.g. by downloading it from Path(chunk.Hash)
+type Fetcher func(ctx context.Context, chunk Chunk) (io.ReadCloser, error)
+
+// Apply writes the target file to w, reusing the local file chunks and fetching the missing ones. Every chunk and the
+// resulting file are verified against the index hashes.
+func Apply(ctx context.Context, plan *Plan, local io.ReaderAt, fetch Fetcher, w io.Writer) error {
+	var (
+		whole  = sha256.New()
+		out    = io.MultiWriter(w, whole)
+		cached = map[string][]byte{}
+		counts = map[string]int{}
+	)
+
+	// keep content of chunks downloaded more than once to fetch them only once
+	for _, op := range plan.Operations {
+		if !op.Local {
+			counts[op.Chunk.Hash]++
+		}
+	}
+
+	for _, op := range plan.Operations {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var data []byte
+		if op.Local {
+			if local == nil {
+				return fmt.Errorf("no local file to read chunk %s", op.Chunk.Hash)
+			}
+			data = make([]byte, op.Chunk.Size)
+			if _, err := local.ReadAt(data, op.LocalOffset); err != nil {
+				return fmt.Errorf("failed to read local chunk %s: %w", op.Chunk.Hash, err)
+			}
+		} else if c, ok := cached[op.Chunk.Hash]; ok {
+			data = c
+		} else {
+			rc, err := fetch(ctx, op.Chunk)
+			if err != nil {
+				return fmt.Errorf("failed to fetch chunk %s: %w", op.Chunk.Hash, err)
+			}
+			var buf bytes.Buffer
+			_, err = io.Copy(&buf, io.LimitReader(rc, op.Chunk.Size+1))
+			_ = rc.Close()
+			if err != nil {
+				return fmt.Errorf("failed to fetch chunk %s: %w", op.Chunk.Hash, err)
+			}
+			data = buf.Bytes()
+		}
+
+		if int64(len(data)) != op.Chunk.Size {
+			return fmt.Errorf("chunk %s size mismatch: %d != %d", op.Chunk.Hash, len(data), op.Chunk.Size)
+		}
+
+		sum := sha256.Sum256(data)
+		if hex.EncodeToString(sum[:]) != op.Chunk.Hash {
+			return fmt.Errorf("chunk %s hash mismatch", op.Chunk.Hash)
+		}
+
+		if !op.Local && counts[op.Chunk.Hash] > 1 {
+			cached[op.Chunk.Hash] = data
+		}
+
+		if _, err := out.Write(data); err != nil {
+			return err
+		}
+	}
+
+	if plan.Target != nil && plan.Target.Hash != "" && hex.EncodeToString(whole.Sum(nil)) != plan.Target.Hash {
+		return fmt.Errorf("patched file hash mismatch")
+	}
+
+	return nil
+}
@@ -0,0 +1,177 @@
+// Package document renders uploaded documents (e.g. PDF) to per-page images for in-world screens.
+package document
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"dev.hackerman.me/artheon/veverse-shared/imaging"
+	"dev.hackerman.me/artheon/veverse-shared/model"
+	"dev.hackerman.me/artheon/veverse-shared/storage"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/gofrs/uuid"
+	"image"
+	"os"
+)
+
+var ErrTooManyPages = errors.New("document has too many pages")
+
+const (
+	DefaultPageSize    = 2048
+	DefaultMaxPages    = 500
+	DefaultJPEGQuality = 90
+)
+
+// Renderer renders pages of the document file in order, calling page for each rendered page
+type Renderer interface {
+	Render(ctx context.Context, filename string, options RenderOptions, page func(index int, img image.Image) error) error
+}
+
+var (
+	_ Renderer = RendererFunc(nil)
+	_ Renderer = Pdftoppm{}
+)
+
+// RendererFunc adapts a function to the Renderer interface
+type RendererFunc func(ctx context.Context, filename string, options RenderOptions, page func(index int, img image.Image) error) error
+
+func (f RendererFunc) Render(ctx context.Context, filename string, options RenderOptions, page func(index int, img image.Image) error) error {
+	return f(ctx, filename, options, page)
+}
+
+type RenderOptions struct {
+	Size     int // max width and height of the rendered pages in pixels (default: DefaultPageSize)
+	MaxPages int // max number of pages, rendering fails with ErrTooManyPages if the document has more (default: DefaultMaxPages)
+}
+
+type Options struct {
+	RenderOptions
+	JPEGQuality int // quality of opaque pages, pages with transparency are encoded as PNG (default: DefaultJPEGQuality)
+}
+
+// Page is an encoded page image
+type Page struct {
+	Index  int    `json:"index"` // zero-based page number
+	Mime   string `json:"mime"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Hash   string `json:"hash"` // hex encoded sha256 of the data
+	Data   []byte `json:"-"`
+}
+
+// LinkMetadata returns the metadata of the file link request for the page stored at the url
+func (p Page) LinkMetadata(url string) model.FileLinkRequestMetadata {
+	size := len(p.Data)
+	hash := p.Hash
+	mime := p.Mime
+	return model.FileLinkRequestMetadata{
+		Type:   model.FileTypeDocumentPage,
+		Url:    url,
+		Mime:   &mime,
+		Size:   &size,
+		Width:  p.Width,
+		Height: p.Height,
+		Index:  int64(p.Index),
+		Hash:   &hash,
+	}
+}
+
+// Process renders the pages of the document file, pages larger than the page size are scaled down
+func Process(ctx context.Context, renderer Renderer, filename string, options Options) ([]Page, error) {
+	if options.Size <= 0 {
+		options.Size = DefaultPageSize
+	}
+	if options.MaxPages <= 0 {
+		options.MaxPages = DefaultMaxPages
+	}
+	if options.JPEGQuality <= 0 {
+		options.JPEGQuality = DefaultJPEGQuality
+	}
+
+	var pages []Page
+	err := renderer.Render(ctx, filename, options.RenderOptions, func(index int, img image.Image) error {
+		if index >= options.MaxPages {
+			return fmt.Errorf("%w: more than %d", ErrTooManyPages, options.MaxPages)
+		}
+
+		w, h := imaging.Fit(img.Bounds().Dx(), img.Bounds().Dy(), options.Size, options.Size)
+		data, mime, err := imaging.Encode(imaging.Resize(img, w, h), options.JPEGQuality)
+		if err != nil {
+			return fmt.Errorf("failed to encode page %d: %w", index, err)
+		}
+
+		sum := sha256.Sum256(data)
+		pages = append(pages, Page{Index: index, Mime: mime, Width: w, Height: h, Hash: hex.EncodeToString(sum[:]), Data: data})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, p := range pages {
+		if p.Index != i {
+			return nil, fmt.Errorf("renderer returned page %d at position %d", p.Index, i)
+		}
+	}
+
+	return pages, nil
+}
+
+// Upload stores the document and its pages and links them to the entity, url returns the permanent url of the stored
+// content. Pages of a previously uploaded version of the document beyond the new page count are deleted.
+func Upload(ctx context.Context, requester *model.User, entityId uuid.UUID, store storage.Storage, filename string, mime string, pages []Page, url func(hash string) (string, error)) (document *model.File, files []model.File, err error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	object, err := store.Put(ctx, file, storage.PutOptions{Mime: mime})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to store document: %w", err)
+	}
+
+	u, err := url(object.Hash)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	size := int(object.Size)
+	document, err = model.LinkFile(ctx, requester, entityId, model.FileLinkRequestMetadata{
+		Type: model.FileTypeDocument,
+		Url:  u,
+		Mime: &mime,
+		Size: &size,
+		Hash: &object.Hash,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to link document: %w", err)
+	}
+
+	for _, p := range pages {
+		_, err = store.Put(ctx, bytes.NewReader(p.Data), storage.PutOptions{Hash: p.Hash, Size: int64(len(p.Data)), Mime: p.Mime})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to store page %d: %w", p.Index, err)
+		}
+
+		if u, err = url(p.Hash); err != nil {
+			return nil, nil, err
+		}
+
+		var f *model.File
+		f, err = model.LinkFile(ctx, requester, entityId, p.LinkMetadata(u))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to link page %d: %w", p.Index, err)
+		}
+
+		files = append(files, *f)
+	}
+
+	if err = model.TrimDocumentPages(ctx, requester, entityId, len(pages)); err != nil {
+		return nil, nil, err
+	}
+
+	return document, files, nil
+}
@@ -0,0 +1,120 @@
+package document
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Pdftoppm renders PDF documents with the pdftoppm tool of poppler-utils
+type Pdftoppm struct {
+	Path string // path of the executable (default: pdftoppm from PATH)
+}
+
+func (r Pdftoppm) Render(ctx context.Context, filename string, options RenderOptions, page func(index int, img image.Image) error) error {
+	if options.Size <= 0 {
+		options.Size = DefaultPageSize
+	}
+	if options.MaxPages <= 0 {
+		options.MaxPages = DefaultMaxPages
+	}
+
+	tool := r.Path
+	if tool == "" {
+		tool = "pdftoppm"
+	}
+
+	dir, err := os.MkdirTemp("", "pdftoppm-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	// render one page more than allowed to detect documents with too many pages
+	args := []string{
+		"-png",
+		"-scale-to", strconv.Itoa(options.Size),
+		"-l", strconv.Itoa(options.MaxPages + 1),
+		filename,
+		filepath.Join(dir, "page"),
+	}
+
+	output, err := exec.CommandContext(ctx, tool, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("pdftoppm failed: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	pages, err := pdftoppmPages(dir)
+	if err != nil {
+		return err
+	}
+
+	for i, p := range pages {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+
+		img, err := decodePNG(p)
+		if err != nil {
+			return fmt.Errorf("failed to decode page %d: %w", i, err)
+		}
+
+		if err = page(i, img); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// pdftoppmPages returns rendered page files in page order, pdftoppm names them page-1.png or page-001.png depending on
+// the page count
+func pdftoppmPages(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	type numbered struct {
+		number int
+		path   string
+	}
+
+	var pages []numbered
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name(), ".png")
+		number, err := strconv.Atoi(name[strings.LastIndex(name, "-")+1:])
+		if err != nil {
+			continue
+		}
+		pages = append(pages, numbered{number, filepath.Join(dir, e.Name())})
+	}
+
+	sort.Slice(pages, func(i, j int) bool {
+		return pages[i].number < pages[j].number
+	})
+
+	paths := make([]string, len(pages))
+	for i, p := range pages {
+		paths[i] = p.path
+	}
+
+	return paths, nil
+}
+
+func decodePNG(filename string) (image.Image, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return png.Decode(file)
+}
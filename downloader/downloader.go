@@ -0,0 +1,446 @@
+// Package downloader downloads release and package files in parallel with resume, checksum verification and
+// bandwidth limiting.
+package downloader
+
+import (
+	"context"
+	"crypto/sha256"
+	"dev.hackerman.me/artheon/veverse-shared/model"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TempSuffix is appended to the destination path while the file is downloaded
+const TempSuffix = ".part"
+
+var (
+	ErrSizeMismatch = errors.New("downloaded file size mismatch")
+	ErrHashMismatch = errors.New("downloaded file hash mismatch")
+)
+
+type Options struct {
+	Concurrency    int                     // number of files downloaded in parallel (default: 4)
+	Retries        int                     // number of attempts after an interrupted or corrupted download (default: 3, negative disables retries)
+	BytesPerSecond int64                   // total bandwidth limit of all downloads (default: unlimited)
+	Client         *http.Client            // http client (default: http.DefaultClient)
+	NewHash        func() hash.Hash        // hash algorithm of the file hashes (default: sha256)
+	Path           func(model.File) string // relative destination path of the file (default: original path or url file name)
+	Progress       chan<- Progress         // receives aggregate progress, updates are dropped if the channel is full, closed when Download returns
+}
+
+// Progress is the aggregate progress of all downloads
+type Progress struct {
+	Files      int   `json:"files"` // completed files
+	TotalFiles int   `json:"totalFiles"`
+	Bytes      int64 `json:"bytes"`      // downloaded or already present bytes
+	TotalBytes int64 `json:"totalBytes"` // total size of the files with known size
+	Failed     int   `json:"failed"`
+}
+
+// Result of a single file download
+type Result struct {
+	File    model.File `json:"file"`
+	Path    string     `json:"path"`    // destination path
+	Skipped bool       `json:"skipped"` // file was already downloaded and valid
+	Err     error      `json:"-"`
+}
+
+type downloader struct {
+	options  Options
+	dir      string
+	limiter  *limiter
+	mu       sync.Mutex
+	progress Progress
+}
+
+// Download downloads files into the directory. Each file is written to a temporary file which is renamed after the
+// size and hash are verified, interrupted downloads are resumed with HTTP range requests. Failed files do not stop
+// other downloads, the returned error describes the failures and results contain errors of individual files.
+func Download(ctx context.Context, files []model.File, dir string, options Options) (results []Result, err error) {
+	if options.Progress != nil {
+		defer close(options.Progress)
+	}
+
+	if options.Concurrency <= 0 {
+		options.Concurrency = 4
+	}
+
+	if options.Retries < 0 {
+		options.Retries = 0
+	} else if options.Retries == 0 {
+		options.Retries = 3
+	}
+
+	if options.Client == nil {
+		options.Client = http.DefaultClient
+	}
+
+	if options.NewHash == nil {
+		options.NewHash = sha256.New
+	}
+
+	if options.Path == nil {
+		options.Path = DefaultPath
+	}
+
+	d := &downloader{
+		options: options,
+		dir:     dir,
+		limiter: newLimiter(options.BytesPerSecond),
+	}
+
+	d.progress.TotalFiles = len(files)
+	for _, f := range files {
+		if f.Size != nil {
+			d.progress.TotalBytes += *f.Size
+		}
+	}
+
+	results = make([]Result, len(files))
+
+	var (
+		wg    sync.WaitGroup
+		queue = make(chan int)
+	)
+
+	for w := 0; w < options.Concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				results[i] = d.download(ctx, files[i])
+				d.update(0, results[i].Err == nil, results[i].Err != nil)
+			}
+		}()
+	}
+
+loop:
+	for i := range files {
+		select {
+		case queue <- i:
+		case <-ctx.Done():
+			break loop
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	if err = ctx.Err(); err != nil {
+		return results, err
+	}
+
+	var (
+		failed int
+		first  error
+	)
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+			if first == nil {
+				first = r.Err
+			}
+		}
+	}
+
+	if failed > 0 {
+		return results, fmt.Errorf("%d of %d files failed: %w", failed, len(files), first)
+	}
+
+	return results, nil
+}
+
+// DefaultPath returns the original path of the release file or the file name of the url
+func DefaultPath(f model.File) string {
+	if p := model.ReleaseManifestPath(f); p != "" {
+		return p
+	}
+	if u, err := url.Parse(f.Url); err == nil && path.Base(u.Path) != "/" && path.Base(u.Path) != "." {
+		return path.Base(u.Path)
+	}
+	return f.Id.String()
+}
+
+func (d *downloader) update(bytes int64, done bool, failed bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.progress.Bytes += bytes
+	if done {
+		d.progress.Files++
+	}
+	if failed {
+		d.progress.Failed++
+	}
+
+	if d.options.Progress != nil {
+		select {
+		case d.options.Progress <- d.progress:
+		default:
+		}
+	}
+}
+
+func (d *downloader) download(ctx context.Context, f model.File) (result Result) {
+	result.File = f
+
+	// cleaning the rooted path keeps the destination inside the directory
+	rel := strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(d.options.Path(f))), "/")
+	if rel == "" {
+		result.Err = fmt.Errorf("invalid destination path of %s", f.Url)
+		return result
+	}
+
+	result.Path = filepath.Join(d.dir, filepath.FromSlash(rel))
+
+	if d.isValid(ctx, f, result.Path) {
+		result.Skipped = true
+		if f.Size != nil {
+			d.update(*f.Size, false, false)
+		}
+		return result
+	}
+
+	if err := os.MkdirAll(filepath.Dir(result.Path), 0755); err != nil {
+		result.Err = err
+		return result
+	}
+
+	temp := result.Path + TempSuffix
+
+	var (
+		err       error
+		restarted = false
+	)
+	for attempt := 0; attempt <= d.options.Retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				result.Err = ctx.Err()
+				return result
+			case <-time.After(time.Duration(attempt) * time.Second):
+			}
+		}
+
+		err = d.fetch(ctx, f, temp, attempt == 0)
+		if errors.Is(err, ErrSizeMismatch) || errors.Is(err, ErrHashMismatch) {
+			// the partial file can not be trusted anymore, download it from scratch once
+			_ = os.Remove(temp)
+			if restarted {
+				break
+			}
+			restarted = true
+			continue
+		}
+		if err == nil || ctx.Err() != nil || isPermanent(err) {
+			break
+		}
+	}
+
+	if err != nil {
+		result.Err = fmt.Errorf("failed to download %s: %w", f.Url, err)
+		return result
+	}
+
+	if err = os.Rename(temp, result.Path); err != nil {
+		result.Err = fmt.Errorf("failed to move %s: %w", temp, err)
+		return result
+	}
+
+	return result
+}
+
+// isValid reports whether the file is already downloaded, files without a hash are always downloaded again
+func (d *downloader) isValid(ctx context.Context, f model.File, p string) bool {
+	if f.Hash == nil || *f.Hash == "" {
+		return false
+	}
+
+	info, err := os.Stat(p)
+	if err != nil || info.IsDir() || (f.Size != nil && *f.Size != info.Size()) {
+		return false
+	}
+
+	file, err := os.Open(p)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	h := d.options.NewHash()
+	if _, err = io.Copy(h, &limitedReader{ctx: ctx, reader: file}); err != nil {
+		return false
+	}
+
+	return strings.EqualFold(hex.EncodeToString(h.Sum(nil)), *f.Hash)
+}
+
+// fetch downloads the file to the temp path resuming from its current size, bytes downloaded by previous runs are
+// reported on the first attempt
+func (d *downloader) fetch(ctx context.Context, f model.File, temp string, first bool) (err error) {
+	file, err := os.OpenFile(temp, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := file.Close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	h := d.options.NewHash()
+
+	// hash the already downloaded part
+	offset, err := io.Copy(h, &limitedReader{ctx: ctx, reader: file})
+	if err != nil {
+		return err
+	}
+
+	restart := func() error {
+		offset = 0
+		h.Reset()
+		if err := file.Truncate(0); err != nil {
+			return err
+		}
+		_, err := file.Seek(0, io.SeekStart)
+		return err
+	}
+
+	if f.Size != nil && offset > *f.Size {
+		if err = restart(); err != nil {
+			return err
+		}
+	}
+
+	if first && offset > 0 {
+		d.update(offset, false, false)
+	}
+
+	if f.Size == nil || offset < *f.Size {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.Url, nil)
+		if err != nil {
+			return permanent(err)
+		}
+
+		if offset > 0 {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		}
+
+		resp, err := d.options.Client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		switch {
+		case resp.StatusCode == http.StatusPartialContent && offset > 0:
+			// resumed, a range not starting at the offset would splice wrong bytes into the file, start over
+			if start, ok := contentRangeStart(resp.Header.Get("Content-Range")); !ok || start != offset {
+				d.update(-offset, false, false)
+				if err = restart(); err != nil {
+					return err
+				}
+				return fmt.Errorf("unexpected content range %q for offset %d", resp.Header.Get("Content-Range"), offset)
+			}
+		case resp.StatusCode == http.StatusOK:
+			// server ignored the range, start over
+			if offset > 0 {
+				d.update(-offset, false, false)
+				if err = restart(); err != nil {
+					return err
+				}
+			}
+		case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+			// the partial file may be complete already, verified below
+		default:
+			err = fmt.Errorf("unexpected status %s", resp.Status)
+			if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+				return permanent(err)
+			}
+			return err
+		}
+
+		if resp.StatusCode != http.StatusRequestedRangeNotSatisfiable {
+			reader := &limitedReader{ctx: ctx, reader: resp.Body, limiter: d.limiter}
+			writer := io.MultiWriter(file, h, progressWriter(d.update))
+			n, err := io.Copy(writer, reader)
+			offset += n
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if f.Size != nil && offset != *f.Size {
+		d.update(-offset, false, false)
+		return fmt.Errorf("%w: %d != %d", ErrSizeMismatch, offset, *f.Size)
+	}
+
+	if f.Hash != nil && *f.Hash != "" && !strings.EqualFold(hex.EncodeToString(h.Sum(nil)), *f.Hash) {
+		d.update(-offset, false, false)
+		return ErrHashMismatch
+	}
+
+	return file.Sync()
+}
+
+// contentRangeStart returns the first byte position of the "bytes first-last/size" content range
+func contentRangeStart(header string) (int64, bool) {
+	header = strings.TrimSpace(header)
+	if !strings.HasPrefix(header, "bytes ") {
+		return 0, false
+	}
+
+	first, _, ok := strings.Cut(strings.TrimPrefix(header, "bytes "), "-")
+	if !ok {
+		return 0, false
+	}
+
+	start, err := strconv.ParseInt(strings.TrimSpace(first), 10, 64)
+	if err != nil || start < 0 {
+		return 0, false
+	}
+
+	return start, true
+}
+
+// progressWriter reports written bytes
+type progressWriter func(bytes int64, done bool, failed bool)
+
+func (w progressWriter) Write(p []byte) (int, error) {
+	w(int64(len(p)), false, false)
+	return len(p), nil
+}
+
+// permanentError is not retried
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e permanentError) Unwrap() error {
+	return e.err
+}
+
+func permanent(err error) error {
+	return permanentError{err}
+}
+
+func isPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p)
+}
@@ -1,4 +1,4 @@
-// Package executable provides a function to check if a file is an executable file.
+// Package executable provides functions to check if a file is an executable file and inspect its target platform.
 package executable
 
 import (
@@ -10,6 +10,30 @@ import (
 	"io"
 )
 
+// HeadSize is the number of leading bytes IsExecutable checks
+const HeadSize = 4
+
+// HeadWriter keeps the first HeadSize bytes written to it and discards the rest, so the content can be checked with
+// IsExecutable while it is copied somewhere else
+type HeadWriter struct {
+	buf bytes.Buffer
+}
+
+func (w *HeadWriter) Write(p []byte) (int, error) {
+	if left := HeadSize - w.buf.Len(); left > 0 {
+		if left > len(p) {
+			left = len(p)
+		}
+		w.buf.Write(p[:left])
+	}
+	return len(p), nil
+}
+
+// IsExecutable checks the written head with IsExecutable
+func (w *HeadWriter) IsExecutable() (bool, error) {
+	return IsExecutable(bytes.NewReader(w.buf.Bytes()))
+}
+
 func readChunk(reader io.Reader, chunkSize int64) ([]byte, error) {
 	buf := new(bytes.Buffer)
 	_, err := io.CopyN(buf, reader, chunkSize)
@@ -19,9 +43,9 @@ func readChunk(reader io.Reader, chunkSize int64) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-// IsExecutable check if source is an executable file
+// IsExecutable check if source is an executable file, use Inspect to get the format, architecture and platform
 func IsExecutable(reader io.Reader) (bool, error) {
-	buf, err := readChunk(reader, 4)
+	buf, err := readChunk(reader, HeadSize)
 	if err != nil {
 		return false, fmt.Errorf("failed to read file: %v", err)
 	}
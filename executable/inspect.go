@@ -0,0 +1,259 @@
+package executable
+
+import (
+	"bufio"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"dev.hackerman.me/artheon/veverse-shared/platform"
+	"dev.hackerman.me/artheon/veverse-shared/unreal"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+var ErrUnknownFormat = errors.New("unknown executable format")
+
+type Format string
+
+const (
+	FormatELF    Format = "elf"
+	FormatPE     Format = "pe"
+	FormatMachO  Format = "macho"
+	FormatFat    Format = "fat" // universal Mach-O binary with multiple architectures
+	FormatScript Format = "script"
+)
+
+type Arch string
+
+const (
+	ArchAmd64 Arch = "amd64"
+	ArchArm64 Arch = "arm64"
+	ArchX86   Arch = "x86"
+)
+
+type Kind string
+
+const (
+	KindExecutable    Kind = "exe"
+	KindSharedLibrary Kind = "shared-lib"
+	KindObject        Kind = "object"
+)
+
+// Info describes an executable file
+type Info struct {
+	Format      Format `json:"format"`
+	Kind        Kind   `json:"kind"`
+	Arch        Arch   `json:"arch,omitempty"`        // architecture, empty if unknown or if the file is a script
+	Archs       []Arch `json:"archs,omitempty"`       // all architectures of a fat binary, the architecture of other binaries
+	OS          string `json:"os,omitempty"`          // target GOOS-style os name (see platform), empty for scripts
+	Platform    string `json:"platform,omitempty"`    // unreal platform name (see unreal), empty if the binary can not run on any of them
+	Interpreter string `json:"interpreter,omitempty"` // shebang line of a script
+}
+
+// RunsOn reports whether the file can be used on the unreal platform, scripts are considered to run on any platform
+// except Windows
+func (i *Info) RunsOn(unrealPlatform string) bool {
+	if i.Format == FormatScript {
+		return unrealPlatform != unreal.Windows
+	}
+	return i.Platform != "" && i.Platform == unrealPlatform
+}
+
+// InspectFile reads the format, architecture and target platform of the file
+func InspectFile(filename string) (*Info, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return Inspect(file)
+}
+
+// Inspect reads the format, architecture and target platform of the executable, ErrUnknownFormat is returned for files
+// that are not ELF, PE, Mach-O binaries or scripts
+func Inspect(r io.ReaderAt) (*Info, error) {
+	var magic [4]byte
+	n, err := r.ReadAt(magic[:], 0)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to read file: %v", err)
+	}
+	if n < 4 {
+		return nil, ErrUnknownFormat
+	}
+
+	le := binary.LittleEndian.Uint32(magic[:])
+	be := binary.BigEndian.Uint32(magic[:])
+
+	var info *Info
+	switch {
+	case string(magic[:]) == elf.ELFMAG:
+		info, err = inspectELF(r)
+	case string(magic[:2]) == "MZ":
+		info, err = inspectPE(r)
+	case string(magic[:2]) == "#!":
+		info, err = inspectScript(r)
+	case be == macho.MagicFat:
+		info, err = inspectFat(r)
+	case le == macho.Magic32 || le == macho.Magic64 || be == macho.Magic32 || be == macho.Magic64:
+		info, err = inspectMachO(r)
+	default:
+		return nil, ErrUnknownFormat
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnknownFormat, err)
+	}
+
+	if info.Arch != "" && len(info.Archs) == 0 {
+		info.Archs = []Arch{info.Arch}
+	}
+
+	return info, nil
+}
+
+func inspectELF(r io.ReaderAt) (*Info, error) {
+	f, err := elf.NewFile(r)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info := &Info{Format: FormatELF}
+
+	switch f.Type {
+	case elf.ET_EXEC:
+		info.Kind = KindExecutable
+	case elf.ET_DYN:
+		// position independent executables are shared objects with an interpreter
+		info.Kind = KindSharedLibrary
+		for _, p := range f.Progs {
+			if p.Type == elf.PT_INTERP {
+				info.Kind = KindExecutable
+				break
+			}
+		}
+	default:
+		info.Kind = KindObject
+	}
+
+	switch f.Machine {
+	case elf.EM_X86_64:
+		info.Arch = ArchAmd64
+	case elf.EM_AARCH64:
+		info.Arch = ArchArm64
+	case elf.EM_386:
+		info.Arch = ArchX86
+	}
+
+	if f.OSABI == elf.ELFOSABI_NONE || f.OSABI == elf.ELFOSABI_LINUX {
+		info.OS = platform.Linux
+		if info.Arch == ArchAmd64 || info.Arch == ArchArm64 {
+			info.Platform = unreal.Linux
+		}
+	}
+
+	return info, nil
+}
+
+func inspectPE(r io.ReaderAt) (*Info, error) {
+	f, err := pe.NewFile(r)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info := &Info{Format: FormatPE, OS: platform.Windows}
+
+	switch {
+	case f.Characteristics&pe.IMAGE_FILE_DLL != 0:
+		info.Kind = KindSharedLibrary
+	case f.Characteristics&pe.IMAGE_FILE_EXECUTABLE_IMAGE != 0:
+		info.Kind = KindExecutable
+	default:
+		info.Kind = KindObject
+	}
+
+	switch f.Machine {
+	case pe.IMAGE_FILE_MACHINE_AMD64:
+		info.Arch = ArchAmd64
+	case pe.IMAGE_FILE_MACHINE_ARM64:
+		info.Arch = ArchArm64
+	case pe.IMAGE_FILE_MACHINE_I386:
+		info.Arch = ArchX86
+	}
+
+	if info.Arch == ArchAmd64 || info.Arch == ArchArm64 {
+		info.Platform = unreal.Windows
+	}
+
+	return info, nil
+}
+
+func inspectMachO(r io.ReaderAt) (*Info, error) {
+	f, err := macho.NewFile(r)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return &Info{Format: FormatMachO, Kind: machOKind(f.Type), Arch: machOArch(f.Cpu), OS: platform.Mac, Platform: unreal.Mac}, nil
+}
+
+func inspectFat(r io.ReaderAt) (*Info, error) {
+	f, err := macho.NewFatFile(r)
+	if err != nil {
+		// java class files share the magic number
+		return nil, err
+	}
+	defer f.Close()
+
+	info := &Info{Format: FormatFat, OS: platform.Mac, Platform: unreal.Mac}
+	for _, a := range f.Arches {
+		if info.Kind == "" {
+			info.Kind = machOKind(a.Type)
+		}
+		if arch := machOArch(a.Cpu); arch != "" {
+			info.Archs = append(info.Archs, arch)
+		}
+	}
+
+	return info, nil
+}
+
+func machOKind(t macho.Type) Kind {
+	switch t {
+	case macho.TypeExec:
+		return KindExecutable
+	case macho.TypeDylib, macho.TypeBundle:
+		return KindSharedLibrary
+	}
+	return KindObject
+}
+
+func machOArch(cpu macho.Cpu) Arch {
+	switch cpu {
+	case macho.CpuAmd64:
+		return ArchAmd64
+	case macho.CpuArm64:
+		return ArchArm64
+	case macho.Cpu386:
+		return ArchX86
+	}
+	return ""
+}
+
+func inspectScript(r io.ReaderAt) (*Info, error) {
+	line, err := bufio.NewReader(io.NewSectionReader(r, 0, 1024)).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	line = strings.TrimSpace(strings.TrimPrefix(line, "#!"))
+
+	return &Info{Format: FormatScript, Kind: KindExecutable, Interpreter: line}, nil
+}
@@ -31,15 +31,30 @@ func DescribeRows(rows pgx.Rows) (out string) {
 	return
 }
 
+// ListFilesRecursive returns paths of the files under the root relative to the root. Ignored paths are .gitignore-style
+// patterns, see IgnoreRules.
 func ListFilesRecursive(root string, ignore []string) ([]string, error) {
+	rules, err := ParseIgnoreRules(ignore)
+	if err != nil {
+		return nil, err
+	}
+
+	return ListFilesRecursiveWithRules(root, rules)
+}
+
+// ListFilesRecursiveWithRules returns paths of the files under the root that are not ignored by the rules, ignored
+// directories are skipped without walking them
+func ListFilesRecursiveWithRules(root string, rules *IgnoreRules) ([]string, error) {
 	var files []string
 	err := godirwalk.Walk(root, &godirwalk.Options{
 		Callback: func(path string, de *godirwalk.Dirent) error {
 			relPath, _ := filepath.Rel(root, path)
-			for _, ignoredFile := range ignore {
-				if strings.HasPrefix(relPath, ignoredFile) || strings.HasPrefix(filepath.Base(path), ignoredFile) {
-					return nil
-				}
+			if relPath == "." {
+				return nil
+			}
+
+			if rules.match(strings.Split(filepath.ToSlash(relPath), "/"), de.IsDir()) {
+				return godirwalk.SkipThis
 			}
 
 			if !de.IsDir() {
@@ -0,0 +1,201 @@
+package helper
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// IgnoreFileName is the default name of the file with ignore rules in the root of a directory tree
+const IgnoreFileName = ".veverseignore"
+
+type ignoreRule struct {
+	segments []string // pattern split by "/", "**" matches any number of segments
+	negate   bool     // "!" re-includes paths matched by previous rules
+	dirOnly  bool     // trailing "/" matches only directories
+}
+
+// IgnoreRules is an ordered list of .gitignore-style patterns, the last matching rule decides whether the path is
+// ignored. Supported syntax:
+//   - "*", "?" and "[a-z]" match within a path segment, "\" escapes special characters
+//   - "**" matches any number of directories ("**/*.pdb", "Plugins/**/Intermediate", "Saved/**")
+//   - patterns without a slash match the name at any level ("Saved" ignores "Saved/" and "Game/Saved/", but not "SavedGames.txt")
+//   - patterns with a leading or middle slash are relative to the root ("/Build", "Game/Saved")
+//   - a trailing slash matches only directories ("Intermediate/")
+//   - "!" negates the pattern, a file can not be re-included if its parent directory is ignored
+//   - blank lines and lines starting with "#" are skipped
+type IgnoreRules struct {
+	rules []ignoreRule
+}
+
+// ParseIgnoreRules parses patterns, one per entry
+func ParseIgnoreRules(patterns []string) (*IgnoreRules, error) {
+	var r = &IgnoreRules{}
+	if err := r.Add(patterns...); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// LoadIgnoreFile reads patterns from the file, one per line
+func LoadIgnoreFile(filename string) (*IgnoreRules, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	rules, err := ParseIgnoreRules(lines)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	return rules, nil
+}
+
+// Add appends patterns to the rules, later patterns take precedence
+func (r *IgnoreRules) Add(patterns ...string) error {
+	for _, p := range patterns {
+		rule, ok, err := parseIgnoreRule(p)
+		if err != nil {
+			return err
+		}
+		if ok {
+			r.rules = append(r.rules, rule)
+		}
+	}
+	return nil
+}
+
+func parseIgnoreRule(line string) (rule ignoreRule, ok bool, err error) {
+	line = strings.TrimRight(line, "\r")
+
+	// trailing spaces are ignored unless escaped
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+
+	if line == "" || strings.HasPrefix(line, "#") {
+		return rule, false, nil
+	}
+
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, "\\!") || strings.HasPrefix(line, "\\#") {
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+
+	if line == "" {
+		return rule, false, nil
+	}
+
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	rule.segments = strings.Split(line, "/")
+	if !anchored {
+		rule.segments = append([]string{"**"}, rule.segments...)
+	}
+
+	for _, s := range rule.segments {
+		if s == "**" {
+			continue
+		}
+		if _, err = path.Match(s, ""); err != nil {
+			return rule, false, fmt.Errorf("invalid ignore pattern %q: %w", line, err)
+		}
+	}
+
+	return rule, true, nil
+}
+
+// Match reports whether the slash separated path relative to the root is ignored, either by itself or because one of
+// its parent directories is ignored
+func (r *IgnoreRules) Match(relPath string, isDir bool) bool {
+	if r == nil || len(r.rules) == 0 {
+		return false
+	}
+
+	segments := strings.Split(strings.Trim(relPath, "/"), "/")
+	for i := 1; i < len(segments); i++ {
+		if r.match(segments[:i], true) {
+			return true
+		}
+	}
+
+	return r.match(segments, isDir)
+}
+
+// match checks the path only, parent directories are expected to be checked already (e.g. pruned by a walk)
+func (r *IgnoreRules) match(segments []string, isDir bool) bool {
+	if r == nil {
+		return false
+	}
+
+	ignored := false
+	for _, rule := range r.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if rule.negate != ignored {
+			// the rule would not change the result
+			continue
+		}
+		if matchSegments(rule.segments, segments) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+func matchSegments(pattern []string, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// skip repeated "**" and try to match the rest at any depth
+			for len(pattern) > 0 && pattern[0] == "**" {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				// a trailing "**" matches everything inside, but not the directory itself
+				return len(segments) > 0
+			}
+			for i := 0; i < len(segments); i++ {
+				if matchSegments(pattern, segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(segments) == 0 {
+			return false
+		}
+
+		if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+			return false
+		}
+
+		pattern = pattern[1:]
+		segments = segments[1:]
+	}
+
+	return len(segments) == 0
+}
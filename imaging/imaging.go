@@ -0,0 +1,184 @@
+// Package imaging generates preview and power-of-two texture variants of images uploaded to entities.
+package imaging
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"dev.hackerman.me/artheon/veverse-shared/model"
+	"dev.hackerman.me/artheon/veverse-shared/storage"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/gofrs/uuid"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+)
+
+var ErrImageTooLarge = errors.New("image is too large")
+
+const (
+	DefaultPreviewSize    = 512
+	DefaultTextureMaxSize = 2048
+	DefaultJPEGQuality    = 85
+	DefaultMaxPixels      = 64 * 1024 * 1024
+)
+
+type Options struct {
+	PreviewSize    int // max width and height of the preview (default: DefaultPreviewSize)
+	TextureMaxSize int // max width and height of the texture (default: DefaultTextureMaxSize)
+	JPEGQuality    int // quality of jpeg previews (default: DefaultJPEGQuality)
+	MaxPixels      int // max number of pixels of the source image, checked before decoding (default: DefaultMaxPixels)
+}
+
+// Variant is an encoded version of the image
+type Variant struct {
+	Type   model.UploadImageType `json:"type"`
+	Mime   string                `json:"mime"`
+	Width  int                   `json:"width"`
+	Height int                   `json:"height"`
+	Hash   string                `json:"hash"` // hex encoded sha256 of the data
+	Data   []byte                `json:"-"`
+}
+
+// LinkMetadata returns the metadata of the file link request for the variant stored at the url
+func (v Variant) LinkMetadata(url string) model.FileLinkRequestMetadata {
+	size := len(v.Data)
+	hash := v.Hash
+	mime := v.Mime
+	return model.FileLinkRequestMetadata{
+		Type:   v.Type.FileType(),
+		Url:    url,
+		Mime:   &mime,
+		Size:   &size,
+		Width:  v.Width,
+		Height: v.Height,
+		Hash:   &hash,
+	}
+}
+
+// Process decodes the PNG, JPEG or GIF image and returns the full image as uploaded, the preview fitting into the
+// preview size and the texture with power-of-two dimensions
+func Process(r io.Reader, options Options) ([]Variant, error) {
+	if options.PreviewSize <= 0 {
+		options.PreviewSize = DefaultPreviewSize
+	}
+	if options.TextureMaxSize <= 0 {
+		options.TextureMaxSize = DefaultTextureMaxSize
+	}
+	if options.JPEGQuality <= 0 {
+		options.JPEGQuality = DefaultJPEGQuality
+	}
+	if options.MaxPixels <= 0 {
+		options.MaxPixels = DefaultMaxPixels
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > options.MaxPixels {
+		return nil, fmt.Errorf("%w: %dx%d", ErrImageTooLarge, config.Width, config.Height)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	full := newVariant(model.ImageFull, "image/"+format, img.Bounds().Dx(), img.Bounds().Dy(), data)
+
+	w, h := Fit(full.Width, full.Height, options.PreviewSize, options.PreviewSize)
+	preview, err := encode(model.ImagePreview, Resize(img, w, h), options)
+	if err != nil {
+		return nil, err
+	}
+
+	w, h = PowerOfTwo(full.Width, options.TextureMaxSize), PowerOfTwo(full.Height, options.TextureMaxSize)
+	texture, err := encode(model.ImageTexture, Resize(img, w, h), options)
+	if err != nil {
+		return nil, err
+	}
+
+	return []Variant{full, *preview, *texture}, nil
+}
+
+// Upload stores the variants and links them to the entity, url returns the permanent url of the stored content (e.g.
+// the CDN url of the storage path). Re-uploaded images replace the previous files and increment their versions.
+func Upload(ctx context.Context, requester *model.User, entityId uuid.UUID, store storage.Storage, variants []Variant, url func(hash string) (string, error)) (files []model.File, err error) {
+	for _, v := range variants {
+		_, err = store.Put(ctx, bytes.NewReader(v.Data), storage.PutOptions{Hash: v.Hash, Size: int64(len(v.Data)), Mime: v.Mime})
+		if err != nil {
+			return nil, fmt.Errorf("failed to store %s: %w", v.Type.FileType(), err)
+		}
+
+		u, err := url(v.Hash)
+		if err != nil {
+			return nil, err
+		}
+
+		file, err := model.LinkFile(ctx, requester, entityId, v.LinkMetadata(u))
+		if err != nil {
+			return nil, fmt.Errorf("failed to link %s: %w", v.Type.FileType(), err)
+		}
+
+		files = append(files, *file)
+	}
+
+	return files, nil
+}
+
+// encode writes opaque previews as JPEG and textures and transparent previews as PNG
+func encode(t model.UploadImageType, img *image.RGBA, options Options) (*Variant, error) {
+	var (
+		data []byte
+		mime string
+		err  error
+	)
+
+	if t == model.ImagePreview {
+		data, mime, err = Encode(img, options.JPEGQuality)
+	} else {
+		data, mime, err = Encode(img, 0)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s: %w", t.FileType(), err)
+	}
+
+	v := newVariant(t, mime, img.Bounds().Dx(), img.Bounds().Dy(), data)
+	return &v, nil
+}
+
+// Encode writes the image as JPEG of the quality if it is opaque and the quality is set, as PNG otherwise
+func Encode(img *image.RGBA, quality int) (data []byte, mime string, err error) {
+	var buf bytes.Buffer
+
+	if quality > 0 && img.Opaque() {
+		mime = "image/jpeg"
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality})
+	} else {
+		mime = "image/png"
+		err = png.Encode(&buf, img)
+	}
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	return buf.Bytes(), mime, nil
+}
+
+func newVariant(t model.UploadImageType, mime string, width, height int, data []byte) Variant {
+	sum := sha256.Sum256(data)
+	return Variant{Type: t, Mime: mime, Width: width, Height: height, Hash: hex.EncodeToString(sum[:]), Data: data}
+}
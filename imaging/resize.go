@@ -0,0 +1,157 @@
+package imaging
+
+import (
+	"image"
+	"image/draw"
+	"math"
+)
+
+// Resize scales the image to the size, downscaling averages the covered source pixels and upscaling interpolates
+// linearly. Colors are averaged with premultiplied alpha, so transparent pixels do not darken the edges.
+func Resize(src image.Image, width, height int) *image.RGBA {
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+
+	rgba := toRGBA(src)
+	srcW, srcH := rgba.Bounds().Dx(), rgba.Bounds().Dy()
+
+	xWeights := resizeWeights(srcW, width)
+	yWeights := resizeWeights(srcH, height)
+
+	// horizontal pass
+	tmp := make([]float64, srcH*width*4)
+	for y := 0; y < srcH; y++ {
+		row := rgba.Pix[y*rgba.Stride:]
+		for x, weights := range xWeights {
+			var r, g, b, a float64
+			for _, w := range weights {
+				p := row[w.index*4:]
+				r += float64(p[0]) * w.weight
+				g += float64(p[1]) * w.weight
+				b += float64(p[2]) * w.weight
+				a += float64(p[3]) * w.weight
+			}
+			t := tmp[(y*width+x)*4:]
+			t[0], t[1], t[2], t[3] = r, g, b, a
+		}
+	}
+
+	// vertical pass
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y, weights := range yWeights {
+		for x := 0; x < width; x++ {
+			var r, g, b, a float64
+			for _, w := range weights {
+				t := tmp[(w.index*width+x)*4:]
+				r += t[0] * w.weight
+				g += t[1] * w.weight
+				b += t[2] * w.weight
+				a += t[3] * w.weight
+			}
+			p := dst.Pix[y*dst.Stride+x*4:]
+			p[0], p[1], p[2], p[3] = clamp(r), clamp(g), clamp(b), clamp(a)
+		}
+	}
+
+	return dst
+}
+
+// Fit returns the largest size within the bounds that keeps the aspect ratio, images smaller than the bounds are not
+// enlarged
+func Fit(width, height, maxWidth, maxHeight int) (int, int) {
+	if width <= maxWidth && height <= maxHeight {
+		return width, height
+	}
+
+	scale := math.Min(float64(maxWidth)/float64(width), float64(maxHeight)/float64(height))
+	w := int(math.Round(float64(width) * scale))
+	h := int(math.Round(float64(height) * scale))
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	return w, h
+}
+
+// PowerOfTwo returns the power of two nearest to the size on the logarithmic scale, limited to max
+func PowerOfTwo(size, max int) int {
+	if size < 1 {
+		size = 1
+	}
+
+	p := 1 << int(math.Round(math.Log2(float64(size))))
+	for max > 0 && p > max {
+		p >>= 1
+	}
+	return p
+}
+
+type weight struct {
+	index  int
+	weight float64
+}
+
+// resizeWeights returns source pixels and their weights for each destination pixel
+func resizeWeights(srcSize, dstSize int) [][]weight {
+	var (
+		weights = make([][]weight, dstSize)
+		scale   = float64(srcSize) / float64(dstSize)
+	)
+
+	for i := range weights {
+		if scale <= 1 {
+			// linear interpolation between the two nearest pixels
+			center := (float64(i)+0.5)*scale - 0.5
+			x0 := int(math.Floor(center))
+			frac := center - float64(x0)
+			x1 := x0 + 1
+			if x0 < 0 {
+				x0 = 0
+			}
+			if x1 > srcSize-1 {
+				x1 = srcSize - 1
+			}
+			weights[i] = []weight{{x0, 1 - frac}, {x1, frac}}
+			continue
+		}
+
+		// area average of the covered pixels
+		start := float64(i) * scale
+		end := start + scale
+		for j := int(math.Floor(start)); j < int(math.Ceil(end)) && j < srcSize; j++ {
+			w := math.Min(end, float64(j+1)) - math.Max(start, float64(j))
+			if w > 0 {
+				weights[i] = append(weights[i], weight{j, w / scale})
+			}
+		}
+	}
+
+	return weights
+}
+
+func toRGBA(src image.Image) *image.RGBA {
+	if rgba, ok := src.(*image.RGBA); ok && rgba.Bounds().Min == (image.Point{}) {
+		return rgba
+	}
+
+	b := src.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, b.Min, draw.Src)
+	return rgba
+}
+
+func clamp(v float64) uint8 {
+	if v <= 0 {
+		return 0
+	}
+	if v >= 255 {
+		return 255
+	}
+	return uint8(v + 0.5)
+}
@@ -0,0 +1,291 @@
+// Package install verifies installed releases against their release file manifest and plans repairs.
+package install
+
+import (
+	"context"
+	"crypto/sha256"
+	"dev.hackerman.me/artheon/veverse-shared/helper"
+	"dev.hackerman.me/artheon/veverse-shared/model"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type Status string
+
+const (
+	StatusOk        Status = "ok"
+	StatusMissing   Status = "missing"   // file of the release is not installed
+	StatusCorrupted Status = "corrupted" // installed file size or hash does not match the release file
+	StatusExtra     Status = "extra"     // installed file is not a part of the release
+)
+
+// FileResult is the verification result of a single file
+type FileResult struct {
+	Path   string      `json:"path"` // slash separated path relative to the install directory
+	Status Status      `json:"status"`
+	File   *model.File `json:"file,omitempty"` // release file, nil for extra files
+	Size   int64       `json:"size"`           // installed file size
+	Hash   string      `json:"hash,omitempty"` // installed file hash, empty if the file was not hashed
+}
+
+// RepairPlan lists files to re-download and files that are not a part of the release
+type RepairPlan struct {
+	Download     []model.File `json:"download"`
+	DownloadSize int64        `json:"downloadSize"`
+	Extra        []string     `json:"extra"` // extra files can be deleted, but may be user data that should be ignored
+}
+
+// Report is the result of the install directory verification
+type Report struct {
+	Files  []FileResult `json:"files"`
+	Repair RepairPlan   `json:"repair"`
+}
+
+// Ok reports whether all release files are installed and valid, extra files are ignored
+func (r *Report) Ok() bool {
+	return len(r.Repair.Download) == 0
+}
+
+// Progress of the verification, bytes are counted while files are hashed
+type Progress struct {
+	Files      int    `json:"files"`
+	TotalFiles int    `json:"totalFiles"`
+	Bytes      int64  `json:"bytes"`
+	TotalBytes int64  `json:"totalBytes"`
+	Path       string `json:"path"` // last processed file
+}
+
+type Options struct {
+	Workers    int              // number of files hashed in parallel (default: number of CPUs)
+	Ignore     []string         // paths ignored when looking for extra files, see helper.ListFilesRecursive
+	NewHash    func() hash.Hash // hash algorithm of the release file hashes (default: sha256)
+	OnProgress func(Progress)   // called after each hashed block and each verified file, calls are serialized
+}
+
+// VerifyRelease verifies the install directory against the release files of the platform and deployment, archives and
+// other files attached to the release are not installed and are skipped
+func VerifyRelease(ctx context.Context, root string, release *model.ReleaseV2, platform string, deployment string, options Options) (*Report, error) {
+	if release == nil {
+		return nil, fmt.Errorf("no release")
+	}
+
+	var files []model.File
+	if release.Files != nil {
+		for _, f := range release.Files.Entities {
+			if f.Type != model.FileTypeRelease || f.Platform != platform || f.Deployment != deployment {
+				continue
+			}
+			files = append(files, f)
+		}
+	}
+
+	return Verify(ctx, root, files, options)
+}
+
+// Verify checks that every release file exists in the root directory and has the expected size and hash, and looks for
+// files that are not a part of the release. Files without a hash are verified by their size only.
+func Verify(ctx context.Context, root string, files []model.File, options Options) (*Report, error) {
+	if options.Workers <= 0 {
+		options.Workers = runtime.NumCPU()
+	}
+
+	if options.NewHash == nil {
+		options.NewHash = sha256.New
+	}
+
+	var installed []string
+	if _, err := os.Stat(root); err == nil {
+		installed, err = helper.ListFilesRecursive(root, options.Ignore)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list installed files: %w", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	var (
+		expected = map[string]bool{}
+		jobs     []FileResult
+		progress = Progress{}
+	)
+
+	for i := range files {
+		f := files[i]
+		if f.Type == model.FileTypeReleaseChunkIndex {
+			continue
+		}
+		p := model.ReleaseManifestPath(f)
+		if p == "" || expected[p] {
+			continue
+		}
+		expected[p] = true
+		jobs = append(jobs, FileResult{Path: p, File: &f})
+		if f.Size != nil {
+			progress.TotalBytes += *f.Size
+		}
+	}
+
+	progress.TotalFiles = len(jobs)
+
+	var (
+		mu      sync.Mutex
+		results = make([]FileResult, len(jobs))
+		queue   = make(chan int)
+		errs    = make(chan error, options.Workers)
+		wg      sync.WaitGroup
+	)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	report := func(path string, bytes int64, done bool) {
+		if options.OnProgress == nil {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		progress.Bytes += bytes
+		progress.Path = path
+		if done {
+			progress.Files++
+		}
+		options.OnProgress(progress)
+	}
+
+	for w := 0; w < options.Workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				result, err := verifyFile(ctx, root, jobs[i], options.NewHash, func(n int64) { report(jobs[i].Path, n, false) })
+				if err != nil {
+					errs <- err
+					cancel()
+					return
+				}
+				results[i] = result
+				report(result.Path, 0, true)
+			}
+		}()
+	}
+
+loop:
+	for i := range jobs {
+		select {
+		case queue <- i:
+		case <-ctx.Done():
+			break loop
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	select {
+	case err := <-errs:
+		return nil, err
+	default:
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var r = &Report{Files: results}
+	for _, result := range results {
+		if result.Status == StatusMissing || result.Status == StatusCorrupted {
+			r.Repair.Download = append(r.Repair.Download, *result.File)
+			if result.File.Size != nil {
+				r.Repair.DownloadSize += *result.File.Size
+			}
+		}
+	}
+
+	for _, p := range installed {
+		p = filepath.ToSlash(p)
+		if expected[p] {
+			continue
+		}
+		var size int64
+		if info, err := os.Stat(filepath.Join(root, filepath.FromSlash(p))); err == nil {
+			size = info.Size()
+		}
+		r.Files = append(r.Files, FileResult{Path: p, Status: StatusExtra, Size: size})
+		r.Repair.Extra = append(r.Repair.Extra, p)
+	}
+
+	sort.SliceStable(r.Files, func(i, j int) bool {
+		return r.Files[i].Path < r.Files[j].Path
+	})
+	sort.Strings(r.Repair.Extra)
+
+	return r, nil
+}
+
+func verifyFile(ctx context.Context, root string, result FileResult, newHash func() hash.Hash, onRead func(int64)) (FileResult, error) {
+	info, err := os.Stat(filepath.Join(root, filepath.FromSlash(result.Path)))
+	if err != nil || info.IsDir() {
+		if err == nil || os.IsNotExist(err) {
+			result.Status = StatusMissing
+			return result, nil
+		}
+		return result, fmt.Errorf("failed to stat %s: %w", result.Path, err)
+	}
+
+	result.Size = info.Size()
+	if result.File.Size != nil && *result.File.Size != result.Size {
+		result.Status = StatusCorrupted
+		return result, nil
+	}
+
+	if result.File.Hash == nil || *result.File.Hash == "" {
+		result.Status = StatusOk
+		return result, nil
+	}
+
+	file, err := os.Open(filepath.Join(root, filepath.FromSlash(result.Path)))
+	if err != nil {
+		return result, fmt.Errorf("failed to open %s: %w", result.Path, err)
+	}
+	defer file.Close()
+
+	h := newHash()
+	_, err = io.Copy(h, &progressReader{ctx: ctx, reader: file, onRead: onRead})
+	if err != nil {
+		return result, fmt.Errorf("failed to hash %s: %w", result.Path, err)
+	}
+
+	result.Hash = hex.EncodeToString(h.Sum(nil))
+	if strings.EqualFold(result.Hash, *result.File.Hash) {
+		result.Status = StatusOk
+	} else {
+		result.Status = StatusCorrupted
+	}
+
+	return result, nil
+}
+
+// progressReader reports read bytes and stops reading when the context is cancelled
+type progressReader struct {
+	ctx    context.Context
+	reader io.Reader
+	onRead func(int64)
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		r.onRead(int64(n))
+	}
+	return n, err
+}
@@ -0,0 +1,76 @@
+-- +goose Up
+-- +goose StatementBegin
+
+create table if not exists region
+(
+    id         uuid primary key default gen_random_uuid(),
+    created_at timestamp        default now(),
+    updated_at timestamp,
+    name       text not null
+);
+
+alter table region
+    add column if not exists latitude   double precision default null, -- geo latitude of the region datacenter
+    add column if not exists longitude  double precision default null, -- geo longitude of the region datacenter
+    add column if not exists probe_host text             default null, -- host used by clients to measure latency to the region
+    add column if not exists probe_port int              default null, -- port used by clients to measure latency to the region
+    add column if not exists enabled    boolean          default true not null; -- disabled regions are excluded from the region selection
+
+comment on table region is 'Region table (region is a deployment location of game servers and pixel streaming instances).';
+
+create unique index if not exists region_name_idx
+    on region (name);
+
+create index if not exists region_enabled_idx
+    on region (enabled);
+
+alter table game_server_v2
+    add column if not exists region_id uuid default null -- replaces the free text region column
+        references region
+            on delete set null;
+
+create index if not exists game_server_region_id_idx
+    on game_server_v2 (region_id);
+
+-- move free text regions of existing servers to the registry
+insert into region (name)
+select distinct gs.region
+from game_server_v2 gs
+where coalesce(gs.region, '') != ''
+on conflict (name) do nothing;
+
+update game_server_v2 gs
+set region_id = r.id
+from region r
+where gs.region_id is null
+  and gs.region = r.name;
+
+drop index if exists game_server_region_idx;
+
+alter table game_server_v2
+    drop column if exists region;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+alter table game_server_v2
+    add column if not exists region text; -- server region, in cluster deployments this is the cluster region (e.g. us-east-1), in community deployments can be any string or empty
+
+update game_server_v2 gs
+set region = r.name
+from region r
+where gs.region_id = r.id;
+
+create index if not exists game_server_region_idx
+    on game_server_v2 (region);
+
+drop index if exists game_server_region_id_idx;
+
+alter table game_server_v2
+    drop column if exists region_id;
+
+-- region is kept as it may predate this migration
+
+-- +goose StatementEnd
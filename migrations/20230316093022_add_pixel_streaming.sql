@@ -0,0 +1,71 @@
+-- +goose Up
+-- +goose StatementBegin
+
+create table if not exists pixel_streaming_instance
+(
+    id             uuid primary key default gen_random_uuid(),
+    created_at     timestamp        default now(),
+    updated_at     timestamp,              -- instance last update time (heartbeat or status change)
+    release_id     uuid                    -- release running on the instance
+        references release_v2
+            on delete cascade,
+    region_id      uuid             default null -- region of the instance
+        references region
+            on delete set null,
+    host           text not null,          -- public host of the instance
+    port           int  not null,          -- public port of the instance
+    instance_type  text             default '' not null, -- instance type used to match sessions (e.g. GPU class)
+    status         text not null,          -- instance status (free, busy, error, quarantined)
+    status_message text             default null, -- status message (error or quarantine reason)
+
+    constraint pixel_streaming_instance_host_port_key
+        unique (host, port)
+);
+
+comment on table pixel_streaming_instance is 'Pixel streaming instance table (instance is a cloud machine running a release and streaming it to a single user).';
+
+create index if not exists pixel_streaming_instance_release_id_idx
+    on pixel_streaming_instance (release_id);
+
+create index if not exists pixel_streaming_instance_region_id_idx
+    on pixel_streaming_instance (region_id);
+
+create index if not exists pixel_streaming_instance_status_idx
+    on pixel_streaming_instance (status, instance_type);
+
+create table if not exists pixel_streaming_session
+(
+    id          uuid primary key default gen_random_uuid(),
+    created_at  timestamp        default now(),
+    updated_at  timestamp,              -- session last update time (heartbeat or status change)
+    instance_id uuid                    -- instance allocated to the session
+        references pixel_streaming_instance
+            on delete set null,
+    user_id     uuid                    -- user who opened the session
+        references users
+            on delete cascade,
+    app_id      uuid not null,          -- app requested by the user
+    world_id    uuid not null,          -- world requested by the user
+    status      text not null           -- session status (active, ended, expired)
+);
+
+comment on table pixel_streaming_session is 'Pixel streaming session table (session is a user streaming an app world from an allocated instance).';
+
+create index if not exists pixel_streaming_session_instance_id_idx
+    on pixel_streaming_session (instance_id);
+
+create index if not exists pixel_streaming_session_user_id_idx
+    on pixel_streaming_session (user_id);
+
+create index if not exists pixel_streaming_session_status_idx
+    on pixel_streaming_session (status);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+drop table if exists pixel_streaming_session;
+drop table if exists pixel_streaming_instance;
+
+-- +goose StatementEnd
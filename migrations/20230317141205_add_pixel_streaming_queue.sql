@@ -0,0 +1,48 @@
+-- +goose Up
+-- +goose StatementBegin
+
+create table if not exists pixel_streaming_queue
+(
+    id            uuid primary key default gen_random_uuid(),
+    created_at    timestamp        default now(), -- time the user joined the queue
+    updated_at    timestamp,                      -- time the user last polled the queue (abandoned entries expire)
+    user_id       uuid not null                   -- user waiting for an instance
+        references users
+            on delete cascade,
+    app_id        uuid not null,                  -- app requested by the user
+    world_id      uuid not null,                  -- world requested by the user
+    region_id     uuid             default null   -- region requested by the user
+        references region
+            on delete set null,
+    instance_type text             default '' not null, -- instance type requested by the user
+    priority      int              default 0 not null,  -- internal users and admins have higher priority
+    status        text not null,                  -- entry status (waiting, allocated, cancelled, expired)
+    session_id    uuid             default null   -- session allocated to the user
+        references pixel_streaming_session
+            on delete set null
+);
+
+comment on table pixel_streaming_queue is 'Pixel streaming queue table (users waiting for a free pixel streaming instance when the pool is exhausted).';
+
+create index if not exists pixel_streaming_queue_pool_idx
+    on pixel_streaming_queue (app_id, region_id, instance_type, status);
+
+create index if not exists pixel_streaming_queue_order_idx
+    on pixel_streaming_queue (status, priority desc, created_at);
+
+create index if not exists pixel_streaming_queue_user_id_idx
+    on pixel_streaming_queue (user_id);
+
+-- a user waits at most once for the same pool, regions are coalesced as null values are distinct
+create unique index if not exists pixel_streaming_queue_waiting_idx
+    on pixel_streaming_queue (user_id, app_id, world_id, coalesce(region_id, '00000000-0000-0000-0000-000000000000'::uuid), instance_type)
+    where status = 'waiting';
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+drop table if exists pixel_streaming_queue;
+
+-- +goose StatementEnd
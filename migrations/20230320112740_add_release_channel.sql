@@ -0,0 +1,52 @@
+-- +goose Up
+-- +goose StatementBegin
+
+create table if not exists release_channel
+(
+    id         uuid primary key default gen_random_uuid(),
+    created_at timestamp        default now(),
+    updated_at timestamp,
+    entity_id  uuid not null -- parent app or launcher
+        references entities
+            on delete cascade,
+    name       text not null,
+    internal   boolean          default false not null -- only internal users can receive releases of the channel
+);
+
+comment on table release_channel is 'Release channel table (named release track of an app or launcher, e.g. stable, beta or internal).';
+
+create unique index if not exists release_channel_entity_id_name_idx
+    on release_channel (entity_id, name);
+
+create table if not exists release_channel_release
+(
+    channel_id         uuid not null
+        references release_channel
+            on delete cascade,
+    release_id         uuid not null
+        references release_v2
+            on delete cascade,
+    rollout_percentage int       default 100 not null -- share of users receiving the release in the channel
+        check (rollout_percentage >= 0 and rollout_percentage <= 100),
+    promoted_at        timestamp default now(),
+    promoted_by        uuid      default null
+        references users
+            on delete set null,
+    primary key (channel_id, release_id)
+);
+
+comment on table release_channel_release is 'Releases promoted to release channels with the staged rollout percentage.';
+
+create index if not exists release_channel_release_release_id_idx
+    on release_channel_release (release_id);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+drop table if exists release_channel_release;
+
+drop table if exists release_channel;
+
+-- +goose StatementEnd
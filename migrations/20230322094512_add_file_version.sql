@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+
+create table if not exists file_version
+(
+    file_id     uuid   not null
+        references files
+            on delete cascade,
+    version     bigint not null,
+    url         text   not null,
+    mime        text,
+    size        bigint,
+    width       integer,
+    height      integer,
+    hash        text,
+    uploaded_by uuid      default null
+        references users
+            on delete set null,
+    created_at  timestamp default now(), -- when the version was uploaded
+    replaced_at timestamp default now(), -- when the version was replaced by the next one
+    primary key (file_id, version)
+);
+
+comment on table file_version is 'Previous versions of files replaced by re-uploading or re-linking.';
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+drop table if exists file_version;
+
+-- +goose StatementEnd
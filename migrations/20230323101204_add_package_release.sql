@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+
+alter table mods
+    add column if not exists released_at timestamp default null; -- when the package was published
+
+alter table mods
+    add column if not exists downloads integer not null default 0; -- number of users who downloaded the package
+
+create table if not exists mod_download_v2
+(
+    mod_id     uuid not null references mods (id) on delete cascade,
+    user_id    uuid not null references users (id) on delete cascade,
+    created_at timestamp default now(),
+    primary key (mod_id, user_id)
+);
+
+comment on table mod_download_v2 is 'Users who downloaded the package, each user is counted in mods.downloads once.';
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+drop table if exists mod_download_v2;
+
+-- released_at and downloads are kept as they may predate this migration
+
+-- +goose StatementEnd
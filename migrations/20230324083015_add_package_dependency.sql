@@ -0,0 +1,34 @@
+-- +goose Up
+-- +goose StatementBegin
+
+alter table mods
+    add column if not exists sdk_constraint text default null; -- semver range of compatible SDK release code versions, e.g. ^1.2
+
+create table if not exists mod_dependency
+(
+    mod_id        uuid              not null
+        references mods
+            on delete cascade,
+    dependency_id uuid              not null
+        references mods
+            on delete cascade,
+    version_range text default null, -- semver range of compatible dependency versions, any version if not set
+    position      integer default 0 not null, -- declaration order, dependencies are mounted in this order
+    created_at    timestamp default now(),
+    primary key (mod_id, dependency_id),
+    check (mod_id <> dependency_id)
+);
+
+comment on table mod_dependency is 'Packages required by other packages, mounted before the dependent package.';
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+drop table if exists mod_dependency;
+
+alter table mods
+    drop column if exists sdk_constraint;
+
+-- +goose StatementEnd
@@ -0,0 +1,31 @@
+-- +goose Up
+-- +goose StatementBegin
+
+create table if not exists app_worlds_v2
+(
+    app_id       uuid not null -- reference to app
+        references app_v2
+            on delete cascade,
+    world_id     uuid not null -- reference to world
+        references spaces
+            on delete cascade,
+    published_by uuid      default null -- user who published the world to the app
+        references users
+            on delete set null,
+    published_at timestamp default now(),
+    primary key (app_id, world_id)
+);
+
+create index if not exists app_worlds_v2_world_id_idx
+    on app_worlds_v2 (world_id);
+
+comment on table app_worlds_v2 is 'Worlds published to apps by their owners, apps show only worlds published to them.';
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+drop table if exists app_worlds_v2;
+
+-- +goose StatementEnd
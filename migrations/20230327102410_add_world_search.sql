@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+
+alter table spaces
+    add column if not exists search tsvector generated always as (
+                setweight(to_tsvector('english', coalesce(name, '')), 'A') ||
+                setweight(to_tsvector('english', coalesce(description, '')), 'B')) stored; -- full-text search document of the world
+
+create index if not exists spaces_search_idx
+    on spaces using gin (search);
+
+create index if not exists spaces_game_mode_idx
+    on spaces (game_mode);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+drop index if exists spaces_game_mode_idx;
+
+drop index if exists spaces_search_idx;
+
+alter table spaces
+    drop column if exists search;
+
+-- +goose StatementEnd
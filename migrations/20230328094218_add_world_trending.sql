@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+
+create table if not exists world_trending_v2
+(
+    world_id   uuid                       not null primary key
+        references spaces
+            on delete cascade,
+    score      double precision default 0 not null, -- time-decayed activity score
+    updated_at timestamp        default now()       -- last refresh, activity after it is added on the next refresh
+);
+
+create index if not exists world_trending_v2_score_idx
+    on world_trending_v2 (score desc);
+
+comment on table world_trending_v2 is 'Trending scores of worlds, refreshed incrementally from visits, likes and concurrent players.';
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+drop table if exists world_trending_v2;
+
+-- +goose StatementEnd
@@ -0,0 +1,78 @@
+-- +goose Up
+-- +goose StatementBegin
+
+alter table entities
+    add column if not exists likes integer default 0 not null; -- number of likes, maintained by the likables trigger
+
+alter table entities
+    add column if not exists dislikes integer default 0 not null; -- number of dislikes, maintained by the likables trigger
+
+-- keep the latest rating of each user
+delete
+from likables
+where ctid in (select ctid
+               from (select ctid,
+                            row_number() over (partition by entity_id, user_id order by updated_at desc nulls last, created_at desc) as n
+                     from likables) d
+               where d.n > 1);
+
+alter table likables
+    add constraint likables_entity_id_user_id_key unique (entity_id, user_id);
+
+update entities e
+set likes    = l.likes,
+    dislikes = l.dislikes
+from (select entity_id,
+             count(*) filter (where value > 0) as likes,
+             count(*) filter (where value < 0) as dislikes
+      from likables
+      group by entity_id) l
+where e.id = l.entity_id;
+
+-- counters follow every change of likables, whoever writes them
+create or replace function likables_update_entity_rating() returns trigger as
+$$
+begin
+    if tg_op in ('UPDATE', 'DELETE') then
+        update entities
+        set likes    = greatest(likes - (old.value > 0)::int, 0),
+            dislikes = greatest(dislikes - (old.value < 0)::int, 0)
+        where id = old.entity_id;
+    end if;
+
+    if tg_op in ('INSERT', 'UPDATE') then
+        update entities
+        set likes    = likes + (new.value > 0)::int,
+            dislikes = dislikes + (new.value < 0)::int
+        where id = new.entity_id;
+    end if;
+
+    return null;
+end;
+$$ language plpgsql;
+
+create trigger likables_update_entity_rating
+    after insert or update of value, entity_id or delete
+    on likables
+    for each row
+execute function likables_update_entity_rating();
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+drop trigger if exists likables_update_entity_rating on likables;
+
+drop function if exists likables_update_entity_rating();
+
+alter table likables
+    drop constraint if exists likables_entity_id_user_id_key;
+
+alter table entities
+    drop column if exists dislikes;
+
+alter table entities
+    drop column if exists likes;
+
+-- +goose StatementEnd
@@ -0,0 +1,81 @@
+-- +goose Up
+-- +goose StatementBegin
+
+create table if not exists comment_v2
+(
+    id         uuid      default gen_random_uuid() not null primary key,
+    entity_id  uuid                                not null -- commented entity
+        references entities
+            on delete cascade,
+    user_id    uuid                                          -- author
+        references users
+            on delete set null,
+    parent_id  uuid      default null                        -- comment this comment replies to
+        references comment_v2
+            on delete cascade,
+    text       text                                not null,
+    hidden     boolean   default false             not null, -- hidden by a moderator, visible to the author and moderators only
+    hidden_by  uuid      default null
+        references users
+            on delete set null,
+    created_at timestamp default now(),
+    updated_at timestamp default null,                       -- last edit of the text
+    deleted_at timestamp default null                        -- soft deleted, the text is not returned, replies are kept
+);
+
+create index if not exists comment_v2_entity_id_created_at_idx
+    on comment_v2 (entity_id, created_at);
+
+create index if not exists comment_v2_parent_id_idx
+    on comment_v2 (parent_id);
+
+comment on table comment_v2 is 'Comments of entities, replies reference their parent comment.';
+
+create table if not exists comment_version_v2
+(
+    comment_id uuid not null
+        references comment_v2
+            on delete cascade,
+    text       text not null,            -- text before the edit
+    created_at timestamp default now(),  -- when the text was replaced
+    primary key (comment_id, created_at)
+);
+
+comment on table comment_version_v2 is 'Previous texts of edited comments.';
+
+create table if not exists comment_report_v2
+(
+    id          uuid      default gen_random_uuid() not null primary key,
+    comment_id  uuid                                not null
+        references comment_v2
+            on delete cascade,
+    user_id     uuid                                not null -- reporter
+        references users
+            on delete cascade,
+    reason      text      default null,
+    status      text      default 'open'            not null, -- open, resolved (action taken) or dismissed
+    created_at  timestamp default now(),
+    resolved_at timestamp default null,
+    resolved_by uuid      default null
+        references users
+            on delete set null,
+    unique (comment_id, user_id)
+);
+
+create index if not exists comment_report_v2_status_created_at_idx
+    on comment_report_v2 (status, created_at);
+
+comment on table comment_report_v2 is 'Comment reports of users, open reports form the moderation queue.';
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+drop table if exists comment_report_v2;
+
+drop table if exists comment_version_v2;
+
+drop table if exists comment_v2;
+
+-- +goose StatementEnd
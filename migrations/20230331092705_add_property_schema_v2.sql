@@ -0,0 +1,44 @@
+-- +goose Up
+-- +goose StatementBegin
+
+create table if not exists property_schema_v2
+(
+    id            uuid      default gen_random_uuid() not null primary key,
+    entity_type   text                                not null, -- entity type the schema applies to, e.g. space or game-lobby
+    name          text                                not null, -- property name
+    type          text                                not null, -- int, float, bool, string, json, vector or color
+    default_value text      default null,                       -- value of the property if it is not set
+    constraints   jsonb     default null,                       -- min, max, minLength, maxLength, pattern, enum
+    required      boolean   default false             not null, -- property can not be deleted
+    description   text      default null,
+    created_at    timestamp default now(),
+    updated_at    timestamp default null,
+    unique (entity_type, name),
+    check (not required or default_value is not null) -- required properties fall back to the default value
+);
+
+comment on table property_schema_v2 is 'Declared properties of entity types, property values are validated against them.';
+
+-- keep the latest value of each property
+delete
+from properties
+where ctid in (select ctid
+               from (select ctid,
+                            row_number() over (partition by entity_id, name order by ctid desc) as n
+                     from properties) d
+               where d.n > 1);
+
+alter table properties
+    add constraint properties_entity_id_name_key unique (entity_id, name);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+alter table properties
+    drop constraint if exists properties_entity_id_name_key;
+
+drop table if exists property_schema_v2;
+
+-- +goose StatementEnd
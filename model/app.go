@@ -46,8 +46,10 @@ func (a *AppV2) InitReleases() {
 }
 
 type GetEntityImageRequest struct {
-	Id   string
-	Type string
+	Id     string
+	Type   string
+	Width  int // target width (optional), the smallest variant covering the target size is returned
+	Height int // target height (optional)
 }
 
 func GetEntityImage(ctx context.Context, requester *User, request GetEntityImageRequest) (entity *File, err error) {
@@ -69,6 +71,10 @@ func GetEntityImage(ctx context.Context, requester *User, request GetEntityImage
 		return nil, fmt.Errorf("image type is invalid")
 	}
 
+	if request.Width > 0 || request.Height > 0 {
+		return getEntityImageVariant(ctx, db, request)
+	}
+
 	var q = `select f.id,
        f.entity_id,
        f.type,
@@ -101,6 +107,34 @@ limit 1`
 	return f, nil
 }
 
+// getEntityImageVariant returns the smallest image covering the target size or the largest image if none does, the
+// variants are the full image and its preview unless the type is set
+func getEntityImageVariant(ctx context.Context, db *pgxpool.Pool, request GetEntityImageRequest) (*File, error) {
+	types := []string{FileTypeImageFull, FileTypeImagePreview}
+	if request.Type != "" {
+		types = []string{request.Type}
+	}
+
+	q := `select ` + fileColumns + `
+from files f
+where f.entity_id = $1
+  and f.type = any ($2)
+order by coalesce(f.width, 0) >= $3 and coalesce(f.height, 0) >= $4 desc,
+         case
+             when coalesce(f.width, 0) >= $3 and coalesce(f.height, 0) >= $4 then coalesce(f.width, 0) * coalesce(f.height, 0)
+             else -coalesce(f.width, 0) * coalesce(f.height, 0)
+             end,
+         f.created_at desc
+limit 1`
+
+	f, err := scanFile(db.QueryRow(ctx, q, request.Id, types, request.Width, request.Height))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get entity image: %w", err)
+	}
+
+	return f, nil
+}
+
 type GetAppLogoRequest struct {
 	Id string
 }
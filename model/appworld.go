@@ -0,0 +1,182 @@
+package model
+
+import (
+	"context"
+	glContext "dev.hackerman.me/artheon/veverse-shared/context"
+	"dev.hackerman.me/artheon/veverse-shared/helper"
+	"fmt"
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"strconv"
+)
+
+// PublishWorldToApp makes the world available in the app, only the world owner or editors can publish it
+func PublishWorldToApp(ctx context.Context, requester *User, appId uuid.UUID, worldId uuid.UUID) (err error) {
+	if requester == nil || requester.Id == uuid.Nil {
+		return ErrNoRequester
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return ErrNoDatabase
+	}
+
+	canEdit, err := RequestCanEditEntity(ctx, requester, worldId)
+	if err != nil {
+		return err
+	}
+
+	if !canEdit {
+		return ErrNoPermission
+	}
+
+	canView, err := RequestCanViewEntity(ctx, requester, appId)
+	if err != nil {
+		return err
+	}
+
+	if !canView {
+		return ErrNoPermission
+	}
+
+	q := `insert into app_worlds_v2 (app_id, world_id, published_by, published_at)
+values ($1, $2, $3, now())
+on conflict (app_id, world_id) do nothing`
+
+	_, err = db.Exec(ctx, q, appId, worldId, requester.Id)
+	if err != nil {
+		return fmt.Errorf("failed to publish world: %w", err)
+	}
+
+	return nil
+}
+
+// UnpublishWorldFromApp removes the world from the app, the world owner or the app owner can remove it
+func UnpublishWorldFromApp(ctx context.Context, requester *User, appId uuid.UUID, worldId uuid.UUID) (err error) {
+	if requester == nil || requester.Id == uuid.Nil {
+		return ErrNoRequester
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return ErrNoDatabase
+	}
+
+	canEdit, err := RequestCanEditEntity(ctx, requester, worldId)
+	if err != nil {
+		return err
+	}
+
+	if !canEdit {
+		canEdit, err = RequestCanEditEntity(ctx, requester, appId)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !canEdit {
+		return ErrNoPermission
+	}
+
+	tag, err := db.Exec(ctx, `delete from app_worlds_v2 where app_id = $1 and world_id = $2`, appId, worldId)
+	if err != nil {
+		return fmt.Errorf("failed to unpublish world: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNoRows
+	}
+
+	return nil
+}
+
+// IndexAppWorlds returns worlds published to the app and visible to the requester, recently published first
+func IndexAppWorlds(ctx context.Context, requester *User, appId uuid.UUID, request BatchRequestMetadata) (batch *WorldBatch, err error) {
+	if requester == nil || requester.Id == uuid.Nil {
+		return nil, ErrNoRequester
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return nil, ErrNoDatabase
+	}
+
+	canView, err := RequestCanViewEntity(ctx, requester, appId)
+	if err != nil {
+		return nil, err
+	}
+
+	if !canView {
+		return nil, ErrNoPermission
+	}
+
+	batch = &WorldBatch{
+		Offset: 0,
+		Limit:  100,
+		Total:  0,
+	}
+
+	if request.Offset > 0 {
+		batch.Offset = request.Offset
+	}
+
+	if request.Limit > 0 && request.Limit <= 100 {
+		batch.Limit = request.Limit
+	}
+
+	var (
+		qArgs   = []any{appId}
+		qArgNum = 1
+	)
+
+	q := ` from app_worlds_v2 aw
+         join spaces w on aw.world_id = w.id
+         join entities e on w.id = e.id`
+
+	if !requester.IsAdmin {
+		qArgNum++
+		qArgs = append(qArgs, requester.Id)
+		q += ` left join accessibles a on e.id = a.entity_id and a.user_id = $` + strconv.Itoa(qArgNum)
+	}
+
+	q += ` where aw.app_id = $1`
+
+	if !requester.IsAdmin {
+		q += ` and (e.public or a.is_owner or a.can_view)`
+	}
+
+	if request.Query != "" {
+		qArgNum++
+		qArgs = append(qArgs, helper.SanitizeLikeClause(request.Query))
+		q += ` and w.name ilike '%' || $` + strconv.Itoa(qArgNum) + ` || '%'`
+	}
+
+	err = db.QueryRow(ctx, `select count(*)`+q, qArgs...).Scan(&batch.Total)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count app worlds: %w", err)
+	}
+
+	q = `select ` + worldColumns + q + ` order by aw.published_at desc offset $` + strconv.Itoa(qArgNum+1) + ` limit $` + strconv.Itoa(qArgNum+2)
+	qArgs = append(qArgs, batch.Offset, batch.Limit)
+
+	rows, err := db.Query(ctx, q, qArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get app worlds: %w", err)
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var w *World
+		w, err = scanWorld(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get app worlds: %w", err)
+		}
+		batch.Entities = append(batch.Entities, *w)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get app worlds: %w", err)
+	}
+
+	return batch, nil
+}
@@ -1,23 +1,777 @@
 package model
 
 import (
+	"context"
+	glContext "dev.hackerman.me/artheon/veverse-shared/context"
 	"fmt"
 	"github.com/gofrs/uuid"
+	"github.com/jackc/pgtype"
+	pgtypeuuid "github.com/jackc/pgtype/ext/gofrs-uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const MaxCommentLength = 4000
+
+const (
+	CommentReportStatusOpen      = "open"
+	CommentReportStatusResolved  = "resolved"  // moderator took action, e.g. hid the comment
+	CommentReportStatusDismissed = "dismissed" // moderator found no violation
 )
 
 type Comment struct {
 	//Entity
 	EntityTrait
+	Timestamps
 
-	UserId uuid.UUID `json:"userId"`
-	Text   string    `json:"text"`
+	UserId    uuid.UUID  `json:"userId"`
+	ParentId  *uuid.UUID `json:"parentId,omitempty"` // comment this comment replies to
+	Text      string     `json:"text"`               // empty if the comment is deleted
+	Hidden    bool       `json:"hidden,omitempty"`   // hidden by a moderator, visible to the author and moderators only
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+	Replies   int32      `json:"replies,omitempty"` // number of visible replies
 }
 
 func (c *Comment) String() string {
 	var out = c.EntityTrait.String()
+	out += c.Timestamps.String()
 	out += fmt.Sprintf("userId: %v, ", c.UserId)
+	if c.ParentId != nil {
+		out += fmt.Sprintf("parentId: %v, ", *c.ParentId)
+	}
 	out += fmt.Sprintf("text: %v, ", c.Text)
+	out += fmt.Sprintf("hidden: %v, ", c.Hidden)
+	if c.DeletedAt != nil {
+		out += fmt.Sprintf("deletedAt: %v, ", *c.DeletedAt)
+	}
+	out += fmt.Sprintf("replies: %v, ", c.Replies)
 	return out
 }
 
 type CommentBatch Batch[Comment]
+
+// CommentVersion is a previous text of an edited comment
+type CommentVersion struct {
+	Text       string    `json:"text"`
+	ReplacedAt time.Time `json:"replacedAt"`
+}
+
+type CommentReport struct {
+	Identifier
+	CommentId  uuid.UUID  `json:"commentId"`
+	UserId     uuid.UUID  `json:"userId"` // reporter
+	Reason     *string    `json:"reason,omitempty"`
+	Status     string     `json:"status"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ResolvedAt *time.Time `json:"resolvedAt,omitempty"`
+	ResolvedBy *uuid.UUID `json:"resolvedBy,omitempty"`
+	Comment    *Comment   `json:"comment,omitempty"`
+}
+
+type CommentReportBatch Batch[CommentReport]
+
+const commentColumns = `c.id,
+       c.entity_id,
+       c.created_at,
+       c.updated_at,
+       c.user_id,
+       c.parent_id,
+       case when c.deleted_at is null then c.text else '' end,
+       c.hidden,
+       c.deleted_at,
+       (select count(*) from comment_v2 r where r.parent_id = c.id and r.deleted_at is null and not r.hidden)::int4`
+
+func scanComment(row pgx.Row) (c *Comment, err error) {
+	var (
+		id, entityId, userId, parentId pgtypeuuid.UUID
+		createdAt, updatedAt           pgtype.Timestamp
+		deletedAt                      pgtype.Timestamp
+		text                           pgtype.Text
+		hidden                         pgtype.Bool
+		replies                        pgtype.Int4
+	)
+
+	err = row.Scan(&id, &entityId, &createdAt, &updatedAt, &userId, &parentId, &text, &hidden, &deletedAt, &replies)
+	if err != nil {
+		return nil, err
+	}
+
+	c = &Comment{}
+	c.Id = id.UUID
+	if entityId.Status == pgtype.Present {
+		c.EntityId = &entityId.UUID
+	}
+	if createdAt.Status == pgtype.Present {
+		c.CreatedAt = createdAt.Time
+	}
+	if updatedAt.Status == pgtype.Present {
+		c.UpdatedAt = &updatedAt.Time
+	}
+	if userId.Status == pgtype.Present {
+		c.UserId = userId.UUID
+	}
+	if parentId.Status == pgtype.Present {
+		c.ParentId = &parentId.UUID
+	}
+	c.Text = text.String
+	c.Hidden = hidden.Bool
+	if deletedAt.Status == pgtype.Present {
+		c.DeletedAt = &deletedAt.Time
+	}
+	c.Replies = replies.Int
+
+	return c, nil
+}
+
+// RequestCanPostComment checks that the requester is allowed to write comments
+func RequestCanPostComment(requester *User) error {
+	if requester.IsBanned {
+		return ErrUserBanned
+	}
+	if requester.IsMuted {
+		return ErrUserMuted
+	}
+	return nil
+}
+
+// requestCanModerateComments reports whether the requester can hide comments of the entity and see hidden ones
+func requestCanModerateComments(ctx context.Context, requester *User, entityId uuid.UUID) (bool, error) {
+	if requester.IsAdmin {
+		return true, nil
+	}
+	return RequestCanEditEntity(ctx, requester, entityId)
+}
+
+// ValidateCommentText returns the trimmed text, the text must not be empty or longer than MaxCommentLength characters
+func ValidateCommentText(text string) (string, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", fmt.Errorf("text is not set")
+	}
+	if utf8.RuneCountInString(text) > MaxCommentLength {
+		return "", fmt.Errorf("text is longer than %d characters", MaxCommentLength)
+	}
+	return text, nil
+}
+
+type CreateCommentRequest struct {
+	ParentId *uuid.UUID `json:"parentId,omitempty"` // comment to reply to (optional)
+	Text     string     `json:"text"`
+}
+
+// CreateComment adds the comment of the requester to the entity, replies must belong to the same entity and hidden
+// comments can not be replied to
+func CreateComment(ctx context.Context, requester *User, entityId uuid.UUID, request CreateCommentRequest) (comment *Comment, err error) {
+	if requester == nil || requester.Id == uuid.Nil {
+		return nil, ErrNoRequester
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return nil, ErrNoDatabase
+	}
+
+	if err = RequestCanPostComment(requester); err != nil {
+		return nil, err
+	}
+
+	if request.Text, err = ValidateCommentText(request.Text); err != nil {
+		return nil, err
+	}
+
+	canView, err := RequestCanViewEntity(ctx, requester, entityId)
+	if err != nil {
+		return nil, err
+	}
+
+	if !canView {
+		return nil, ErrNoPermission
+	}
+
+	if request.ParentId != nil {
+		var (
+			parentEntityId pgtypeuuid.UUID
+			parentHidden   pgtype.Bool
+		)
+		err = db.QueryRow(ctx, `select c.entity_id, c.hidden from comment_v2 c where c.id = $1 and c.deleted_at is null`, *request.ParentId).Scan(&parentEntityId, &parentHidden)
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				return nil, fmt.Errorf("%w: parent comment", ErrNoRows)
+			}
+			return nil, fmt.Errorf("failed to get parent comment: %w", err)
+		}
+
+		if parentEntityId.UUID != entityId {
+			return nil, fmt.Errorf("parent comment belongs to another entity")
+		}
+
+		if parentHidden.Bool {
+			return nil, fmt.Errorf("%w: parent comment is hidden", ErrNoPermission)
+		}
+	}
+
+	q := `insert into comment_v2 (entity_id, user_id, parent_id, text)
+values ($1, $2, $3, $4)
+returning ` + commentColumns
+
+	comment, err = scanComment(db.QueryRow(ctx, q, entityId, requester.Id, request.ParentId, request.Text))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create comment: %w", err)
+	}
+
+	return comment, nil
+}
+
+// getCommentForUpdate locks the comment and returns its entity and author
+func getCommentForUpdate(ctx context.Context, tx pgx.Tx, commentId uuid.UUID) (entityId uuid.UUID, userId uuid.UUID, deleted bool, err error) {
+	var (
+		e, u      pgtypeuuid.UUID
+		deletedAt pgtype.Timestamp
+	)
+
+	err = tx.QueryRow(ctx, `select c.entity_id, c.user_id, c.deleted_at from comment_v2 c where c.id = $1 for update`, commentId).Scan(&e, &u, &deletedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return uuid.Nil, uuid.Nil, false, ErrNoRows
+		}
+		return uuid.Nil, uuid.Nil, false, fmt.Errorf("failed to get comment: %w", err)
+	}
+
+	return e.UUID, u.UUID, deletedAt.Status == pgtype.Present, nil
+}
+
+// UpdateComment replaces the text of the comment of the requester, the previous text is kept in the edit history
+func UpdateComment(ctx context.Context, requester *User, commentId uuid.UUID, text string) (comment *Comment, err error) {
+	if requester == nil || requester.Id == uuid.Nil {
+		return nil, ErrNoRequester
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return nil, ErrNoDatabase
+	}
+
+	if err = RequestCanPostComment(requester); err != nil {
+		return nil, err
+	}
+
+	if text, err = ValidateCommentText(text); err != nil {
+		return nil, err
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	_, userId, deleted, err := getCommentForUpdate(ctx, tx, commentId)
+	if err != nil {
+		return nil, err
+	}
+
+	if userId != requester.Id {
+		return nil, ErrNoPermission
+	}
+
+	if deleted {
+		return nil, ErrNoRows
+	}
+
+	_, err = tx.Exec(ctx, `insert into comment_version_v2 (comment_id, text, created_at) select c.id, c.text, now() from comment_v2 c where c.id = $1 and c.text <> $2`, commentId, text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save comment history: %w", err)
+	}
+
+	comment, err = scanComment(tx.QueryRow(ctx, `update comment_v2 c set text = $2, updated_at = now() where c.id = $1 returning `+commentColumns, commentId, text))
+	if err != nil {
+		return nil, fmt.Errorf("failed to update comment: %w", err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return comment, nil
+}
+
+// DeleteComment soft deletes the comment, replies are kept. Authors can delete their comments, moderators of the entity
+// can delete any comment.
+func DeleteComment(ctx context.Context, requester *User, commentId uuid.UUID) (err error) {
+	if requester == nil || requester.Id == uuid.Nil {
+		return ErrNoRequester
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return ErrNoDatabase
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	entityId, userId, deleted, err := getCommentForUpdate(ctx, tx, commentId)
+	if err != nil {
+		return err
+	}
+
+	if deleted {
+		return nil
+	}
+
+	if userId != requester.Id {
+		canModerate, err := requestCanModerateComments(ctx, requester, entityId)
+		if err != nil {
+			return err
+		}
+
+		if !canModerate {
+			return ErrNoPermission
+		}
+	}
+
+	_, err = tx.Exec(ctx, `update comment_v2 set deleted_at = now() where id = $1`, commentId)
+	if err != nil {
+		return fmt.Errorf("failed to delete comment: %w", err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// HideComment hides or shows the comment, only owners and editors of the commented entity and admins can hide comments
+func HideComment(ctx context.Context, requester *User, commentId uuid.UUID, hidden bool) (err error) {
+	if requester == nil || requester.Id == uuid.Nil {
+		return ErrNoRequester
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return ErrNoDatabase
+	}
+
+	var entityId pgtypeuuid.UUID
+	err = db.QueryRow(ctx, `select c.entity_id from comment_v2 c where c.id = $1`, commentId).Scan(&entityId)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return ErrNoRows
+		}
+		return fmt.Errorf("failed to get comment: %w", err)
+	}
+
+	canModerate, err := requestCanModerateComments(ctx, requester, entityId.UUID)
+	if err != nil {
+		return err
+	}
+
+	if !canModerate {
+		return ErrNoPermission
+	}
+
+	q := `update comment_v2
+set hidden    = $2,
+    hidden_by = case when $2 then $3::uuid end
+where id = $1`
+
+	_, err = db.Exec(ctx, q, commentId, hidden, requester.Id)
+	if err != nil {
+		return fmt.Errorf("failed to hide comment: %w", err)
+	}
+
+	return nil
+}
+
+type IndexCommentsRequest struct {
+	BatchRequestMetadata
+	ParentId *uuid.UUID `json:"parentId,omitempty"` // replies of the comment, top level comments if not set
+}
+
+// IndexComments returns top level comments of the entity, newest first, or replies of the parent comment, oldest first.
+// Hidden comments are returned to their authors and moderators only.
+func IndexComments(ctx context.Context, requester *User, entityId uuid.UUID, request IndexCommentsRequest) (batch *CommentBatch, err error) {
+	if requester == nil || requester.Id == uuid.Nil {
+		return nil, ErrNoRequester
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return nil, ErrNoDatabase
+	}
+
+	canView, err := RequestCanViewEntity(ctx, requester, entityId)
+	if err != nil {
+		return nil, err
+	}
+
+	if !canView {
+		return nil, ErrNoPermission
+	}
+
+	canModerate, err := requestCanModerateComments(ctx, requester, entityId)
+	if err != nil {
+		return nil, err
+	}
+
+	batch = &CommentBatch{
+		Offset: 0,
+		Limit:  100,
+		Total:  0,
+	}
+
+	if request.Offset > 0 {
+		batch.Offset = request.Offset
+	}
+
+	if request.Limit > 0 && request.Limit <= 100 {
+		batch.Limit = request.Limit
+	}
+
+	var (
+		qArgs   = []any{entityId}
+		qArgNum = 1
+		qOrder  string
+	)
+
+	q := ` from comment_v2 c where c.entity_id = $1`
+
+	if request.ParentId != nil {
+		qArgNum++
+		qArgs = append(qArgs, *request.ParentId)
+		q += ` and c.parent_id = $` + strconv.Itoa(qArgNum)
+		qOrder = ` order by c.created_at, c.id`
+	} else {
+		q += ` and c.parent_id is null`
+		qOrder = ` order by c.created_at desc, c.id`
+	}
+
+	if !canModerate {
+		qArgNum++
+		qArgs = append(qArgs, requester.Id)
+		q += ` and (not c.hidden or c.user_id = $` + strconv.Itoa(qArgNum) + `)`
+	}
+
+	err = db.QueryRow(ctx, `select count(*)`+q, qArgs...).Scan(&batch.Total)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count comments: %w", err)
+	}
+
+	q = `select ` + commentColumns + q + qOrder + ` offset $` + strconv.Itoa(qArgNum+1) + ` limit $` + strconv.Itoa(qArgNum+2)
+	qArgs = append(qArgs, batch.Offset, batch.Limit)
+
+	rows, err := db.Query(ctx, q, qArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get comments: %w", err)
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var c *Comment
+		c, err = scanComment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get comments: %w", err)
+		}
+		batch.Entities = append(batch.Entities, *c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get comments: %w", err)
+	}
+
+	return batch, nil
+}
+
+// IndexCommentVersions returns the edit history of the comment, newest first, to the author and moderators
+func IndexCommentVersions(ctx context.Context, requester *User, commentId uuid.UUID) (versions []CommentVersion, err error) {
+	if requester == nil || requester.Id == uuid.Nil {
+		return nil, ErrNoRequester
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return nil, ErrNoDatabase
+	}
+
+	var entityId, userId pgtypeuuid.UUID
+	err = db.QueryRow(ctx, `select c.entity_id, c.user_id from comment_v2 c where c.id = $1`, commentId).Scan(&entityId, &userId)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNoRows
+		}
+		return nil, fmt.Errorf("failed to get comment: %w", err)
+	}
+
+	if userId.UUID != requester.Id {
+		canModerate, err := requestCanModerateComments(ctx, requester, entityId.UUID)
+		if err != nil {
+			return nil, err
+		}
+
+		if !canModerate {
+			return nil, ErrNoPermission
+		}
+	}
+
+	rows, err := db.Query(ctx, `select v.text, v.created_at from comment_version_v2 v where v.comment_id = $1 order by v.created_at desc`, commentId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get comment history: %w", err)
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var v CommentVersion
+		if err = rows.Scan(&v.Text, &v.ReplacedAt); err != nil {
+			return nil, fmt.Errorf("failed to get comment history: %w", err)
+		}
+		versions = append(versions, v)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get comment history: %w", err)
+	}
+
+	return versions, nil
+}
+
+// ReportComment reports the comment to moderators, reporting the same comment again updates the reason of the open
+// report. Resolved and dismissed reports stay closed, so a reporter can not reopen them.
+func ReportComment(ctx context.Context, requester *User, commentId uuid.UUID, reason *string) (err error) {
+	if requester == nil || requester.Id == uuid.Nil {
+		return ErrNoRequester
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return ErrNoDatabase
+	}
+
+	if requester.IsBanned {
+		return ErrUserBanned
+	}
+
+	var entityId pgtypeuuid.UUID
+	err = db.QueryRow(ctx, `select c.entity_id from comment_v2 c where c.id = $1 and c.deleted_at is null`, commentId).Scan(&entityId)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return ErrNoRows
+		}
+		return fmt.Errorf("failed to get comment: %w", err)
+	}
+
+	canView, err := RequestCanViewEntity(ctx, requester, entityId.UUID)
+	if err != nil {
+		return err
+	}
+
+	if !canView {
+		return ErrNoPermission
+	}
+
+	q := `insert into comment_report_v2 (comment_id, user_id, reason, status)
+values ($1, $2, $3, $4)
+on conflict (comment_id, user_id) do update set reason = excluded.reason
+where comment_report_v2.status = $4`
+
+	_, err = db.Exec(ctx, q, commentId, requester.Id, reason, CommentReportStatusOpen)
+	if err != nil {
+		return fmt.Errorf("failed to report comment: %w", err)
+	}
+
+	return nil
+}
+
+type IndexCommentReportsRequest struct {
+	BatchRequestMetadata
+	Status string `json:"status,omitempty"` // status of the reports (default: open)
+}
+
+// IndexCommentReports returns the moderation queue of comment reports with the reported comments, oldest first
+func IndexCommentReports(ctx context.Context, requester *User, request IndexCommentReportsRequest) (batch *CommentReportBatch, err error) {
+	if requester == nil || requester.Id == uuid.Nil {
+		return nil, ErrNoRequester
+	}
+
+	if !requester.IsAdmin {
+		return nil, ErrNoPermission
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return nil, ErrNoDatabase
+	}
+
+	if request.Status == "" {
+		request.Status = CommentReportStatusOpen
+	}
+
+	batch = &CommentReportBatch{
+		Offset: 0,
+		Limit:  100,
+		Total:  0,
+	}
+
+	if request.Offset > 0 {
+		batch.Offset = request.Offset
+	}
+
+	if request.Limit > 0 && request.Limit <= 100 {
+		batch.Limit = request.Limit
+	}
+
+	err = db.QueryRow(ctx, `select count(*) from comment_report_v2 cr where cr.status = $1`, request.Status).Scan(&batch.Total)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count comment reports: %w", err)
+	}
+
+	// deleted comments keep their text for moderators
+	q := `select cr.id,
+       cr.comment_id,
+       cr.user_id,
+       cr.reason,
+       cr.status,
+       cr.created_at,
+       cr.resolved_at,
+       cr.resolved_by,
+       c.id,
+       c.entity_id,
+       c.created_at,
+       c.updated_at,
+       c.user_id,
+       c.parent_id,
+       c.text,
+       c.hidden,
+       c.deleted_at,
+       0::int4
+from comment_report_v2 cr
+         join comment_v2 c on cr.comment_id = c.id
+where cr.status = $1
+order by cr.created_at, cr.id
+offset $2 limit $3`
+
+	rows, err := db.Query(ctx, q, request.Status, batch.Offset, batch.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get comment reports: %w", err)
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			r          CommentReport
+			id         pgtypeuuid.UUID
+			commentId  pgtypeuuid.UUID
+			userId     pgtypeuuid.UUID
+			reason     pgtype.Text
+			status     pgtype.Text
+			createdAt  pgtype.Timestamp
+			resolvedAt pgtype.Timestamp
+			resolvedBy pgtypeuuid.UUID
+		)
+
+		r.Comment, err = scanComment(prefixScanner{rows, []any{&id, &commentId, &userId, &reason, &status, &createdAt, &resolvedAt, &resolvedBy}})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get comment reports: %w", err)
+		}
+
+		r.Id = id.UUID
+		r.CommentId = commentId.UUID
+		r.UserId = userId.UUID
+		if reason.Status == pgtype.Present {
+			r.Reason = &reason.String
+		}
+		r.Status = status.String
+		r.CreatedAt = createdAt.Time
+		if resolvedAt.Status == pgtype.Present {
+			r.ResolvedAt = &resolvedAt.Time
+		}
+		if resolvedBy.Status == pgtype.Present {
+			r.ResolvedBy = &resolvedBy.UUID
+		}
+
+		batch.Entities = append(batch.Entities, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get comment reports: %w", err)
+	}
+
+	return batch, nil
+}
+
+// prefixScanner scans the leading columns into the prefix destinations and the rest into the destinations of Scan
+type prefixScanner struct {
+	row    pgx.Row
+	prefix []any
+}
+
+func (s prefixScanner) Scan(dest ...any) error {
+	return s.row.Scan(append(s.prefix, dest...)...)
+}
+
+// ResolveCommentReports closes all open reports of the comment with the status, the comment is hidden if hide is set
+func ResolveCommentReports(ctx context.Context, requester *User, commentId uuid.UUID, status string, hide bool) (err error) {
+	if requester == nil || requester.Id == uuid.Nil {
+		return ErrNoRequester
+	}
+
+	if !requester.IsAdmin {
+		return ErrNoPermission
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return ErrNoDatabase
+	}
+
+	if status != CommentReportStatusResolved && status != CommentReportStatusDismissed {
+		return fmt.Errorf("invalid report status %q", status)
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	q := `update comment_report_v2
+set status      = $2,
+    resolved_at = now(),
+    resolved_by = $3
+where comment_id = $1
+  and status = $4`
+
+	_, err = tx.Exec(ctx, q, commentId, status, requester.Id, CommentReportStatusOpen)
+	if err != nil {
+		return fmt.Errorf("failed to resolve comment reports: %w", err)
+	}
+
+	if hide {
+		_, err = tx.Exec(ctx, `update comment_v2 set hidden = true, hidden_by = $2 where id = $1`, commentId, requester.Id)
+		if err != nil {
+			return fmt.Errorf("failed to hide comment: %w", err)
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package model
+
+import (
+	"context"
+	glContext "dev.hackerman.me/artheon/veverse-shared/context"
+	"fmt"
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	FileTypeDocument     = "document"      // uploaded document, e.g. PDF
+	FileTypeDocumentPage = "document_page" // page of the document rendered to an image, File.Index is the zero-based page number
+)
+
+// IndexDocumentPages returns rendered pages of the entity document in page order
+func IndexDocumentPages(ctx context.Context, requester *User, entityId uuid.UUID, request BatchRequestMetadata) (batch *FileBatch, err error) {
+	if requester == nil {
+		return nil, ErrNoRequester
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return nil, ErrNoDatabase
+	}
+
+	canView, err := RequestCanViewEntity(ctx, requester, entityId)
+	if err != nil {
+		return nil, err
+	}
+
+	if !canView {
+		return nil, ErrNoPermission
+	}
+
+	batch = &FileBatch{
+		Offset: 0,
+		Limit:  100,
+		Total:  0,
+	}
+
+	if request.Offset > 0 {
+		batch.Offset = request.Offset
+	}
+
+	if request.Limit > 0 && request.Limit <= 100 {
+		batch.Limit = request.Limit
+	}
+
+	err = db.QueryRow(ctx, `select count(*) from files f where f.entity_id = $1 and f.type = $2`, entityId, FileTypeDocumentPage).Scan(&batch.Total)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count document pages: %w", err)
+	}
+
+	q := `select ` + fileColumns + `
+from files f
+where f.entity_id = $1
+  and f.type = $2
+order by f.variation
+offset $3 limit $4`
+
+	rows, err := db.Query(ctx, q, entityId, FileTypeDocumentPage, batch.Offset, batch.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get document pages: %w", err)
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var f *File
+		f, err = scanFile(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get document pages: %w", err)
+		}
+		batch.Entities = append(batch.Entities, *f)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get document pages: %w", err)
+	}
+
+	return batch, nil
+}
+
+// TrimDocumentPages deletes pages left from a previous version of the document with more pages
+func TrimDocumentPages(ctx context.Context, requester *User, entityId uuid.UUID, pageCount int) (err error) {
+	if requester == nil {
+		return ErrNoRequester
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return ErrNoDatabase
+	}
+
+	canEdit, err := RequestCanEditEntity(ctx, requester, entityId)
+	if err != nil {
+		return err
+	}
+
+	if !canEdit {
+		return ErrNoPermission
+	}
+
+	_, err = db.Exec(ctx, `delete from files where entity_id = $1 and type = $2 and variation >= $3`, entityId, FileTypeDocumentPage, pageCount)
+	if err != nil {
+		return fmt.Errorf("failed to delete document pages: %w", err)
+	}
+
+	return nil
+}
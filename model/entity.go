@@ -138,7 +138,7 @@ func RequestCanViewEntity(ctx context.Context, requester *User, id uuid.UUID) (b
 		rows pgx.Rows
 	)
 
-	q = `SELECT a.is_owner, can_view, public FROM entities e LEFT JOIN accessibles a ON e.id = a.entity_id WHERE e.id = $1 AND a.user_id = $2`
+	q = `SELECT coalesce(a.is_owner, false), coalesce(a.can_view, false), e.public FROM entities e LEFT JOIN accessibles a ON e.id = a.entity_id AND a.user_id = $2 WHERE e.id = $1`
 	rows, err := db.Query(ctx, q, id, requester.Id)
 	if err != nil {
 		return false, err
@@ -180,7 +180,7 @@ func RequestCanEditEntity(ctx context.Context, requester *User, id uuid.UUID) (b
 		rows pgx.Rows
 	)
 
-	q = `SELECT a.is_owner, can_edit, public FROM entities e LEFT JOIN accessibles a ON e.id = a.entity_id WHERE e.id = $1 AND a.user_id = $2`
+	q = `SELECT coalesce(a.is_owner, false), coalesce(a.can_edit, false) FROM entities e LEFT JOIN accessibles a ON e.id = a.entity_id AND a.user_id = $2 WHERE e.id = $1`
 	rows, err := db.Query(ctx, q, id, requester.Id)
 	if err != nil {
 		return false, err
@@ -221,7 +221,7 @@ func RequestCanDeleteEntity(ctx context.Context, requester *User, id uuid.UUID)
 		rows pgx.Rows
 	)
 
-	q = `SELECT a.is_owner, can_delete, public FROM entities e LEFT JOIN accessibles a ON e.id = a.entity_id WHERE e.id = $1 AND a.user_id = $2`
+	q = `SELECT coalesce(a.is_owner, false), coalesce(a.can_delete, false) FROM entities e LEFT JOIN accessibles a ON e.id = a.entity_id AND a.user_id = $2 WHERE e.id = $1`
 	rows, err := db.Query(ctx, q, id, requester.Id)
 	if err != nil {
 		return false, err
@@ -11,4 +11,16 @@ var (
 	ErrPlayerNotConnected     = errors.New("player not connected to server")
 	ErrPlayerAlreadyConnected = errors.New("player already connected to server")
 	ErrNoFreeSlots            = errors.New("no free slots on server")
+	ErrNoRegion               = errors.New("no region available")
+	ErrNoFreeInstance         = errors.New("no free pixel streaming instance")
+	ErrInvalidInstanceStatus  = errors.New("invalid instance status")
+	ErrReleaseVersionExists   = errors.New("release version already exists")
+	ErrNoReleaseChannel       = errors.New("no release channel")
+	ErrNoFile                 = errors.New("no file")
+	ErrNoPackage              = errors.New("no package")
+	ErrDependencyCycle        = errors.New("dependency cycle")
+	ErrUserMuted              = errors.New("user is muted")
+	ErrUserBanned             = errors.New("user is banned")
+	ErrInvalidProperty        = errors.New("invalid property")
+	ErrRequiredProperty       = errors.New("property is required")
 )
@@ -1,8 +1,14 @@
 package model
 
 import (
+	"context"
+	glContext "dev.hackerman.me/artheon/veverse-shared/context"
 	"fmt"
 	"github.com/gofrs/uuid"
+	pgtypeuuid "github.com/jackc/pgtype/ext/gofrs-uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"strconv"
 )
 
 type UploadImageType int64
@@ -14,6 +20,23 @@ const (
 	ImageTexture
 )
 
+const (
+	FileTypeImageFull    = "image_full"
+	FileTypeImagePreview = "image_preview"
+	FileTypeImageTexture = "image_texture" // power-of-two version of the image for Unreal
+)
+
+// FileType returns the type of the file of the image variant
+func (t UploadImageType) FileType() string {
+	switch t {
+	case ImagePreview:
+		return FileTypeImagePreview
+	case ImageTexture:
+		return FileTypeImageTexture
+	}
+	return FileTypeImageFull
+}
+
 // File trait for the Entity
 type File struct {
 	EntityTrait
@@ -105,6 +128,7 @@ type FileLinkRequestMetadata struct {
 	Height       int     `json:"height,omitempty" query:"height"`                        // Height of the media surface (optional), usually set for multimedia files (images and videos)
 	Index        int64   `json:"index,omitempty" query:"index"`                          // Index of the file (for file arrays such as PDF pages rendered to images)
 	OriginalPath string  `json:"originalPath,omitempty" query:"original-path,omitempty"` // Original path of the file (to be re-downloaded to the correct location, used by app release files)
+	Hash         *string `json:"hash,omitempty" query:"hash"`                            // Hash of the file content (optional), used to verify downloads and installed files
 }
 
 type FileUploadRequestMetadata struct {
@@ -117,6 +141,8 @@ type FileUploadRequestMetadata struct {
 	Height       int     `json:"height,omitempty" query:"height"`                        // Height of the media surface (optional), usually set for multimedia files (images and videos)
 	Index        int64   `json:"index,omitempty" query:"index"`                          // Index of the file (for file arrays such as PDF pages rendered to images)
 	OriginalPath string  `json:"originalPath,omitempty" query:"original-path,omitempty"` // Original path of the file (to be re-downloaded to the correct location, used by app release files)
+	Size         *int    `json:"size,omitempty" query:"size"`                            // Size of the file (optional), used to verify the upload
+	Hash         *string `json:"hash,omitempty" query:"hash"`                            // Hash of the file content (optional), used to verify downloads and installed files
 }
 
 type FileUploadLinkRequestMetadata struct {
@@ -139,3 +165,497 @@ type FileDownloadRequestMetadata struct {
 	EntityId string `json:"entityId,omitempty"` // SupportedPlatform (OS) of the pak file (Win64, Mac, Linux, IOS, Android)
 	FileId   string `json:"fileId,omitempty"`   // SupportedPlatform (OS) of the pak file (Win64, Mac, Linux, IOS, Android)
 }
+
+// DefaultFileMime is the mime type of files linked without one
+const DefaultFileMime = "binary/octet-stream"
+
+const fileColumns = `f.id,
+       f.entity_id,
+       f.type,
+       f.url,
+       f.mime,
+       f.size,
+       f.version,
+       f.deployment_type,
+       f.platform,
+       f.uploaded_by,
+       f.width,
+       f.height,
+       f.created_at,
+       f.updated_at,
+       f.variation,
+       f.original_path,
+       f.hash`
+
+func scanFile(row pgx.Row) (f *File, err error) {
+	f = &File{}
+	err = row.Scan(&f.Id, &f.EntityId, &f.Type, &f.Url, &f.Mime, &f.Size, &f.Version, &f.Deployment, &f.Platform, &f.UploadedBy, &f.Width, &f.Height, &f.CreatedAt, &f.UpdatedAt, &f.Index, &f.OriginalPath, &f.Hash)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// LinkFile links the file to the entity. A file with the same type, platform, deployment, index and original path is
+// replaced, its version is incremented and the previous version is kept in the file history.
+func LinkFile(ctx context.Context, requester *User, entityId uuid.UUID, metadata FileLinkRequestMetadata) (file *File, err error) {
+	if requester == nil {
+		return nil, ErrNoRequester
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return nil, ErrNoDatabase
+	}
+
+	if entityId.IsNil() {
+		return nil, fmt.Errorf("entity id is not set")
+	}
+
+	if metadata.Type == "" {
+		return nil, fmt.Errorf("file type is not set")
+	}
+
+	if metadata.Url == "" {
+		return nil, fmt.Errorf("file url is not set")
+	}
+
+	canEdit, err := RequestCanEditEntity(ctx, requester, entityId)
+	if err != nil {
+		return nil, err
+	}
+
+	if !canEdit {
+		return nil, ErrNoPermission
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	// the lookup below locks nothing if the file does not exist yet, so concurrent links of the same file are
+	// serialized by the entity and type to avoid duplicate current files
+	_, err = tx.Exec(ctx, `select pg_advisory_xact_lock(hashtext('files:' || $1::text || ':' || $2))`, entityId, metadata.Type)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock files: %w", err)
+	}
+
+	q := `select f.id
+from files f
+where f.entity_id = $1
+  and f.type = $2
+  and coalesce(f.platform, '') = $3
+  and coalesce(f.deployment_type, '') = $4
+  and coalesce(f.variation, 0) = $5
+  and coalesce(f.original_path, '') = $6
+order by f.created_at desc
+limit 1
+for update`
+
+	var fileId pgtypeuuid.UUID
+	err = tx.QueryRow(ctx, q, entityId, metadata.Type, metadata.Platform, metadata.Deployment, metadata.Index, metadata.OriginalPath).Scan(&fileId)
+	if err != nil && err != pgx.ErrNoRows {
+		return nil, fmt.Errorf("failed to get file: %w", err)
+	}
+
+	if err == nil {
+		file, err = replaceFileTx(ctx, tx, requester, fileId.UUID, metadata)
+	} else {
+		file, err = insertFileTx(ctx, tx, requester, entityId, metadata)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return file, nil
+}
+
+// ReplaceFile replaces the content of the file, the version is incremented and the previous version is kept in the file
+// history. Type, platform, deployment, index and original path of the file do not change.
+func ReplaceFile(ctx context.Context, requester *User, fileId uuid.UUID, metadata FileLinkRequestMetadata) (file *File, err error) {
+	if requester == nil {
+		return nil, ErrNoRequester
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return nil, ErrNoDatabase
+	}
+
+	if metadata.Url == "" {
+		return nil, fmt.Errorf("file url is not set")
+	}
+
+	entityId, err := getFileEntityId(ctx, db, fileId)
+	if err != nil {
+		return nil, err
+	}
+
+	canEdit, err := RequestCanEditEntity(ctx, requester, entityId)
+	if err != nil {
+		return nil, err
+	}
+
+	if !canEdit {
+		return nil, ErrNoPermission
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	_, err = tx.Exec(ctx, `select id from files where id = $1 for update`, fileId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock file: %w", err)
+	}
+
+	file, err = replaceFileTx(ctx, tx, requester, fileId, metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return file, nil
+}
+
+func insertFileTx(ctx context.Context, tx pgx.Tx, requester *User, entityId uuid.UUID, metadata FileLinkRequestMetadata) (file *File, err error) {
+	var (
+		mime    = DefaultFileMime
+		size    *int64
+		version = metadata.Version
+	)
+
+	if metadata.Mime != nil && *metadata.Mime != "" {
+		mime = *metadata.Mime
+	}
+
+	if metadata.Size != nil {
+		s := int64(*metadata.Size)
+		size = &s
+	}
+
+	if version < 1 {
+		version = 1
+	}
+
+	q := `insert into files as f (id, entity_id, type, url, mime, size, version, deployment_type, platform, uploaded_by, width, height, created_at, updated_at, variation, original_path, hash)
+values (gen_random_uuid(), $1, $2, $3, $4, $5, $6, $7, $8, $9, nullif($10, 0), nullif($11, 0), now(), null, $12, $13, $14)
+returning ` + fileColumns
+
+	file, err = scanFile(tx.QueryRow(ctx, q, entityId, metadata.Type, metadata.Url, mime, size, version, metadata.Deployment, metadata.Platform, requester.Id, metadata.Width, metadata.Height, metadata.Index, metadata.OriginalPath, metadata.Hash))
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert file: %w", err)
+	}
+
+	return file, nil
+}
+
+func replaceFileTx(ctx context.Context, tx pgx.Tx, requester *User, fileId uuid.UUID, metadata FileLinkRequestMetadata) (file *File, err error) {
+	q := `insert into file_version (file_id, version, url, mime, size, width, height, hash, uploaded_by, created_at)
+select f.id, f.version, f.url, f.mime, f.size, f.width, f.height, f.hash, f.uploaded_by, coalesce(f.updated_at, f.created_at)
+from files f
+where f.id = $1
+on conflict (file_id, version) do nothing`
+
+	_, err = tx.Exec(ctx, q, fileId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to keep file version: %w", err)
+	}
+
+	var size *int64
+	if metadata.Size != nil {
+		s := int64(*metadata.Size)
+		size = &s
+	}
+
+	// the mime type is kept if not set, the same as on insert an empty mime type is replaced with the default
+	mime := metadata.Mime
+	if mime != nil && *mime == "" {
+		m := DefaultFileMime
+		mime = &m
+	}
+
+	q = `update files f
+set url         = $2,
+    mime        = coalesce($3, f.mime),
+    size        = $4,
+    width       = nullif($5, 0),
+    height      = nullif($6, 0),
+    hash        = $7,
+    uploaded_by = $8,
+    version     = greatest(f.version + 1, $9),
+    updated_at  = now()
+where f.id = $1
+returning ` + fileColumns
+
+	file, err = scanFile(tx.QueryRow(ctx, q, fileId, metadata.Url, mime, size, metadata.Width, metadata.Height, metadata.Hash, requester.Id, metadata.Version))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNoFile
+		}
+		return nil, fmt.Errorf("failed to replace file: %w", err)
+	}
+
+	return file, nil
+}
+
+// IndexEntityFiles returns current versions of the files of the entity filtered by type, platform and deployment
+func IndexEntityFiles(ctx context.Context, requester *User, entityId uuid.UUID, request FileBatchRequestMetadata) (batch *FileBatch, err error) {
+	if requester == nil {
+		return nil, ErrNoRequester
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return nil, ErrNoDatabase
+	}
+
+	canView, err := RequestCanViewEntity(ctx, requester, entityId)
+	if err != nil {
+		return nil, err
+	}
+
+	if !canView {
+		return nil, ErrNoPermission
+	}
+
+	batch = &FileBatch{
+		Offset: 0,
+		Limit:  100,
+		Total:  0,
+	}
+
+	if request.Offset > 0 {
+		batch.Offset = request.Offset
+	}
+
+	if request.Limit > 0 && request.Limit <= 100 {
+		batch.Limit = request.Limit
+	}
+
+	var (
+		qWhere  = ` where f.entity_id = $1`
+		qArgs   = []any{entityId}
+		qArgNum = 1
+	)
+
+	if request.Type != "" {
+		qArgNum++
+		qArgs = append(qArgs, request.Type)
+		qWhere += ` and f.type = $` + strconv.Itoa(qArgNum)
+	}
+
+	if request.Platform != "" {
+		qArgNum++
+		qArgs = append(qArgs, request.Platform)
+		qWhere += ` and f.platform = $` + strconv.Itoa(qArgNum)
+	}
+
+	if request.Deployment != "" {
+		qArgNum++
+		qArgs = append(qArgs, request.Deployment)
+		qWhere += ` and f.deployment_type = $` + strconv.Itoa(qArgNum)
+	}
+
+	err = db.QueryRow(ctx, `select count(*) from files f`+qWhere, qArgs...).Scan(&batch.Total)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count files: %w", err)
+	}
+
+	q := `select ` + fileColumns + ` from files f` + qWhere
+	q += ` order by f.type, f.platform, f.deployment_type, f.variation, f.original_path, f.created_at offset $` + strconv.Itoa(qArgNum+1) + ` limit $` + strconv.Itoa(qArgNum+2)
+	qArgs = append(qArgs, batch.Offset, batch.Limit)
+
+	rows, err := db.Query(ctx, q, qArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get files: %w", err)
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var f *File
+		f, err = scanFile(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get files: %w", err)
+		}
+		batch.Entities = append(batch.Entities, *f)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get files: %w", err)
+	}
+
+	return batch, nil
+}
+
+// IndexFileVersions returns previous versions of the file, newest first
+func IndexFileVersions(ctx context.Context, requester *User, fileId uuid.UUID) (files []File, err error) {
+	if requester == nil {
+		return nil, ErrNoRequester
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return nil, ErrNoDatabase
+	}
+
+	entityId, err := getFileEntityId(ctx, db, fileId)
+	if err != nil {
+		return nil, err
+	}
+
+	canView, err := RequestCanViewEntity(ctx, requester, entityId)
+	if err != nil {
+		return nil, err
+	}
+
+	if !canView {
+		return nil, ErrNoPermission
+	}
+
+	rows, err := db.Query(ctx, fileVersionQuery+` order by v.version desc`, fileId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file versions: %w", err)
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var f *File
+		f, err = scanFile(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get file versions: %w", err)
+		}
+		files = append(files, *f)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get file versions: %w", err)
+	}
+
+	return files, nil
+}
+
+// GetFileVersion returns the version of the file, either current or previous
+func GetFileVersion(ctx context.Context, requester *User, fileId uuid.UUID, version int64) (file *File, err error) {
+	if requester == nil {
+		return nil, ErrNoRequester
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return nil, ErrNoDatabase
+	}
+
+	entityId, err := getFileEntityId(ctx, db, fileId)
+	if err != nil {
+		return nil, err
+	}
+
+	canView, err := RequestCanViewEntity(ctx, requester, entityId)
+	if err != nil {
+		return nil, err
+	}
+
+	if !canView {
+		return nil, ErrNoPermission
+	}
+
+	q := `select ` + fileColumns + ` from files f where f.id = $1 and f.version = $2
+union all
+` + fileVersionQuery + ` and v.version = $2
+limit 1`
+
+	file, err = scanFile(db.QueryRow(ctx, q, fileId, version))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNoFile
+		}
+		return nil, fmt.Errorf("failed to get file version: %w", err)
+	}
+
+	return file, nil
+}
+
+// fileVersionQuery selects previous versions of the file $1 with the file columns, the version was replaced at updated_at
+const fileVersionQuery = `select f.id,
+       f.entity_id,
+       f.type,
+       v.url,
+       v.mime,
+       v.size,
+       v.version,
+       f.deployment_type,
+       f.platform,
+       v.uploaded_by,
+       v.width,
+       v.height,
+       v.created_at,
+       v.replaced_at,
+       f.variation,
+       f.original_path,
+       v.hash
+from file_version v
+         inner join files f on f.id = v.file_id
+where v.file_id = $1`
+
+// DeleteFile deletes the file with all its versions
+func DeleteFile(ctx context.Context, requester *User, fileId uuid.UUID) (err error) {
+	if requester == nil {
+		return ErrNoRequester
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return ErrNoDatabase
+	}
+
+	entityId, err := getFileEntityId(ctx, db, fileId)
+	if err != nil {
+		return err
+	}
+
+	canEdit, err := RequestCanEditEntity(ctx, requester, entityId)
+	if err != nil {
+		return err
+	}
+
+	if !canEdit {
+		return ErrNoPermission
+	}
+
+	_, err = db.Exec(ctx, `delete from files where id = $1`, fileId)
+	if err != nil {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+
+	return nil
+}
+
+func getFileEntityId(ctx context.Context, db *pgxpool.Pool, fileId uuid.UUID) (uuid.UUID, error) {
+	var entityId pgtypeuuid.UUID
+	err := db.QueryRow(ctx, `select entity_id from files where id = $1`, fileId).Scan(&entityId)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return uuid.Nil, ErrNoFile
+		}
+		return uuid.Nil, fmt.Errorf("failed to get file: %w", err)
+	}
+	return entityId.UUID, nil
+}
@@ -1020,6 +1020,7 @@ where e.id = $2
 
 type FindGameServerV2Args struct {
 	RegionId   uuid.UUID
+	Pings      []RegionPing // used to select the best region if the region id is not set
 	ReleaseId  uuid.UUID
 	WorldId    uuid.UUID
 	GameModeId *uuid.UUID
@@ -1039,6 +1040,11 @@ func FindGameServerV2(ctx context.Context, requester *User, args FindGameServerV
 		return
 	}
 
+	args.RegionId, err = resolveGameServerV2Region(ctx, requester, args.RegionId, args.Pings, args.Type)
+	if err != nil {
+		return
+	}
+
 	var (
 		q    string
 		rows pgx.Rows
@@ -1595,17 +1601,25 @@ func UpdateGameServerV2Status(ctx context.Context, requester *User, args UpdateG
 
 // MatchGameServerV2Args contains the arguments for matching a game server.
 type MatchGameServerV2Args struct {
-	RegionId   uuid.UUID  `json:"regionId"`             // required for official servers
-	ReleaseId  uuid.UUID  `json:"releaseId"`            // required
-	WorldId    uuid.UUID  `json:"worldId"`              // required
-	GameModeId *uuid.UUID `json:"gameModeId,omitempty"` // optional
-	Type       string     `json:"type"`                 // "official" or "community"
+	RegionId   uuid.UUID    `json:"regionId"`             // required for official servers, selected using pings if not set
+	Pings      []RegionPing `json:"pings,omitempty"`      // optional client-reported region latencies
+	ReleaseId  uuid.UUID    `json:"releaseId"`            // required
+	WorldId    uuid.UUID    `json:"worldId"`              // required
+	GameModeId *uuid.UUID   `json:"gameModeId,omitempty"` // optional
+	Type       string       `json:"type"`                 // "official" or "community"
 }
 
 // MatchGameServerV2 returns a game server that matches the given criteria or creates a new one.
 //
 //goland:noinspection GoUnusedExportedFunction
 func MatchGameServerV2(ctx context.Context, requester *User, args MatchGameServerV2Args) (e *GameServerV2, created bool, err error) {
+	// Resolve the region once, so the found and the created servers are in the same region.
+	args.RegionId, err = resolveGameServerV2Region(ctx, requester, args.RegionId, args.Pings, args.Type)
+	if err != nil {
+		err = errors.Wrap(err, "failed to match game server")
+		return
+	}
+
 	findArgs := FindGameServerV2Args{
 		RegionId:   args.RegionId,
 		ReleaseId:  args.ReleaseId,
@@ -1836,3 +1850,26 @@ func RemovePlayerFromGameServerV2(ctx context.Context, requester *User, args Rem
 
 	return
 }
+
+// resolveGameServerV2Region returns the region id if set, otherwise selects the best region for the requester if pings
+// are reported or the server is official. Community servers have no region, so without pings the nil region id is
+// returned and the region filter is not applied, the same as if there are no enabled regions.
+func resolveGameServerV2Region(ctx context.Context, requester *User, regionId uuid.UUID, pings []RegionPing, serverType string) (uuid.UUID, error) {
+	if !regionId.IsNil() {
+		return regionId, nil
+	}
+
+	if len(pings) == 0 && serverType != GameServerTypeOfficial {
+		return uuid.Nil, nil
+	}
+
+	region, err := SelectRegion(ctx, requester, SelectRegionRequest{Pings: pings})
+	if err != nil {
+		if err == ErrNoRegion {
+			return uuid.Nil, nil
+		}
+		return uuid.Nil, errors.Wrap(err, "failed to select region")
+	}
+
+	return region.Id, nil
+}
@@ -1,8 +1,13 @@
 package model
 
 import (
+	"context"
+	glContext "dev.hackerman.me/artheon/veverse-shared/context"
 	"fmt"
 	"github.com/gofrs/uuid"
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 // Likable entity trait
@@ -26,6 +31,107 @@ func (l *Likable) String() string {
 type LikableBatch Batch[Likable]
 
 type Rating struct {
-	TotalLikes    int32 `json:"likes"`
-	TotalDislikes int32 `json:"dislikes"`
+	TotalLikes    int32  `json:"likes"`
+	TotalDislikes int32  `json:"dislikes"`
+	Liked         *int32 `json:"liked,omitempty"` // value of the requester, 1 for like, -1 for dislike, not set if not rated
+}
+
+// LikeEntity sets the like of the requester, liking an entity again does nothing
+func LikeEntity(ctx context.Context, requester *User, entityId uuid.UUID) (rating *Rating, err error) {
+	return rateEntity(ctx, requester, entityId, 1)
+}
+
+// DislikeEntity sets the dislike of the requester, replacing the like if there is one
+func DislikeEntity(ctx context.Context, requester *User, entityId uuid.UUID) (rating *Rating, err error) {
+	return rateEntity(ctx, requester, entityId, -1)
+}
+
+// UnrateEntity clears the like or dislike of the requester
+func UnrateEntity(ctx context.Context, requester *User, entityId uuid.UUID) (rating *Rating, err error) {
+	return rateEntity(ctx, requester, entityId, 0)
+}
+
+// rateEntity sets the value of the requester, the entity like and dislike counters are maintained by the likables
+// trigger. Each user has at most one rating of the entity, rating it again with the same value keeps the timestamps.
+func rateEntity(ctx context.Context, requester *User, entityId uuid.UUID, value int8) (rating *Rating, err error) {
+	if requester == nil || requester.Id == uuid.Nil {
+		return nil, ErrNoRequester
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return nil, ErrNoDatabase
+	}
+
+	canView, err := RequestCanViewEntity(ctx, requester, entityId)
+	if err != nil {
+		return nil, err
+	}
+
+	if !canView {
+		return nil, ErrNoPermission
+	}
+
+	if value == 0 {
+		_, err = db.Exec(ctx, `delete from likables where entity_id = $1 and user_id = $2`, entityId, requester.Id)
+	} else {
+		q := `insert into likables (id, entity_id, user_id, value, created_at)
+values (gen_random_uuid(), $1, $2, $3, now())
+on conflict (entity_id, user_id) do update set value      = excluded.value,
+                                               updated_at = now()
+where likables.value != excluded.value`
+		_, err = db.Exec(ctx, q, entityId, requester.Id, value)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to rate entity: %w", err)
+	}
+
+	return GetRating(ctx, requester, entityId)
+}
+
+// GetRating returns the like and dislike counters of the entity and the value of the requester
+func GetRating(ctx context.Context, requester *User, entityId uuid.UUID) (rating *Rating, err error) {
+	if requester == nil || requester.Id == uuid.Nil {
+		return nil, ErrNoRequester
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return nil, ErrNoDatabase
+	}
+
+	canView, err := RequestCanViewEntity(ctx, requester, entityId)
+	if err != nil {
+		return nil, err
+	}
+
+	if !canView {
+		return nil, ErrNoPermission
+	}
+
+	q := `select e.likes,
+       e.dislikes,
+       (select l.value from likables l where l.entity_id = e.id and l.user_id = $2)
+from entities e
+where e.id = $1`
+
+	var (
+		likes, dislikes pgtype.Int4
+		liked           pgtype.Int4
+	)
+
+	err = db.QueryRow(ctx, q, entityId, requester.Id).Scan(&likes, &dislikes, &liked)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNoRows
+		}
+		return nil, fmt.Errorf("failed to get rating: %w", err)
+	}
+
+	rating = &Rating{TotalLikes: likes.Int, TotalDislikes: dislikes.Int}
+	if liked.Status == pgtype.Present {
+		rating.Liked = &liked.Int
+	}
+
+	return rating, nil
 }
@@ -1,9 +1,26 @@
 package model
 
 import (
+	"context"
+	glContext "dev.hackerman.me/artheon/veverse-shared/context"
+	"dev.hackerman.me/artheon/veverse-shared/helper"
+	"dev.hackerman.me/artheon/veverse-shared/semver"
+	"fmt"
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgtype"
+	pgtypeuuid "github.com/jackc/pgtype/ext/gofrs-uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"strconv"
+	"strings"
 	"time"
 )
 
+const (
+	FileTypePak             = "pak"               // package content for the platform and deployment of the file
+	FileTypePakExtraContent = "pak-extra-content" // additional package content shared by all platforms
+)
+
 type Package struct {
 	Entity
 
@@ -20,8 +37,13 @@ type Package struct {
 	Liked         *int32     `json:"liked,omitempty"`
 	TotalLikes    *int32     `json:"totalLikes,omitempty"`
 	TotalDislikes *int32     `json:"totalDislikes,omitempty"`
+
+	SdkConstraint string              `json:"sdkConstraint,omitempty"` // semver range of compatible SDK release code versions (e.g. "^1.2"), any if empty
+	Dependencies  []PackageDependency `json:"dependencies,omitempty"`  // packages mounted before this package, in mount order
 }
 
+type PackageBatch Batch[Package]
+
 // PackageBatchRequestMetadata Batch request metadata for requesting Package entities
 type PackageBatchRequestMetadata struct {
 	BatchRequestMetadata
@@ -44,6 +66,8 @@ type PackageCreateMetadata struct {
 	Release     string  `json:"releaseName,omitempty"` // Release
 	Map         *string `json:"map,omitempty"`         // Map (list of maps included into the package)
 	Version     *string `json:"version,omitempty"`     // Version of the package
+
+	SdkConstraint *string `json:"sdkConstraint,omitempty"` // Semver range of compatible SDK release code versions
 }
 
 type PackageUpdateMetadata struct {
@@ -55,4 +79,544 @@ type PackageUpdateMetadata struct {
 	Release     *string `json:"releaseName,omitempty"` // Release
 	Map         *string `json:"map,omitempty"`         // Map (list of maps included into the package)
 	Version     *string `json:"version,omitempty"`     // Version of the package
+
+	SdkConstraint *string `json:"sdkConstraint,omitempty"` // Semver range of compatible SDK release code versions, empty to allow any
+}
+
+const packageColumns = `e.id,
+       e.created_at,
+       e.updated_at,
+       e.entity_type,
+       e.views,
+       e.public,
+       ou.id,
+       ou.name,
+       pk.name,
+       pk.title,
+       pk.summary,
+       pk.description,
+       pk.map,
+       pk.release_name,
+       pk.price::float8,
+       pk.version,
+       pk.released_at,
+       pk.downloads,
+       pk.sdk_constraint`
+
+// packageFrom joins the package entity and its first owner
+const packageFrom = ` from mods pk
+         left join entities e on pk.id = e.id
+         left join lateral (select oa.user_id from accessibles oa where oa.entity_id = e.id and oa.is_owner limit 1) o on true
+         left join users ou on o.user_id = ou.id`
+
+func scanPackage(row pgx.Row) (p *Package, err error) {
+	var (
+		id                   pgtypeuuid.UUID
+		createdAt, updatedAt pgtype.Timestamptz
+		entityType           pgtype.Text
+		views                pgtype.Int4
+		public               pgtype.Bool
+		ownerId              pgtypeuuid.UUID
+		ownerName            pgtype.Text
+		name                 pgtype.Text
+		title                pgtype.Text
+		summary              pgtype.Text
+		description          pgtype.Text
+		packageMap           pgtype.Text
+		release              pgtype.Text
+		price                pgtype.Float8
+		version              pgtype.Text
+		releasedAt           pgtype.Timestamp
+		downloads            pgtype.Int4
+		sdkConstraint        pgtype.Text
+	)
+
+	err = row.Scan(&id, &createdAt, &updatedAt, &entityType, &views, &public, &ownerId, &ownerName, &name, &title, &summary, &description, &packageMap, &release, &price, &version, &releasedAt, &downloads, &sdkConstraint)
+	if err != nil {
+		return nil, err
+	}
+
+	p = &Package{}
+	p.Id = id.UUID
+	if createdAt.Status == pgtype.Present {
+		p.CreatedAt = createdAt.Time
+	}
+	if updatedAt.Status == pgtype.Present {
+		p.UpdatedAt = &updatedAt.Time
+	}
+	if entityType.Status == pgtype.Present {
+		p.EntityType = entityType.String
+	}
+	if views.Status == pgtype.Present {
+		p.Views = views.Int
+	}
+	if public.Status == pgtype.Present {
+		p.Public = public.Bool
+	}
+	if ownerId.Status == pgtype.Present {
+		p.Owner = &User{}
+		p.Owner.Id = ownerId.UUID
+		if ownerName.Status == pgtype.Present {
+			p.Owner.Name = &ownerName.String
+		}
+	}
+	if name.Status == pgtype.Present {
+		p.Name = name.String
+	}
+	if title.Status == pgtype.Present {
+		p.Title = title.String
+	}
+	if summary.Status == pgtype.Present {
+		p.Summary = summary.String
+	}
+	if description.Status == pgtype.Present {
+		p.Description = description.String
+	}
+	if packageMap.Status == pgtype.Present {
+		p.Map = packageMap.String
+	}
+	if release.Status == pgtype.Present {
+		p.Release = release.String
+	}
+	if price.Status == pgtype.Present {
+		p.Price = &price.Float
+	}
+	if version.Status == pgtype.Present {
+		p.Version = version.String
+	}
+	if releasedAt.Status == pgtype.Present {
+		p.ReleasedAt = &releasedAt.Time
+	}
+	if downloads.Status == pgtype.Present {
+		p.Downloads = &downloads.Int
+	}
+	if sdkConstraint.Status == pgtype.Present {
+		p.SdkConstraint = sdkConstraint.String
+	}
+
+	return p, nil
+}
+
+// CreatePackage creates the package owned by the requester, the package is not released until it is published
+func CreatePackage(ctx context.Context, requester *User, metadata PackageCreateMetadata) (pkg *Package, err error) {
+	if requester == nil || requester.Id == uuid.Nil {
+		return nil, ErrNoRequester
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return nil, ErrNoDatabase
+	}
+
+	if metadata.Name == "" {
+		return nil, fmt.Errorf("name is not set")
+	}
+
+	if err = validateSdkConstraint(metadata.SdkConstraint); err != nil {
+		return nil, err
+	}
+
+	public := true
+	if metadata.Public != nil {
+		public = *metadata.Public
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	q := `with e as (insert into entities (id, entity_type, public) values (gen_random_uuid(), 'mod', $1) returning id)
+insert
+into mods (id, name, title, summary, description, map, release_name, version, sdk_constraint)
+select e.id, $2, $3, $4, $5, $6, $7, $8, nullif($9, '')
+from e
+returning id`
+
+	var id pgtypeuuid.UUID
+	err = tx.QueryRow(ctx, q, public, metadata.Name, metadata.Title, metadata.Summary, metadata.Description, metadata.Map, metadata.Release, metadata.Version, metadata.SdkConstraint).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create package: %w", err)
+	}
+
+	q = `insert into accessibles (entity_id, user_id, is_owner, can_view, can_edit, can_delete) values ($1, $2, true, true, true, true)`
+	_, err = tx.Exec(ctx, q, id.UUID, requester.Id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set package owner: %w", err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return GetPackage(ctx, requester, PackageRequestMetadata{IdRequestMetadata: IdRequestMetadata{Id: id.UUID}})
+}
+
+// UpdatePackage updates the fields of the package set in the metadata
+func UpdatePackage(ctx context.Context, requester *User, id uuid.UUID, metadata PackageUpdateMetadata) (pkg *Package, err error) {
+	if requester == nil || requester.Id == uuid.Nil {
+		return nil, ErrNoRequester
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return nil, ErrNoDatabase
+	}
+
+	canEdit, err := RequestCanEditEntity(ctx, requester, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !canEdit {
+		return nil, ErrNoPermission
+	}
+
+	if metadata.Name != nil && *metadata.Name == "" {
+		return nil, fmt.Errorf("name is not set")
+	}
+
+	if err = validateSdkConstraint(metadata.SdkConstraint); err != nil {
+		return nil, err
+	}
+
+	var (
+		qSet    []string
+		qArgs   = []any{id}
+		qArgNum = 1
+	)
+
+	columns := []struct {
+		name  string
+		value *string
+	}{
+		{"name", metadata.Name},
+		{"title", metadata.Title},
+		{"summary", metadata.Summary},
+		{"description", metadata.Description},
+		{"release_name", metadata.Release},
+		{"map", metadata.Map},
+		{"version", metadata.Version},
+	}
+
+	for _, c := range columns {
+		if c.value != nil {
+			qArgNum++
+			qArgs = append(qArgs, *c.value)
+			qSet = append(qSet, c.name+` = $`+strconv.Itoa(qArgNum))
+		}
+	}
+
+	if metadata.SdkConstraint != nil {
+		qArgNum++
+		qArgs = append(qArgs, *metadata.SdkConstraint)
+		qSet = append(qSet, `sdk_constraint = nullif($`+strconv.Itoa(qArgNum)+`, '')`)
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	if len(qSet) > 0 {
+		_, err = tx.Exec(ctx, `update mods set `+strings.Join(qSet, ", ")+` where id = $1`, qArgs...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update package: %w", err)
+		}
+	}
+
+	if metadata.Public != nil {
+		_, err = tx.Exec(ctx, `update entities set public = $2, updated_at = now() where id = $1`, id, *metadata.Public)
+	} else {
+		_, err = tx.Exec(ctx, `update entities set updated_at = now() where id = $1`, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update package entity: %w", err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return GetPackage(ctx, requester, PackageRequestMetadata{IdRequestMetadata: IdRequestMetadata{Id: id}})
+}
+
+// PublishPackage stamps the release time of the package, the package must have at least one pak file
+func PublishPackage(ctx context.Context, requester *User, id uuid.UUID) (pkg *Package, err error) {
+	if requester == nil || requester.Id == uuid.Nil {
+		return nil, ErrNoRequester
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return nil, ErrNoDatabase
+	}
+
+	canEdit, err := RequestCanEditEntity(ctx, requester, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !canEdit {
+		return nil, ErrNoPermission
+	}
+
+	var hasPak bool
+	err = db.QueryRow(ctx, `select exists(select 1 from files f where f.entity_id = $1 and f.type = $2)`, id, FileTypePak).Scan(&hasPak)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get package files: %w", err)
+	}
+
+	if !hasPak {
+		return nil, fmt.Errorf("%w: package has no pak files", ErrNoFile)
+	}
+
+	_, err = db.Exec(ctx, `update mods set released_at = now() where id = $1`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to publish package: %w", err)
+	}
+
+	return GetPackage(ctx, requester, PackageRequestMetadata{IdRequestMetadata: IdRequestMetadata{Id: id}})
+}
+
+// IndexPackages returns packages visible to the requester, unpublished packages are returned to their owners and editors
+// only. If the platform or deployment is set, only packages having
+// a pak file for them are returned
+func IndexPackages(ctx context.Context, requester *User, request PackageBatchRequestMetadata) (batch *PackageBatch, err error) {
+	if requester == nil || requester.Id == uuid.Nil {
+		return nil, ErrNoRequester
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return nil, ErrNoDatabase
+	}
+
+	batch = &PackageBatch{
+		Offset: 0,
+		Limit:  100,
+		Total:  0,
+	}
+
+	if request.Offset > 0 {
+		batch.Offset = request.Offset
+	}
+
+	if request.Limit > 0 && request.Limit <= 100 {
+		batch.Limit = request.Limit
+	}
+
+	var (
+		qJoin   string
+		qWhere  []string
+		qArgs   []any
+		qArgNum = 0
+	)
+
+	if !requester.IsAdmin {
+		qArgNum++
+		qArgs = append(qArgs, requester.Id)
+		qJoin = ` left join accessibles a on e.id = a.entity_id and a.user_id = $` + strconv.Itoa(qArgNum)
+		// unpublished packages are visible to their editors only
+		qWhere = append(qWhere, `(a.is_owner or a.can_edit or (pk.released_at is not null and (e.public or a.can_view)))`)
+	}
+
+	if request.Query != "" {
+		qArgNum++
+		qArgs = append(qArgs, helper.SanitizeLikeClause(request.Query))
+		qWhere = append(qWhere, `(pk.name ilike '%' || $`+strconv.Itoa(qArgNum)+` || '%' or pk.title ilike '%' || $`+strconv.Itoa(qArgNum)+` || '%')`)
+	}
+
+	if request.Platform != "" || request.Deployment != "" {
+		qArgNum++
+		qArgs = append(qArgs, FileTypePak)
+		qPak := `exists(select 1 from files f where f.entity_id = e.id and f.type = $` + strconv.Itoa(qArgNum)
+		if request.Platform != "" {
+			qArgNum++
+			qArgs = append(qArgs, request.Platform)
+			qPak += ` and f.platform = $` + strconv.Itoa(qArgNum)
+		}
+		if request.Deployment != "" {
+			qArgNum++
+			qArgs = append(qArgs, request.Deployment)
+			qPak += ` and f.deployment_type = $` + strconv.Itoa(qArgNum)
+		}
+		qWhere = append(qWhere, qPak+`)`)
+	}
+
+	q := packageFrom + qJoin
+	if len(qWhere) > 0 {
+		q += ` where ` + strings.Join(qWhere, ` and `)
+	}
+
+	err = db.QueryRow(ctx, `select count(*)`+q, qArgs...).Scan(&batch.Total)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count packages: %w", err)
+	}
+
+	q = `select ` + packageColumns + q + ` order by pk.released_at desc nulls last, e.created_at desc offset $` + strconv.Itoa(qArgNum+1) + ` limit $` + strconv.Itoa(qArgNum+2)
+	qArgs = append(qArgs, batch.Offset, batch.Limit)
+
+	rows, err := db.Query(ctx, q, qArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get packages: %w", err)
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var p *Package
+		p, err = scanPackage(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get packages: %w", err)
+		}
+		batch.Entities = append(batch.Entities, *p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get packages: %w", err)
+	}
+
+	return batch, nil
+}
+
+// GetPackage returns the package with its pak files in Entity.Files, filtered by the platform and deployment if they
+// are set, and the extra content files shared by all platforms. Unpublished packages are returned to their editors only.
+func GetPackage(ctx context.Context, requester *User, request PackageRequestMetadata) (pkg *Package, err error) {
+	if requester == nil || requester.Id == uuid.Nil {
+		return nil, ErrNoRequester
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return nil, ErrNoDatabase
+	}
+
+	canView, err := RequestCanViewEntity(ctx, requester, request.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !canView {
+		return nil, ErrNoPermission
+	}
+
+	pkg, err = scanPackage(db.QueryRow(ctx, `select `+packageColumns+packageFrom+` where pk.id = $1`, request.Id))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNoRows
+		}
+		return nil, fmt.Errorf("failed to get package: %w", err)
+	}
+
+	if pkg.ReleasedAt == nil {
+		canEdit, err := RequestCanEditEntity(ctx, requester, request.Id)
+		if err != nil {
+			return nil, err
+		}
+
+		if !canEdit {
+			return nil, ErrNoPermission
+		}
+	}
+
+	var (
+		qArgs   = []any{request.Id, FileTypePak, FileTypePakExtraContent}
+		qArgNum = 3
+	)
+
+	q := `select ` + fileColumns + ` from files f where f.entity_id = $1 and (f.type = $3 or (f.type = $2`
+	if request.Platform != "" {
+		qArgNum++
+		qArgs = append(qArgs, request.Platform)
+		q += ` and f.platform = $` + strconv.Itoa(qArgNum)
+	}
+	if request.Deployment != "" {
+		qArgNum++
+		qArgs = append(qArgs, request.Deployment)
+		q += ` and f.deployment_type = $` + strconv.Itoa(qArgNum)
+	}
+	q += `)) order by f.type, f.platform, f.deployment_type, f.original_path`
+
+	rows, err := db.Query(ctx, q, qArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get package files: %w", err)
+	}
+
+	defer rows.Close()
+	pkg.Files = &FileBatch{}
+	for rows.Next() {
+		var f *File
+		f, err = scanFile(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get package files: %w", err)
+		}
+		pkg.Files.Entities = append(pkg.Files.Entities, *f)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get package files: %w", err)
+	}
+
+	pkg.Files.Limit = int64(len(pkg.Files.Entities))
+	pkg.Files.Total = uint64(len(pkg.Files.Entities))
+
+	pkg.Dependencies, err = getPackageDependencies(ctx, db, pkg.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	return pkg, nil
+}
+
+// IncrementPackageDownloads counts a download of the published package by the requester, each user is counted once
+func IncrementPackageDownloads(ctx context.Context, requester *User, id uuid.UUID) (err error) {
+	if requester == nil || requester.Id == uuid.Nil {
+		return ErrNoRequester
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return ErrNoDatabase
+	}
+
+	canView, err := RequestCanViewEntity(ctx, requester, id)
+	if err != nil {
+		return err
+	}
+
+	if !canView {
+		return ErrNoPermission
+	}
+
+	q := `with d as (insert into mod_download_v2 (mod_id, user_id)
+    select pk.id, $2 from mods pk where pk.id = $1 and pk.released_at is not null
+    on conflict do nothing
+    returning mod_id)
+update mods
+set downloads = downloads + 1
+where id in (select mod_id from d)`
+	_, err = db.Exec(ctx, q, id, requester.Id)
+	if err != nil {
+		return fmt.Errorf("failed to count package download: %w", err)
+	}
+
+	return nil
+}
+
+func validateSdkConstraint(constraint *string) error {
+	if constraint == nil || *constraint == "" {
+		return nil
+	}
+	if _, err := semver.ParseConstraint(*constraint); err != nil {
+		return fmt.Errorf("invalid sdk constraint: %w", err)
+	}
+	return nil
 }
@@ -0,0 +1,408 @@
+package model
+
+import (
+	"context"
+	glContext "dev.hackerman.me/artheon/veverse-shared/context"
+	"dev.hackerman.me/artheon/veverse-shared/semver"
+	"fmt"
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgtype"
+	pgtypeuuid "github.com/jackc/pgtype/ext/gofrs-uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"sort"
+	"strings"
+)
+
+// PackageDependency is a package required by another package
+type PackageDependency struct {
+	PackageId  uuid.UUID `json:"packageId"`            // required package
+	Constraint string    `json:"constraint,omitempty"` // semver range of compatible package versions (e.g. "^1.2"), any if empty
+}
+
+type PakConflictKind string
+
+const (
+	PakConflictMissingPackage PakConflictKind = "missing-package" // package does not exist or is not visible to the requester
+	PakConflictCycle          PakConflictKind = "cycle"           // package depends on itself through its dependencies
+	PakConflictVersion        PakConflictKind = "version"         // dependency version does not satisfy the constraint
+	PakConflictSdk            PakConflictKind = "sdk"             // client release code version does not satisfy the sdk constraint
+	PakConflictFile           PakConflictKind = "file"            // two packages mount a file with the same path
+)
+
+// PakConflict describes a problem preventing the package from being mounted as resolved
+type PakConflict struct {
+	Kind         PakConflictKind `json:"kind"`
+	PackageId    uuid.UUID       `json:"packageId"`
+	DependencyId *uuid.UUID      `json:"dependencyId,omitempty"` // conflicting package for dependency and file conflicts
+	Platform     string          `json:"platform,omitempty"`
+	Path         string          `json:"path,omitempty"`
+	Message      string          `json:"message"`
+}
+
+// MissingPak is a package without a pak file for the platform
+type MissingPak struct {
+	PackageId uuid.UUID `json:"packageId"`
+	Platform  string    `json:"platform"`
+}
+
+// PakResolution is the result of the package dependency resolution
+type PakResolution struct {
+	Packages  []uuid.UUID       `json:"packages"`            // packages in mount order, dependencies before dependent packages
+	Platforms map[string][]File `json:"platforms"`           // pak and extra content files of each platform in mount order
+	Conflicts []PakConflict     `json:"conflicts,omitempty"` // problems found in the dependency graph
+	Missing   []MissingPak      `json:"missing,omitempty"`   // packages without builds for some of the platforms
+}
+
+// Ok reports whether the packages can be mounted on all requested platforms
+func (r *PakResolution) Ok() bool {
+	return len(r.Conflicts) == 0 && len(r.Missing) == 0
+}
+
+// ResolvePaks orders the root package and its dependencies so that dependencies are mounted first and collects their
+// pak files for each platform. Packages must contain their dependencies and pak files of all platforms. Dependency and
+// sdk constraints are checked against package versions and the client release code version if it is set. If no
+// platforms are set, the platforms of the root package pak files are used.
+func ResolvePaks(rootId uuid.UUID, packages map[uuid.UUID]*Package, codeVersion string, platforms []string, deployment string) *PakResolution {
+	r := &PakResolution{Platforms: map[string][]File{}}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+
+	state := map[uuid.UUID]int{}
+
+	var visit func(id uuid.UUID)
+	visit = func(id uuid.UUID) {
+		if state[id] == visited {
+			return
+		}
+
+		if state[id] == visiting {
+			r.Conflicts = append(r.Conflicts, PakConflict{Kind: PakConflictCycle, PackageId: id, Message: "package depends on itself"})
+			return
+		}
+
+		p, ok := packages[id]
+		if !ok || p == nil {
+			state[id] = visited
+			r.Conflicts = append(r.Conflicts, PakConflict{Kind: PakConflictMissingPackage, PackageId: id, Message: "package not found"})
+			return
+		}
+
+		state[id] = visiting
+		for _, d := range p.Dependencies {
+			dependencyId := d.PackageId
+			if dp, ok := packages[dependencyId]; ok && dp != nil && d.Constraint != "" {
+				if satisfied, err := semver.Satisfies(dp.Version, d.Constraint); err != nil || !satisfied {
+					r.Conflicts = append(r.Conflicts, PakConflict{
+						Kind:         PakConflictVersion,
+						PackageId:    id,
+						DependencyId: &dependencyId,
+						Message:      fmt.Sprintf("dependency version %q does not satisfy %q", dp.Version, d.Constraint),
+					})
+				}
+			}
+			visit(dependencyId)
+		}
+		state[id] = visited
+
+		if p.SdkConstraint != "" && codeVersion != "" {
+			if satisfied, err := semver.Satisfies(codeVersion, p.SdkConstraint); err != nil || !satisfied {
+				r.Conflicts = append(r.Conflicts, PakConflict{
+					Kind:      PakConflictSdk,
+					PackageId: id,
+					Message:   fmt.Sprintf("release code version %q does not satisfy %q", codeVersion, p.SdkConstraint),
+				})
+			}
+		}
+
+		r.Packages = append(r.Packages, id)
+	}
+
+	visit(rootId)
+
+	if len(platforms) == 0 {
+		if root, ok := packages[rootId]; ok && root != nil {
+			platforms = pakPlatforms(root)
+		}
+	}
+
+	for _, platform := range platforms {
+		var (
+			files = make([]File, 0)
+			paths = map[string]uuid.UUID{}
+		)
+
+		for _, id := range r.Packages {
+			var paks, extras []File
+			if p := packages[id]; p.Files != nil {
+				for _, f := range p.Files.Entities {
+					if f.Type == FileTypePak && f.Platform == platform && (deployment == "" || f.Deployment == deployment) {
+						paks = append(paks, f)
+					} else if f.Type == FileTypePakExtraContent {
+						extras = append(extras, f)
+					}
+				}
+			}
+
+			if len(paks) == 0 {
+				r.Missing = append(r.Missing, MissingPak{PackageId: id, Platform: platform})
+			}
+
+			for _, f := range append(paks, extras...) {
+				if f.OriginalPath != nil && *f.OriginalPath != "" {
+					// pak paths are case-insensitive on Windows
+					path := strings.ToLower(*f.OriginalPath)
+					if owner, ok := paths[path]; ok && owner != id {
+						r.Conflicts = append(r.Conflicts, PakConflict{
+							Kind:         PakConflictFile,
+							PackageId:    id,
+							DependencyId: &owner,
+							Platform:     platform,
+							Path:         *f.OriginalPath,
+							Message:      "file is mounted by another package",
+						})
+					} else {
+						paths[path] = id
+					}
+				}
+				files = append(files, f)
+			}
+		}
+
+		r.Platforms[platform] = files
+	}
+
+	return r
+}
+
+func pakPlatforms(p *Package) []string {
+	if p.Files == nil {
+		return nil
+	}
+
+	var (
+		platforms []string
+		seen      = map[string]bool{}
+	)
+
+	for _, f := range p.Files.Entities {
+		if f.Type == FileTypePak && f.Platform != "" && !seen[f.Platform] {
+			seen[f.Platform] = true
+			platforms = append(platforms, f.Platform)
+		}
+	}
+
+	sort.Strings(platforms)
+	return platforms
+}
+
+type ResolveWorldPaksRequest struct {
+	WorldId    uuid.UUID `json:"worldId"`
+	ReleaseId  uuid.UUID `json:"releaseId,omitempty"`  // client release used to check sdk constraints (optional)
+	Platforms  []string  `json:"platforms,omitempty"`  // platforms to resolve (default: platforms of the world package)
+	Deployment string    `json:"deployment,omitempty"` // SupportedDeployment of the pak files (Server or Client)
+}
+
+// ResolveWorldPaks resolves the package of the world and its dependencies, packages not visible to the requester are
+// reported as missing
+func ResolveWorldPaks(ctx context.Context, requester *User, request ResolveWorldPaksRequest) (resolution *PakResolution, err error) {
+	if requester == nil || requester.Id == uuid.Nil {
+		return nil, ErrNoRequester
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return nil, ErrNoDatabase
+	}
+
+	canView, err := RequestCanViewEntity(ctx, requester, request.WorldId)
+	if err != nil {
+		return nil, err
+	}
+
+	if !canView {
+		return nil, ErrNoPermission
+	}
+
+	var modId pgtypeuuid.UUID
+	err = db.QueryRow(ctx, `select w.mod_id from spaces w where w.id = $1`, request.WorldId).Scan(&modId)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNoRows
+		}
+		return nil, fmt.Errorf("failed to get world: %w", err)
+	}
+
+	if modId.Status != pgtype.Present {
+		return nil, ErrNoPackage
+	}
+
+	var codeVersion string
+	if !request.ReleaseId.IsNil() {
+		release, err := GetReleaseV2(ctx, requester, request.ReleaseId)
+		if err != nil {
+			return nil, err
+		}
+		if release == nil {
+			return nil, ErrNoRows
+		}
+		codeVersion = release.CodeVersion
+	}
+
+	packages := map[uuid.UUID]*Package{}
+	queue := []uuid.UUID{modId.UUID}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+
+		if _, ok := packages[id]; ok {
+			continue
+		}
+
+		p, err := GetPackage(ctx, requester, PackageRequestMetadata{IdRequestMetadata: IdRequestMetadata{Id: id}, Deployment: request.Deployment})
+		if err == ErrNoPermission || err == ErrNoRows {
+			packages[id] = nil
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		packages[id] = p
+		for _, d := range p.Dependencies {
+			queue = append(queue, d.PackageId)
+		}
+	}
+
+	return ResolvePaks(modId.UUID, packages, codeVersion, request.Platforms, request.Deployment), nil
+}
+
+// SetPackageDependencies replaces dependencies of the package, dependencies are mounted in the order of the list
+func SetPackageDependencies(ctx context.Context, requester *User, id uuid.UUID, dependencies []PackageDependency) (pkg *Package, err error) {
+	if requester == nil || requester.Id == uuid.Nil {
+		return nil, ErrNoRequester
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return nil, ErrNoDatabase
+	}
+
+	canEdit, err := RequestCanEditEntity(ctx, requester, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !canEdit {
+		return nil, ErrNoPermission
+	}
+
+	seen := map[uuid.UUID]bool{}
+	for _, d := range dependencies {
+		if d.PackageId == id {
+			return nil, fmt.Errorf("%w: package depends on itself", ErrDependencyCycle)
+		}
+
+		if seen[d.PackageId] {
+			return nil, fmt.Errorf("duplicate dependency %s", d.PackageId)
+		}
+		seen[d.PackageId] = true
+
+		if d.Constraint != "" {
+			if _, err = semver.ParseConstraint(d.Constraint); err != nil {
+				return nil, fmt.Errorf("invalid dependency constraint: %w", err)
+			}
+		}
+
+		canView, err := RequestCanViewEntity(ctx, requester, d.PackageId)
+		if err != nil {
+			return nil, err
+		}
+
+		if !canView {
+			return nil, ErrNoPermission
+		}
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	// serialize dependency updates, otherwise concurrent updates of two packages could each pass the cycle check
+	_, err = tx.Exec(ctx, `select pg_advisory_xact_lock(hashtext('mod_dependency'))`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock package dependencies: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `delete from mod_dependency where mod_id = $1`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete package dependencies: %w", err)
+	}
+
+	// union instead of union all stops the recursion on cycles inserted bypassing this check
+	q := `with recursive d(id) as (select md.dependency_id
+                              from mod_dependency md
+                              where md.mod_id = $1
+                              union
+                              select md.dependency_id
+                              from mod_dependency md
+                                       join d on md.mod_id = d.id)
+select exists(select 1 from d where d.id = $2)`
+
+	for i, d := range dependencies {
+		var cycle bool
+		err = tx.QueryRow(ctx, q, d.PackageId, id).Scan(&cycle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check package dependencies: %w", err)
+		}
+
+		if cycle {
+			return nil, fmt.Errorf("%w: %s depends on the package", ErrDependencyCycle, d.PackageId)
+		}
+
+		_, err = tx.Exec(ctx, `insert into mod_dependency (mod_id, dependency_id, version_range, position) values ($1, $2, nullif($3, ''), $4)`, id, d.PackageId, d.Constraint, i)
+		if err != nil {
+			return nil, fmt.Errorf("failed to add package dependency: %w", err)
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return GetPackage(ctx, requester, PackageRequestMetadata{IdRequestMetadata: IdRequestMetadata{Id: id}})
+}
+
+func getPackageDependencies(ctx context.Context, db *pgxpool.Pool, id uuid.UUID) (dependencies []PackageDependency, err error) {
+	rows, err := db.Query(ctx, `select md.dependency_id, md.version_range from mod_dependency md where md.mod_id = $1 order by md.position, md.created_at`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get package dependencies: %w", err)
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			dependencyId pgtypeuuid.UUID
+			constraint   pgtype.Text
+		)
+
+		if err = rows.Scan(&dependencyId, &constraint); err != nil {
+			return nil, fmt.Errorf("failed to get package dependencies: %w", err)
+		}
+
+		dependencies = append(dependencies, PackageDependency{PackageId: dependencyId.UUID, Constraint: constraint.String})
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get package dependencies: %w", err)
+	}
+
+	return dependencies, nil
+}
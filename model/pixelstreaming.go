@@ -1,34 +1,927 @@
 package model
 
 import (
+	"context"
+	glContext "dev.hackerman.me/artheon/veverse-shared/context"
+	"errors"
+	"fmt"
 	"github.com/gofrs/uuid"
+	"github.com/jackc/pgtype"
+	pgtypeuuid "github.com/jackc/pgtype/ext/gofrs-uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"strconv"
+	"time"
 )
 
+// PixelStreamingInstanceStatus enum
+const (
+	PixelStreamingInstanceStatusFree        = "free"        // instance is registered and waiting for a session
+	PixelStreamingInstanceStatusBusy        = "busy"        // instance is allocated to a session
+	PixelStreamingInstanceStatusError       = "error"       // instance reported an error, will be quarantined
+	PixelStreamingInstanceStatusQuarantined = "quarantined" // instance is excluded from the allocation until it is registered again
+)
+
+// PixelStreamingSessionStatus enum
+const (
+	PixelStreamingSessionStatusActive  = "active"  // session is allocated to an instance
+	PixelStreamingSessionStatusEnded   = "ended"   // session has been ended by the user or the service
+	PixelStreamingSessionStatusExpired = "expired" // session has been idle for too long
+)
+
+// ValidPixelStreamingInstanceStatuses List of statuses an instance can report (for API request validation)
+var ValidPixelStreamingInstanceStatuses = []string{
+	PixelStreamingInstanceStatusFree,
+	PixelStreamingInstanceStatusBusy,
+	PixelStreamingInstanceStatusError,
+}
+
 type PixelStreamingInstance struct {
 	Identifier
-	ReleaseId    *uuid.UUID `json:"releaseId,omitempty"`
-	RegionId     *uuid.UUID `json:"regionId,omitempty"`
-	Host         string     `json:"host,omitempty"`
-	Port         uint16     `json:"port,omitempty"`
-	Status       string     `json:"status,omitempty"`
-	InstanceType string     `json:"instanceType,omitempty"`
+	Timestamps
+	ReleaseId     *uuid.UUID `json:"releaseId,omitempty"`
+	RegionId      *uuid.UUID `json:"regionId,omitempty"`
+	Host          string     `json:"host,omitempty"`
+	Port          uint16     `json:"port,omitempty"`
+	Status        string     `json:"status,omitempty"`
+	StatusMessage string     `json:"statusMessage,omitempty"`
+	InstanceType  string     `json:"instanceType,omitempty"`
 }
 
+type PixelStreamingInstanceBatch Batch[PixelStreamingInstance]
+
 type PixelStreamingSession struct {
 	Identifier
 
-	InstanceId *uuid.UUID `json:"instanceId"`
-	AppId      *uuid.UUID `json:"appId"`
-	WorldId    *uuid.UUID `json:"worldId"`
-	Status     string     `json:"status"`
+	InstanceId *uuid.UUID              `json:"instanceId"`
+	Instance   *PixelStreamingInstance `json:"instance,omitempty"`
+	UserId     *uuid.UUID              `json:"userId,omitempty"`
+	AppId      *uuid.UUID              `json:"appId"`
+	WorldId    *uuid.UUID              `json:"worldId"`
+	Status     string                  `json:"status"`
 
 	Timestamps
 }
 
 type PixelStreamingSessionData struct {
-	Id           *uuid.UUID `json:"id,omitempty"`
-	InstanceType string     `json:"instanceType,omitempty"`
-	AppId        *uuid.UUID `json:"appId,omitempty"`
-	WorldId      *uuid.UUID `json:"worldId,omitempty"`
-	Status       string     `json:"status,omitempty"`
+	Id           *uuid.UUID   `json:"id,omitempty"`
+	InstanceType string       `json:"instanceType,omitempty"`
+	AppId        *uuid.UUID   `json:"appId,omitempty"`
+	WorldId      *uuid.UUID   `json:"worldId,omitempty"`
+	RegionId     *uuid.UUID   `json:"regionId,omitempty"` // region of the instance, selected using pings if not set
+	Pings        []RegionPing `json:"pings,omitempty"`    // client-reported region latencies
+	Status       string       `json:"status,omitempty"`
+}
+
+// SelectPixelStreamingRegion sets the session region to the best region for the requester unless it is already set
+func SelectPixelStreamingRegion(ctx context.Context, requester *User, data *PixelStreamingSessionData) error {
+	if data == nil {
+		return fmt.Errorf("no session data")
+	}
+
+	if data.RegionId != nil && !data.RegionId.IsNil() {
+		return nil
+	}
+
+	region, err := SelectRegion(ctx, requester, SelectRegionRequest{Pings: data.Pings})
+	if err != nil {
+		return fmt.Errorf("failed to select pixel streaming region: %w", err)
+	}
+
+	data.RegionId = &region.Id
+	return nil
+}
+
+type RegisterPixelStreamingInstanceRequest struct {
+	ReleaseId    uuid.UUID  `json:"releaseId"`          // release running on the instance (required)
+	RegionId     *uuid.UUID `json:"regionId,omitempty"` // region of the instance (optional)
+	Host         string     `json:"host"`               // public host of the instance (required)
+	Port         uint16     `json:"port"`               // public port of the instance (required)
+	InstanceType string     `json:"instanceType"`       // instance type (e.g. GPU class) used to match sessions (optional)
+}
+
+// RegisterPixelStreamingInstance adds the instance to the pool or resets the already registered instance with the same
+// host and port. Sessions still active on the re-registered instance are ended.
+func RegisterPixelStreamingInstance(ctx context.Context, requester *User, request RegisterPixelStreamingInstanceRequest) (instance *PixelStreamingInstance, err error) {
+	if requester == nil {
+		return nil, ErrNoRequester
+	}
+
+	if !requester.IsAdmin && !requester.IsInternal {
+		return nil, ErrNoPermission
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return nil, ErrNoDatabase
+	}
+
+	if request.ReleaseId.IsNil() {
+		return nil, fmt.Errorf("release id is not set")
+	}
+
+	if request.Host == "" || request.Port == 0 {
+		return nil, fmt.Errorf("host and port are required")
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	q := `insert into pixel_streaming_instance (id, created_at, release_id, region_id, host, port, instance_type, status)
+values (gen_random_uuid(), now(), $1, $2, $3, $4, $5, $6)
+on conflict (host, port) do update set release_id     = excluded.release_id,
+                                       region_id      = excluded.region_id,
+                                       instance_type  = excluded.instance_type,
+                                       status         = excluded.status,
+                                       status_message = null,
+                                       updated_at     = now()
+returning id`
+
+	var id pgtypeuuid.UUID
+	err = tx.QueryRow(ctx, q, request.ReleaseId, request.RegionId, request.Host, int32(request.Port), request.InstanceType, PixelStreamingInstanceStatusFree).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to register pixel streaming instance: %w", err)
+	}
+
+	q = `update pixel_streaming_session set status = $1, updated_at = now() where instance_id = $2 and status = $3`
+	_, err = tx.Exec(ctx, q, PixelStreamingSessionStatusEnded, id.UUID, PixelStreamingSessionStatusActive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to end pixel streaming instance sessions: %w", err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	advancePixelStreamingQueue(ctx, db)
+
+	return GetPixelStreamingInstance(ctx, requester, id.UUID)
+}
+
+// UnregisterPixelStreamingInstance removes the instance from the pool and ends its active sessions.
+func UnregisterPixelStreamingInstance(ctx context.Context, requester *User, id uuid.UUID) (err error) {
+	if requester == nil {
+		return ErrNoRequester
+	}
+
+	if !requester.IsAdmin && !requester.IsInternal {
+		return ErrNoPermission
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return ErrNoDatabase
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	q := `update pixel_streaming_session set status = $1, updated_at = now() where instance_id = $2 and status = $3`
+	_, err = tx.Exec(ctx, q, PixelStreamingSessionStatusEnded, id, PixelStreamingSessionStatusActive)
+	if err != nil {
+		return fmt.Errorf("failed to end pixel streaming instance sessions: %w", err)
+	}
+
+	tag, err := tx.Exec(ctx, `delete from pixel_streaming_instance where id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to unregister pixel streaming instance: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNoRows
+	}
+
+	return tx.Commit(ctx)
+}
+
+type UpdatePixelStreamingInstanceStatusRequest struct {
+	Id            uuid.UUID `json:"id"`
+	Status        string    `json:"status"`
+	StatusMessage string    `json:"statusMessage,omitempty"`
+}
+
+// UpdatePixelStreamingInstanceStatus updates the instance status, also used by instances as a heartbeat. Only the error
+// status is applied, free and busy are controlled by AllocatePixelStreamingInstance and EndPixelStreamingSession.
+// Quarantined instances keep their status until they are registered again and return ErrInvalidInstanceStatus.
+func UpdatePixelStreamingInstanceStatus(ctx context.Context, requester *User, request UpdatePixelStreamingInstanceStatusRequest) (err error) {
+	if requester == nil {
+		return ErrNoRequester
+	}
+
+	if !requester.IsAdmin && !requester.IsInternal {
+		return ErrNoPermission
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return ErrNoDatabase
+	}
+
+	var found = false
+	for _, s := range ValidPixelStreamingInstanceStatuses {
+		if s == request.Status {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return ErrInvalidInstanceStatus
+	}
+
+	// free and busy are set by the allocation and the end of the session, reporting them only refreshes the heartbeat,
+	// otherwise a heartbeat sent right after the allocation would free an instance with an active session
+	q := `update pixel_streaming_instance
+set status         = case when $1 = $5 then $1 else status end,
+    status_message = $2,
+    updated_at     = now()
+where id = $3
+  and status != $4`
+	tag, err := db.Exec(ctx, q, request.Status, request.StatusMessage, request.Id, PixelStreamingInstanceStatusQuarantined, PixelStreamingInstanceStatusError)
+	if err != nil {
+		return fmt.Errorf("failed to update pixel streaming instance status: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		var status pgtype.Text
+		err = db.QueryRow(ctx, `select status from pixel_streaming_instance where id = $1`, request.Id).Scan(&status)
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				return ErrNoRows
+			}
+			return fmt.Errorf("failed to get pixel streaming instance status: %w", err)
+		}
+		return ErrInvalidInstanceStatus
+	}
+
+	return nil
+}
+
+// QuarantinePixelStreamingInstance excludes the instance from the allocation and ends its active sessions.
+func QuarantinePixelStreamingInstance(ctx context.Context, requester *User, id uuid.UUID, message string) (err error) {
+	if requester == nil {
+		return ErrNoRequester
+	}
+
+	if !requester.IsAdmin && !requester.IsInternal {
+		return ErrNoPermission
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return ErrNoDatabase
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	q := `update pixel_streaming_instance set status = $1, status_message = $2, updated_at = now() where id = $3`
+	tag, err := tx.Exec(ctx, q, PixelStreamingInstanceStatusQuarantined, message, id)
+	if err != nil {
+		return fmt.Errorf("failed to quarantine pixel streaming instance: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNoRows
+	}
+
+	q = `update pixel_streaming_session set status = $1, updated_at = now() where instance_id = $2 and status = $3`
+	_, err = tx.Exec(ctx, q, PixelStreamingSessionStatusEnded, id, PixelStreamingSessionStatusActive)
+	if err != nil {
+		return fmt.Errorf("failed to end pixel streaming instance sessions: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+// QuarantineStalePixelStreamingInstances quarantines instances stuck in a bad state: instances that reported an error,
+// busy instances without an active session and instances that have not sent a heartbeat within the timeout.
+// Returns the number of quarantined instances.
+func QuarantineStalePixelStreamingInstances(ctx context.Context, requester *User, timeout time.Duration) (quarantined int64, err error) {
+	if requester == nil {
+		return 0, ErrNoRequester
+	}
+
+	if !requester.IsAdmin && !requester.IsInternal {
+		return 0, ErrNoPermission
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return 0, ErrNoDatabase
+	}
+
+	q := `update pixel_streaming_instance i
+set status         = $1,
+    status_message = case
+                         when i.status = $2 then coalesce(i.status_message, 'instance reported an error')
+                         when coalesce(i.updated_at, i.created_at) < now() - $5 * interval '1 second' then 'instance heartbeat timed out'
+                         else 'instance is busy without an active session' end,
+    updated_at     = now()
+where i.status = $2
+   or (i.status in ($3, $4) and coalesce(i.updated_at, i.created_at) < now() - $5 * interval '1 second')
+   or (i.status = $4 and not exists(select 1 from pixel_streaming_session s where s.instance_id = i.id and s.status = $6))`
+
+	tag, err := db.Exec(ctx, q,
+		PixelStreamingInstanceStatusQuarantined,
+		PixelStreamingInstanceStatusError,
+		PixelStreamingInstanceStatusFree,
+		PixelStreamingInstanceStatusBusy,
+		int64(timeout.Seconds()),
+		PixelStreamingSessionStatusActive)
+	if err != nil {
+		return 0, fmt.Errorf("failed to quarantine pixel streaming instances: %w", err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// AllocatePixelStreamingInstance atomically reserves a free instance running a release of the requested app and opens
+// a session for the (app, world) pair. If the region is not set, the best region for the requester is used.
+func AllocatePixelStreamingInstance(ctx context.Context, requester *User, request PixelStreamingSessionData) (session *PixelStreamingSession, err error) {
+	if requester == nil {
+		return nil, ErrNoRequester
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return nil, ErrNoDatabase
+	}
+
+	if request.AppId == nil || request.AppId.IsNil() {
+		return nil, fmt.Errorf("app id is not set")
+	}
+
+	if request.WorldId == nil || request.WorldId.IsNil() {
+		return nil, fmt.Errorf("world id is not set")
+	}
+
+	err = SelectPixelStreamingRegion(ctx, requester, &request)
+	if err != nil && !errors.Is(err, ErrNoRegion) {
+		return nil, err
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	session, err = allocatePixelStreamingInstanceTx(ctx, tx, requester.Id, request)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return session, nil
+}
+
+// allocatePixelStreamingInstanceTx locks a free instance skipping instances locked by concurrent allocations, marks it
+// busy and inserts the session.
+func allocatePixelStreamingInstanceTx(ctx context.Context, tx pgx.Tx, userId uuid.UUID, request PixelStreamingSessionData) (session *PixelStreamingSession, err error) {
+	var (
+		q       string
+		qArgs   = make([]any, 0)
+		qArgNum = 0
+	)
+
+	qArgNum++
+	qArgs = append(qArgs, request.AppId)
+	q = `select i.id, i.release_id, i.region_id, i.host, i.port, i.instance_type
+from pixel_streaming_instance i
+         inner join release_v2 r on i.release_id = r.id
+where r.entity_id = $` + strconv.Itoa(qArgNum)
+
+	qArgNum++
+	qArgs = append(qArgs, PixelStreamingInstanceStatusFree)
+	q += ` and i.status = $` + strconv.Itoa(qArgNum)
+
+	if request.RegionId != nil && !request.RegionId.IsNil() {
+		qArgNum++
+		qArgs = append(qArgs, request.RegionId)
+		q += ` and i.region_id = $` + strconv.Itoa(qArgNum)
+	}
+
+	if request.InstanceType != "" {
+		qArgNum++
+		qArgs = append(qArgs, request.InstanceType)
+		q += ` and i.instance_type = $` + strconv.Itoa(qArgNum)
+	}
+
+	q += ` order by coalesce(i.updated_at, i.created_at) limit 1 for update of i skip locked`
+
+	var (
+		instanceId   pgtypeuuid.UUID
+		releaseId    pgtypeuuid.UUID
+		regionId     pgtypeuuid.UUID
+		host         pgtype.Text
+		port         pgtype.Int4
+		instanceType pgtype.Text
+	)
+
+	err = tx.QueryRow(ctx, q, qArgs...).Scan(&instanceId, &releaseId, &regionId, &host, &port, &instanceType)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNoFreeInstance
+		}
+		return nil, fmt.Errorf("failed to find a free pixel streaming instance: %w", err)
+	}
+
+	q = `update pixel_streaming_instance set status = $1, updated_at = now() where id = $2`
+	_, err = tx.Exec(ctx, q, PixelStreamingInstanceStatusBusy, instanceId.UUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reserve pixel streaming instance: %w", err)
+	}
+
+	session = &PixelStreamingSession{
+		InstanceId: &instanceId.UUID,
+		UserId:     &userId,
+		AppId:      request.AppId,
+		WorldId:    request.WorldId,
+		Status:     PixelStreamingSessionStatusActive,
+	}
+
+	q = `insert into pixel_streaming_session (id, created_at, updated_at, instance_id, user_id, app_id, world_id, status)
+values (gen_random_uuid(), now(), now(), $1, $2, $3, $4, $5)
+returning id, created_at`
+
+	err = tx.QueryRow(ctx, q, instanceId.UUID, userId, request.AppId, request.WorldId, session.Status).Scan(&session.Id, &session.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pixel streaming session: %w", err)
+	}
+
+	session.UpdatedAt = &session.CreatedAt
+	session.Instance = &PixelStreamingInstance{Status: PixelStreamingInstanceStatusBusy}
+	session.Instance.Id = instanceId.UUID
+	if releaseId.Status == pgtype.Present {
+		session.Instance.ReleaseId = &releaseId.UUID
+	}
+	if regionId.Status == pgtype.Present {
+		session.Instance.RegionId = &regionId.UUID
+	}
+	if host.Status == pgtype.Present {
+		session.Instance.Host = host.String
+	}
+	if port.Status == pgtype.Present {
+		session.Instance.Port = uint16(port.Int)
+	}
+	if instanceType.Status == pgtype.Present {
+		session.Instance.InstanceType = instanceType.String
+	}
+
+	return session, nil
+}
+
+// TouchPixelStreamingSession keeps the session alive, sessions not touched within the timeout are expired.
+func TouchPixelStreamingSession(ctx context.Context, requester *User, id uuid.UUID) (err error) {
+	if requester == nil {
+		return ErrNoRequester
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return ErrNoDatabase
+	}
+
+	var (
+		q     = `update pixel_streaming_session set updated_at = now() where id = $1 and status = $2`
+		qArgs = []any{id, PixelStreamingSessionStatusActive}
+	)
+
+	if !requester.IsAdmin && !requester.IsInternal {
+		q += ` and user_id = $3`
+		qArgs = append(qArgs, requester.Id)
+	}
+
+	tag, err := db.Exec(ctx, q, qArgs...)
+	if err != nil {
+		return fmt.Errorf("failed to touch pixel streaming session: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNoRows
+	}
+
+	return nil
+}
+
+// EndPixelStreamingSession ends the active session and returns its instance to the pool.
+func EndPixelStreamingSession(ctx context.Context, requester *User, id uuid.UUID) (err error) {
+	if requester == nil {
+		return ErrNoRequester
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return ErrNoDatabase
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	var (
+		instanceId pgtypeuuid.UUID
+		userId     pgtypeuuid.UUID
+	)
+
+	q := `select instance_id, user_id from pixel_streaming_session where id = $1 and status = $2 for update`
+	err = tx.QueryRow(ctx, q, id, PixelStreamingSessionStatusActive).Scan(&instanceId, &userId)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return ErrNoRows
+		}
+		return fmt.Errorf("failed to get pixel streaming session: %w", err)
+	}
+
+	if !requester.IsAdmin && !requester.IsInternal && (userId.Status != pgtype.Present || userId.UUID != requester.Id) {
+		return ErrNoPermission
+	}
+
+	q = `update pixel_streaming_session set status = $1, updated_at = now() where id = $2`
+	_, err = tx.Exec(ctx, q, PixelStreamingSessionStatusEnded, id)
+	if err != nil {
+		return fmt.Errorf("failed to end pixel streaming session: %w", err)
+	}
+
+	if instanceId.Status == pgtype.Present {
+		// quarantined instances stay quarantined
+		q = `update pixel_streaming_instance set status = $1, updated_at = now() where id = $2 and status = $3`
+		_, err = tx.Exec(ctx, q, PixelStreamingInstanceStatusFree, instanceId.UUID, PixelStreamingInstanceStatusBusy)
+		if err != nil {
+			return fmt.Errorf("failed to release pixel streaming instance: %w", err)
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	advancePixelStreamingQueue(ctx, db)
+
+	return nil
+}
+
+// ExpirePixelStreamingSessions expires active sessions idle past the timeout and returns their instances to the pool.
+// Returns the number of expired sessions.
+func ExpirePixelStreamingSessions(ctx context.Context, requester *User, timeout time.Duration) (expired int64, err error) {
+	if requester == nil {
+		return 0, ErrNoRequester
+	}
+
+	if !requester.IsAdmin && !requester.IsInternal {
+		return 0, ErrNoPermission
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return 0, ErrNoDatabase
+	}
+
+	q := `with s as (
+    update pixel_streaming_session
+        set status = $1, updated_at = now()
+        where status = $2 and coalesce(updated_at, created_at) < now() - $3 * interval '1 second'
+        returning instance_id),
+     i as (
+         update pixel_streaming_instance
+             set status = $4, updated_at = now()
+             where id in (select instance_id from s) and status = $5)
+select count(*)
+from s`
+
+	err = db.QueryRow(ctx, q,
+		PixelStreamingSessionStatusExpired,
+		PixelStreamingSessionStatusActive,
+		int64(timeout.Seconds()),
+		PixelStreamingInstanceStatusFree,
+		PixelStreamingInstanceStatusBusy).Scan(&expired)
+	if err != nil {
+		return 0, fmt.Errorf("failed to expire pixel streaming sessions: %w", err)
+	}
+
+	if expired > 0 {
+		advancePixelStreamingQueue(ctx, db)
+	}
+
+	return expired, nil
+}
+
+func GetPixelStreamingSession(ctx context.Context, requester *User, id uuid.UUID) (session *PixelStreamingSession, err error) {
+	if requester == nil {
+		return nil, ErrNoRequester
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return nil, ErrNoDatabase
+	}
+
+	q := `select s.id, s.created_at, s.updated_at, s.instance_id, s.user_id, s.app_id, s.world_id, s.status,
+       i.release_id, i.region_id, i.host, i.port, i.instance_type, i.status
+from pixel_streaming_session s
+         left join pixel_streaming_instance i on s.instance_id = i.id
+where s.id = $1`
+
+	var (
+		sessionId         pgtypeuuid.UUID
+		createdAt         pgtype.Timestamptz
+		updatedAt         pgtype.Timestamptz
+		instanceId        pgtypeuuid.UUID
+		userId            pgtypeuuid.UUID
+		appId             pgtypeuuid.UUID
+		worldId           pgtypeuuid.UUID
+		status            pgtype.Text
+		instanceReleaseId pgtypeuuid.UUID
+		instanceRegionId  pgtypeuuid.UUID
+		instanceHost      pgtype.Text
+		instancePort      pgtype.Int4
+		instanceType      pgtype.Text
+		instanceStatus    pgtype.Text
+	)
+
+	err = db.QueryRow(ctx, q, id).Scan(&sessionId, &createdAt, &updatedAt, &instanceId, &userId, &appId, &worldId, &status,
+		&instanceReleaseId, &instanceRegionId, &instanceHost, &instancePort, &instanceType, &instanceStatus)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNoRows
+		}
+		return nil, fmt.Errorf("failed to get pixel streaming session: %w", err)
+	}
+
+	if !requester.IsAdmin && !requester.IsInternal && (userId.Status != pgtype.Present || userId.UUID != requester.Id) {
+		return nil, ErrNoPermission
+	}
+
+	session = &PixelStreamingSession{}
+	session.Id = sessionId.UUID
+	if createdAt.Status == pgtype.Present {
+		session.CreatedAt = createdAt.Time
+	}
+	if updatedAt.Status == pgtype.Present {
+		session.UpdatedAt = &updatedAt.Time
+	}
+	if userId.Status == pgtype.Present {
+		session.UserId = &userId.UUID
+	}
+	if appId.Status == pgtype.Present {
+		session.AppId = &appId.UUID
+	}
+	if worldId.Status == pgtype.Present {
+		session.WorldId = &worldId.UUID
+	}
+	if status.Status == pgtype.Present {
+		session.Status = status.String
+	}
+	if instanceId.Status == pgtype.Present {
+		session.InstanceId = &instanceId.UUID
+		session.Instance = &PixelStreamingInstance{}
+		session.Instance.Id = instanceId.UUID
+		if instanceReleaseId.Status == pgtype.Present {
+			session.Instance.ReleaseId = &instanceReleaseId.UUID
+		}
+		if instanceRegionId.Status == pgtype.Present {
+			session.Instance.RegionId = &instanceRegionId.UUID
+		}
+		if instanceHost.Status == pgtype.Present {
+			session.Instance.Host = instanceHost.String
+		}
+		if instancePort.Status == pgtype.Present {
+			session.Instance.Port = uint16(instancePort.Int)
+		}
+		if instanceType.Status == pgtype.Present {
+			session.Instance.InstanceType = instanceType.String
+		}
+		if instanceStatus.Status == pgtype.Present {
+			session.Instance.Status = instanceStatus.String
+		}
+	}
+
+	return session, nil
+}
+
+func GetPixelStreamingInstance(ctx context.Context, requester *User, id uuid.UUID) (instance *PixelStreamingInstance, err error) {
+	if requester == nil {
+		return nil, ErrNoRequester
+	}
+
+	if !requester.IsAdmin && !requester.IsInternal {
+		return nil, ErrNoPermission
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return nil, ErrNoDatabase
+	}
+
+	q := `select i.id, i.created_at, i.updated_at, i.release_id, i.region_id, i.host, i.port, i.status, i.status_message, i.instance_type from pixel_streaming_instance i where i.id = $1`
+
+	rows, err := db.Query(ctx, q, id)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	if rows.Next() {
+		instance, err = scanPixelStreamingInstance(rows)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if instance == nil {
+		return nil, ErrNoRows
+	}
+
+	return instance, nil
+}
+
+type IndexPixelStreamingInstanceRequest struct {
+	Offset       *int64     `json:"offset,omitempty"`
+	Limit        *int64     `json:"limit,omitempty"`
+	RegionId     *uuid.UUID `json:"regionId,omitempty"`
+	ReleaseId    *uuid.UUID `json:"releaseId,omitempty"`
+	InstanceType *string    `json:"instanceType,omitempty"`
+	Status       *string    `json:"status,omitempty"`
+}
+
+func IndexPixelStreamingInstance(ctx context.Context, requester *User, request IndexPixelStreamingInstanceRequest) (entities *PixelStreamingInstanceBatch, err error) {
+	if requester == nil || requester.Id == uuid.Nil {
+		return nil, ErrNoRequester
+	}
+
+	if !requester.IsAdmin && !requester.IsInternal {
+		return nil, ErrNoPermission
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return nil, ErrNoDatabase
+	}
+
+	var batch = PixelStreamingInstanceBatch{
+		Offset: 0,
+		Limit:  100,
+		Total:  0,
+	}
+
+	if request.Offset != nil && *request.Offset >= 0 {
+		batch.Offset = *request.Offset
+	}
+
+	if request.Limit != nil && *request.Limit > 0 && *request.Limit <= 100 {
+		batch.Limit = *request.Limit
+	}
+
+	var (
+		qt      string
+		q       string
+		qWhere  = ` where true`
+		qArgs   = make([]any, 0)
+		qArgNum = 0
+		rows    pgx.Rows
+	)
+
+	if request.RegionId != nil {
+		qArgNum++
+		qArgs = append(qArgs, *request.RegionId)
+		qWhere += ` and i.region_id = $` + strconv.Itoa(qArgNum)
+	}
+
+	if request.ReleaseId != nil {
+		qArgNum++
+		qArgs = append(qArgs, *request.ReleaseId)
+		qWhere += ` and i.release_id = $` + strconv.Itoa(qArgNum)
+	}
+
+	if request.InstanceType != nil {
+		qArgNum++
+		qArgs = append(qArgs, *request.InstanceType)
+		qWhere += ` and i.instance_type = $` + strconv.Itoa(qArgNum)
+	}
+
+	if request.Status != nil {
+		qArgNum++
+		qArgs = append(qArgs, *request.Status)
+		qWhere += ` and i.status = $` + strconv.Itoa(qArgNum)
+	}
+
+	qt = `select count(*) from pixel_streaming_instance i` + qWhere
+	err = db.QueryRow(ctx, qt, qArgs...).Scan(&batch.Total)
+	if err != nil {
+		return nil, err
+	}
+
+	q = `select i.id, i.created_at, i.updated_at, i.release_id, i.region_id, i.host, i.port, i.status, i.status_message, i.instance_type from pixel_streaming_instance i` + qWhere
+	q += ` order by i.created_at offset $` + strconv.Itoa(qArgNum+1) + ` limit $` + strconv.Itoa(qArgNum+2)
+	qArgs = append(qArgs, batch.Offset, batch.Limit)
+
+	rows, err = db.Query(ctx, q, qArgs...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var instance *PixelStreamingInstance
+		instance, err = scanPixelStreamingInstance(rows)
+		if err != nil {
+			return nil, err
+		}
+		if instance != nil {
+			batch.Entities = append(batch.Entities, *instance)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &batch, nil
+}
+
+func scanPixelStreamingInstance(rows pgx.Rows) (instance *PixelStreamingInstance, err error) {
+	var (
+		id            pgtypeuuid.UUID
+		createdAt     pgtype.Timestamptz
+		updatedAt     pgtype.Timestamptz
+		releaseId     pgtypeuuid.UUID
+		regionId      pgtypeuuid.UUID
+		host          pgtype.Text
+		port          pgtype.Int4
+		status        pgtype.Text
+		statusMessage pgtype.Text
+		instanceType  pgtype.Text
+	)
+
+	err = rows.Scan(&id, &createdAt, &updatedAt, &releaseId, &regionId, &host, &port, &status, &statusMessage, &instanceType)
+	if err != nil {
+		return nil, err
+	}
+
+	if id.Status != pgtype.Present {
+		return nil, nil
+	}
+
+	instance = &PixelStreamingInstance{}
+	instance.Id = id.UUID
+	if createdAt.Status == pgtype.Present {
+		instance.CreatedAt = createdAt.Time
+	}
+	if updatedAt.Status == pgtype.Present {
+		instance.UpdatedAt = &updatedAt.Time
+	}
+	if releaseId.Status == pgtype.Present {
+		instance.ReleaseId = &releaseId.UUID
+	}
+	if regionId.Status == pgtype.Present {
+		instance.RegionId = &regionId.UUID
+	}
+	if host.Status == pgtype.Present {
+		instance.Host = host.String
+	}
+	if port.Status == pgtype.Present {
+		instance.Port = uint16(port.Int)
+	}
+	if status.Status == pgtype.Present {
+		instance.Status = status.String
+	}
+	if statusMessage.Status == pgtype.Present {
+		instance.StatusMessage = statusMessage.String
+	}
+	if instanceType.Status == pgtype.Present {
+		instance.InstanceType = instanceType.String
+	}
+
+	return instance, nil
 }
@@ -0,0 +1,521 @@
+package model
+
+import (
+	"context"
+	glContext "dev.hackerman.me/artheon/veverse-shared/context"
+	"errors"
+	"fmt"
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgtype"
+	pgtypeuuid "github.com/jackc/pgtype/ext/gofrs-uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+// PixelStreamingQueueStatus enum
+const (
+	PixelStreamingQueueStatusWaiting   = "waiting"   // user is waiting for a free instance
+	PixelStreamingQueueStatusAllocated = "allocated" // session has been allocated, the entry is complete
+	PixelStreamingQueueStatusCancelled = "cancelled" // user left the queue
+	PixelStreamingQueueStatusExpired   = "expired"   // user stopped polling the queue
+)
+
+const (
+	pixelStreamingQueuePriorityDefault  = 0 // regular users
+	pixelStreamingQueuePriorityInternal = 1 // internal users and admins skip ahead of regular users
+
+	// pixelStreamingDefaultSessionDuration is used to estimate the wait time if there are no recent sessions
+	pixelStreamingDefaultSessionDuration = 10 * time.Minute
+	// pixelStreamingSessionHistorySize is the number of recent sessions used to estimate the session duration
+	pixelStreamingSessionHistorySize = 50
+	// pixelStreamingQueueBatchSize is the max number of queue entries processed at once
+	pixelStreamingQueueBatchSize = 100
+)
+
+// PixelStreamingQueueEntry is a user waiting for a pixel streaming instance of the (instance type, region, app) pool
+type PixelStreamingQueueEntry struct {
+	Identifier
+	Timestamps
+
+	UserId        *uuid.UUID             `json:"userId,omitempty"`
+	AppId         *uuid.UUID             `json:"appId"`
+	WorldId       *uuid.UUID             `json:"worldId"`
+	RegionId      *uuid.UUID             `json:"regionId,omitempty"`
+	InstanceType  string                 `json:"instanceType,omitempty"`
+	Priority      int32                  `json:"priority"`
+	Status        string                 `json:"status"`
+	SessionId     *uuid.UUID             `json:"sessionId,omitempty"`
+	Session       *PixelStreamingSession `json:"session,omitempty"`       // allocated session, set when the status is allocated
+	Position      int64                  `json:"position,omitempty"`      // 1-based position in the queue, set when the status is waiting
+	EstimatedWait int64                  `json:"estimatedWait,omitempty"` // estimated wait time in seconds, set when the status is waiting
+}
+
+// RequestPixelStreamingSession allocates a free instance or puts the requester into the waiting queue if the pool is
+// exhausted. Returns either the allocated session or the queue entry with its position and estimated wait time.
+func RequestPixelStreamingSession(ctx context.Context, requester *User, request PixelStreamingSessionData) (session *PixelStreamingSession, entry *PixelStreamingQueueEntry, err error) {
+	if requester == nil {
+		return nil, nil, ErrNoRequester
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return nil, nil, ErrNoDatabase
+	}
+
+	err = SelectPixelStreamingRegion(ctx, requester, &request)
+	if err != nil && !errors.Is(err, ErrNoRegion) {
+		return nil, nil, err
+	}
+
+	// users already waiting in the queue keep their place
+	entry, err = pollWaitingPixelStreamingQueueEntry(ctx, db, requester, request)
+	if err != ErrNoRows {
+		return nil, entry, err
+	}
+
+	// do not skip users already waiting for the same pool
+	var waiting int64
+	q := `select count(*)
+from pixel_streaming_queue
+where app_id = $1
+  and region_id is not distinct from $2
+  and instance_type = $3
+  and status = $4
+  and priority >= $5`
+	err = db.QueryRow(ctx, q, request.AppId, request.RegionId, request.InstanceType, PixelStreamingQueueStatusWaiting, pixelStreamingQueuePriority(requester)).Scan(&waiting)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to check pixel streaming queue: %w", err)
+	}
+
+	if waiting == 0 {
+		session, err = AllocatePixelStreamingInstance(ctx, requester, request)
+		if err == nil {
+			return session, nil, nil
+		} else if !errors.Is(err, ErrNoFreeInstance) {
+			return nil, nil, err
+		}
+	}
+
+	// a concurrent request of the same user (e.g. a double submit) may have enqueued it already
+	q = `insert into pixel_streaming_queue (id, created_at, updated_at, user_id, app_id, world_id, region_id, instance_type, priority, status)
+values (gen_random_uuid(), now(), now(), $1, $2, $3, $4, $5, $6, $7)
+on conflict do nothing
+returning id`
+
+	var id pgtypeuuid.UUID
+	err = db.QueryRow(ctx, q, requester.Id, request.AppId, request.WorldId, request.RegionId, request.InstanceType, pixelStreamingQueuePriority(requester), PixelStreamingQueueStatusWaiting).Scan(&id)
+	if err == pgx.ErrNoRows {
+		entry, err = pollWaitingPixelStreamingQueueEntry(ctx, db, requester, request)
+		return nil, entry, err
+	} else if err != nil {
+		return nil, nil, fmt.Errorf("failed to enqueue pixel streaming session: %w", err)
+	}
+
+	entry, err = PollPixelStreamingQueue(ctx, requester, id.UUID)
+	return nil, entry, err
+}
+
+// pollWaitingPixelStreamingQueueEntry polls the waiting entry of the requester for the pool, ErrNoRows is returned if
+// the requester is not waiting
+func pollWaitingPixelStreamingQueueEntry(ctx context.Context, db *pgxpool.Pool, requester *User, request PixelStreamingSessionData) (entry *PixelStreamingQueueEntry, err error) {
+	q := `select id
+from pixel_streaming_queue
+where user_id = $1
+  and app_id = $2
+  and world_id = $3
+  and region_id is not distinct from $4
+  and instance_type = $5
+  and status = $6
+limit 1`
+
+	var id pgtypeuuid.UUID
+	err = db.QueryRow(ctx, q, requester.Id, request.AppId, request.WorldId, request.RegionId, request.InstanceType, PixelStreamingQueueStatusWaiting).Scan(&id)
+	if err == pgx.ErrNoRows {
+		return nil, ErrNoRows
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to check pixel streaming queue: %w", err)
+	}
+
+	return PollPixelStreamingQueue(ctx, requester, id.UUID)
+}
+
+// PollPixelStreamingQueue refreshes the queue entry heartbeat and returns its current state. Waiting entries include
+// the position and the estimated wait time, allocated entries include the session.
+func PollPixelStreamingQueue(ctx context.Context, requester *User, id uuid.UUID) (entry *PixelStreamingQueueEntry, err error) {
+	if requester == nil {
+		return nil, ErrNoRequester
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return nil, ErrNoDatabase
+	}
+
+	q := `update pixel_streaming_queue
+set updated_at = now()
+where id = $1
+  and (user_id = $2 or $3)
+returning id, created_at, updated_at, user_id, app_id, world_id, region_id, instance_type, priority, status, session_id`
+
+	var (
+		entryId      pgtypeuuid.UUID
+		createdAt    pgtype.Timestamptz
+		updatedAt    pgtype.Timestamptz
+		userId       pgtypeuuid.UUID
+		appId        pgtypeuuid.UUID
+		worldId      pgtypeuuid.UUID
+		regionId     pgtypeuuid.UUID
+		instanceType pgtype.Text
+		priority     pgtype.Int4
+		status       pgtype.Text
+		sessionId    pgtypeuuid.UUID
+	)
+
+	// only the owner of the entry can keep it from expiring
+	err = db.QueryRow(ctx, q, id, requester.Id, requester.IsAdmin || requester.IsInternal).Scan(&entryId, &createdAt, &updatedAt, &userId, &appId, &worldId, &regionId, &instanceType, &priority, &status, &sessionId)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			var exists bool
+			if err = db.QueryRow(ctx, `select exists(select 1 from pixel_streaming_queue where id = $1)`, id).Scan(&exists); err != nil {
+				return nil, fmt.Errorf("failed to get pixel streaming queue entry: %w", err)
+			}
+			if exists {
+				return nil, ErrNoPermission
+			}
+			return nil, ErrNoRows
+		}
+		return nil, fmt.Errorf("failed to get pixel streaming queue entry: %w", err)
+	}
+
+	entry = &PixelStreamingQueueEntry{}
+	entry.Id = entryId.UUID
+	if createdAt.Status == pgtype.Present {
+		entry.CreatedAt = createdAt.Time
+	}
+	if updatedAt.Status == pgtype.Present {
+		entry.UpdatedAt = &updatedAt.Time
+	}
+	if userId.Status == pgtype.Present {
+		entry.UserId = &userId.UUID
+	}
+	if appId.Status == pgtype.Present {
+		entry.AppId = &appId.UUID
+	}
+	if worldId.Status == pgtype.Present {
+		entry.WorldId = &worldId.UUID
+	}
+	if regionId.Status == pgtype.Present {
+		entry.RegionId = &regionId.UUID
+	}
+	if instanceType.Status == pgtype.Present {
+		entry.InstanceType = instanceType.String
+	}
+	if priority.Status == pgtype.Present {
+		entry.Priority = priority.Int
+	}
+	if status.Status == pgtype.Present {
+		entry.Status = status.String
+	}
+
+	switch entry.Status {
+	case PixelStreamingQueueStatusAllocated:
+		if sessionId.Status == pgtype.Present {
+			entry.SessionId = &sessionId.UUID
+			entry.Session, err = GetPixelStreamingSession(ctx, requester, sessionId.UUID)
+			if err != nil {
+				return nil, err
+			}
+		}
+	case PixelStreamingQueueStatusWaiting:
+		err = fillPixelStreamingQueuePosition(ctx, db, entry)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return entry, nil
+}
+
+// CancelPixelStreamingQueueEntry removes the waiting entry from the queue
+func CancelPixelStreamingQueueEntry(ctx context.Context, requester *User, id uuid.UUID) (err error) {
+	if requester == nil {
+		return ErrNoRequester
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return ErrNoDatabase
+	}
+
+	var (
+		q     = `update pixel_streaming_queue set status = $1, updated_at = now() where id = $2 and status = $3`
+		qArgs = []any{PixelStreamingQueueStatusCancelled, id, PixelStreamingQueueStatusWaiting}
+	)
+
+	if !requester.IsAdmin && !requester.IsInternal {
+		q += ` and user_id = $4`
+		qArgs = append(qArgs, requester.Id)
+	}
+
+	tag, err := db.Exec(ctx, q, qArgs...)
+	if err != nil {
+		return fmt.Errorf("failed to cancel pixel streaming queue entry: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNoRows
+	}
+
+	return nil
+}
+
+// ProcessPixelStreamingQueue moves waiting entries to free instances, higher priority entries first, then in the order
+// they were queued. Returns the number of allocated sessions.
+func ProcessPixelStreamingQueue(ctx context.Context, requester *User) (allocated int64, err error) {
+	if requester == nil {
+		return 0, ErrNoRequester
+	}
+
+	if !requester.IsAdmin && !requester.IsInternal {
+		return 0, ErrNoPermission
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return 0, ErrNoDatabase
+	}
+
+	return processPixelStreamingQueue(ctx, db)
+}
+
+func processPixelStreamingQueue(ctx context.Context, db *pgxpool.Pool) (allocated int64, err error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	q := `select id, user_id, app_id, world_id, region_id, instance_type
+from pixel_streaming_queue
+where status = $1
+order by priority desc, created_at
+limit $2 for update skip locked`
+
+	rows, err := tx.Query(ctx, q, PixelStreamingQueueStatusWaiting, pixelStreamingQueueBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query pixel streaming queue: %w", err)
+	}
+
+	type waitingEntry struct {
+		id      uuid.UUID
+		userId  uuid.UUID
+		request PixelStreamingSessionData
+	}
+
+	var entries []waitingEntry
+	for rows.Next() {
+		var (
+			id           pgtypeuuid.UUID
+			userId       pgtypeuuid.UUID
+			appId        pgtypeuuid.UUID
+			worldId      pgtypeuuid.UUID
+			regionId     pgtypeuuid.UUID
+			instanceType pgtype.Text
+		)
+
+		err = rows.Scan(&id, &userId, &appId, &worldId, &regionId, &instanceType)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+
+		e := waitingEntry{id: id.UUID, userId: userId.UUID}
+		e.request.AppId = &appId.UUID
+		e.request.WorldId = &worldId.UUID
+		if regionId.Status == pgtype.Present {
+			e.request.RegionId = &regionId.UUID
+		}
+		if instanceType.Status == pgtype.Present {
+			e.request.InstanceType = instanceType.String
+		}
+		entries = append(entries, e)
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	// pools without free instances are skipped for the rest of the batch to keep the queue order
+	var exhausted = make(map[string]bool)
+
+	for _, e := range entries {
+		key := fmt.Sprintf("%v/%v/%s", e.request.AppId, e.request.RegionId, e.request.InstanceType)
+		if exhausted[key] {
+			continue
+		}
+
+		// use a savepoint, so a failed allocation does not abort the whole batch
+		var sp pgx.Tx
+		sp, err = tx.Begin(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("failed to start savepoint: %w", err)
+		}
+
+		var session *PixelStreamingSession
+		session, err = allocatePixelStreamingInstanceTx(ctx, sp, e.userId, e.request)
+		if err != nil {
+			_ = sp.Rollback(ctx)
+			if errors.Is(err, ErrNoFreeInstance) {
+				exhausted[key] = true
+				continue
+			}
+			return 0, err
+		}
+
+		q = `update pixel_streaming_queue set status = $1, session_id = $2, updated_at = now() where id = $3`
+		_, err = sp.Exec(ctx, q, PixelStreamingQueueStatusAllocated, session.Id, e.id)
+		if err != nil {
+			_ = sp.Rollback(ctx)
+			return 0, fmt.Errorf("failed to update pixel streaming queue entry: %w", err)
+		}
+
+		err = sp.Commit(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("failed to release savepoint: %w", err)
+		}
+
+		allocated++
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return allocated, nil
+}
+
+// ExpirePixelStreamingQueue expires waiting entries that have not been polled within the timeout.
+// Returns the number of expired entries.
+func ExpirePixelStreamingQueue(ctx context.Context, requester *User, timeout time.Duration) (expired int64, err error) {
+	if requester == nil {
+		return 0, ErrNoRequester
+	}
+
+	if !requester.IsAdmin && !requester.IsInternal {
+		return 0, ErrNoPermission
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return 0, ErrNoDatabase
+	}
+
+	q := `update pixel_streaming_queue set status = $1 where status = $2 and updated_at < now() - $3 * interval '1 second'`
+	tag, err := db.Exec(ctx, q, PixelStreamingQueueStatusExpired, PixelStreamingQueueStatusWaiting, int64(timeout.Seconds()))
+	if err != nil {
+		return 0, fmt.Errorf("failed to expire pixel streaming queue: %w", err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// EstimatePixelStreamingWait estimates the wait time for the queue position, assuming the instances of the pool are
+// released evenly at the average session duration.
+func EstimatePixelStreamingWait(position int64, instances int64, averageSession time.Duration) time.Duration {
+	if position <= 0 {
+		return 0
+	}
+
+	if instances <= 0 {
+		instances = 1
+	}
+
+	if averageSession <= 0 {
+		averageSession = pixelStreamingDefaultSessionDuration
+	}
+
+	// every instance serves one user per average session, so users are served in rounds
+	rounds := (position + instances - 1) / instances
+
+	return time.Duration(rounds) * averageSession
+}
+
+func pixelStreamingQueuePriority(requester *User) int32 {
+	if requester.IsInternal || requester.IsAdmin {
+		return pixelStreamingQueuePriorityInternal
+	}
+	return pixelStreamingQueuePriorityDefault
+}
+
+// fillPixelStreamingQueuePosition sets the position and the estimated wait time of the waiting entry
+func fillPixelStreamingQueuePosition(ctx context.Context, db *pgxpool.Pool, entry *PixelStreamingQueueEntry) (err error) {
+	q := `select count(*)
+from pixel_streaming_queue
+where app_id = $1
+  and region_id is not distinct from $2
+  and instance_type = $3
+  and status = $4
+  and (priority > $5 or (priority = $5 and created_at <= $6))`
+
+	err = db.QueryRow(ctx, q, entry.AppId, entry.RegionId, entry.InstanceType, PixelStreamingQueueStatusWaiting, entry.Priority, entry.CreatedAt).Scan(&entry.Position)
+	if err != nil {
+		return fmt.Errorf("failed to get pixel streaming queue position: %w", err)
+	}
+
+	var (
+		instances      int64
+		averageSeconds pgtype.Float8
+	)
+
+	q = `select count(*)
+from pixel_streaming_instance i
+         inner join release_v2 r on i.release_id = r.id
+where r.entity_id = $1
+  and ($2::uuid is null or i.region_id = $2)
+  and ($3 = '' or i.instance_type = $3)
+  and i.status in ($4, $5)`
+
+	err = db.QueryRow(ctx, q, entry.AppId, entry.RegionId, entry.InstanceType, PixelStreamingInstanceStatusFree, PixelStreamingInstanceStatusBusy).Scan(&instances)
+	if err != nil {
+		return fmt.Errorf("failed to count pixel streaming instances: %w", err)
+	}
+
+	q = `select avg(extract(epoch from (s.updated_at - s.created_at)))
+from (select s.created_at, s.updated_at
+      from pixel_streaming_session s
+               inner join pixel_streaming_instance i on s.instance_id = i.id
+      where s.app_id = $1
+        and ($2::uuid is null or i.region_id = $2)
+        and ($3 = '' or i.instance_type = $3)
+        and s.status in ($4, $5)
+      order by s.updated_at desc
+      limit $6) s`
+
+	err = db.QueryRow(ctx, q, entry.AppId, entry.RegionId, entry.InstanceType, PixelStreamingSessionStatusEnded, PixelStreamingSessionStatusExpired, pixelStreamingSessionHistorySize).Scan(&averageSeconds)
+	if err != nil {
+		return fmt.Errorf("failed to get pixel streaming session duration: %w", err)
+	}
+
+	var averageSession time.Duration
+	if averageSeconds.Status == pgtype.Present {
+		averageSession = time.Duration(averageSeconds.Float * float64(time.Second))
+	}
+
+	entry.EstimatedWait = int64(EstimatePixelStreamingWait(entry.Position, instances, averageSession).Seconds())
+
+	return nil
+}
+
+// advancePixelStreamingQueue hands freed instances to waiting users, errors are logged as the caller has already
+// completed its own work
+func advancePixelStreamingQueue(ctx context.Context, db *pgxpool.Pool) {
+	if _, err := processPixelStreamingQueue(ctx, db); err != nil {
+		logrus.Errorf("failed to process pixel streaming queue: %v", err)
+	}
+}
@@ -1,6 +1,15 @@
 package model
 
-import "fmt"
+import (
+	"context"
+	glContext "dev.hackerman.me/artheon/veverse-shared/context"
+	"fmt"
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgtype"
+	pgtypeuuid "github.com/jackc/pgtype/ext/gofrs-uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
 
 // Custom entity property trait
 type Property struct {
@@ -26,3 +35,227 @@ type InsertProperty struct {
 	Name  string `json:"name"`
 	Value string `json:"value"`
 }
+
+// getEntityPropertySchemas returns the property schemas of the entity type
+func getEntityPropertySchemas(ctx context.Context, db *pgxpool.Pool, entityId uuid.UUID) (schemas []PropertySchema, err error) {
+	var entityType pgtype.Text
+	err = db.QueryRow(ctx, `select entity_type from entities where id = $1`, entityId).Scan(&entityType)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNoRows
+		}
+		return nil, fmt.Errorf("failed to get entity type: %w", err)
+	}
+
+	return getPropertySchemas(ctx, db, entityType.String)
+}
+
+func scanProperty(row pgx.Row) (p *Property, err error) {
+	var (
+		id       pgtypeuuid.UUID
+		entityId pgtypeuuid.UUID
+		t        pgtype.Text
+		name     pgtype.Text
+		value    pgtype.Text
+	)
+
+	err = row.Scan(&id, &entityId, &t, &name, &value)
+	if err != nil {
+		return nil, err
+	}
+
+	p = &Property{Type: t.String, Name: name.String, Value: value.String}
+	p.Id = id.UUID
+	p.EntityId = &entityId.UUID
+	if p.Type == "" {
+		p.Type = PropertyTypeString
+	}
+
+	return p, nil
+}
+
+// GetEntityProperties returns typed properties of the entity, declared properties that are not set are returned with
+// their default values
+func GetEntityProperties(ctx context.Context, requester *User, entityId uuid.UUID) (properties []TypedProperty, err error) {
+	if requester == nil {
+		return nil, ErrNoRequester
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return nil, ErrNoDatabase
+	}
+
+	canView, err := RequestCanViewEntity(ctx, requester, entityId)
+	if err != nil {
+		return nil, err
+	}
+
+	if !canView {
+		return nil, ErrNoPermission
+	}
+
+	schemas, err := getEntityPropertySchemas(ctx, db, entityId)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(ctx, `select id, entity_id, type, name, value from properties where entity_id = $1 order by name`, entityId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get properties: %w", err)
+	}
+
+	defer rows.Close()
+	var stored []Property
+	for rows.Next() {
+		var p *Property
+		p, err = scanProperty(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get properties: %w", err)
+		}
+		stored = append(stored, *p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get properties: %w", err)
+	}
+
+	return withPropertyDefaults(schemas, stored), nil
+}
+
+// GetEntityProperty returns the typed property of the entity or its default value
+func GetEntityProperty(ctx context.Context, requester *User, entityId uuid.UUID, name string) (property *TypedProperty, err error) {
+	properties, err := GetEntityProperties(ctx, requester, entityId)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range properties {
+		if p.Name == name {
+			return &p, nil
+		}
+	}
+
+	return nil, ErrNoRows
+}
+
+// SetEntityProperty validates the property against the schema of the entity type and creates or replaces it
+func SetEntityProperty(ctx context.Context, requester *User, entityId uuid.UUID, property InsertProperty) (result *TypedProperty, err error) {
+	properties, err := SetEntityProperties(ctx, requester, entityId, []InsertProperty{property})
+	if err != nil {
+		return nil, err
+	}
+
+	return &properties[0], nil
+}
+
+// SetEntityProperties validates the properties against the schema of the entity type and creates or replaces them in a
+// single transaction, properties not listed are kept
+func SetEntityProperties(ctx context.Context, requester *User, entityId uuid.UUID, properties []InsertProperty) (result []TypedProperty, err error) {
+	if requester == nil {
+		return nil, ErrNoRequester
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return nil, ErrNoDatabase
+	}
+
+	if len(properties) == 0 {
+		return nil, nil
+	}
+
+	canEdit, err := RequestCanEditEntity(ctx, requester, entityId)
+	if err != nil {
+		return nil, err
+	}
+
+	if !canEdit {
+		return nil, ErrNoPermission
+	}
+
+	schemas, err := getEntityPropertySchemas(ctx, db, entityId)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err = ValidateProperties(schemas, properties)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	q := `insert into properties (id, entity_id, type, name, value)
+values (gen_random_uuid(), $1, $2, $3, $4)
+on conflict (entity_id, name) do update set type  = excluded.type,
+                                            value = excluded.value
+returning id`
+
+	for i, p := range result {
+		var id pgtypeuuid.UUID
+		err = tx.QueryRow(ctx, q, entityId, p.Type, p.Name, p.Value).Scan(&id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set property %s: %w", p.Name, err)
+		}
+		result[i].Id = id.UUID
+		result[i].EntityId = &entityId
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return result, nil
+}
+
+// DeleteEntityProperty deletes the property of the entity, required properties can not be deleted, they can only be
+// reset to the default value by setting it
+func DeleteEntityProperty(ctx context.Context, requester *User, entityId uuid.UUID, name string) (err error) {
+	if requester == nil {
+		return ErrNoRequester
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return ErrNoDatabase
+	}
+
+	canEdit, err := RequestCanEditEntity(ctx, requester, entityId)
+	if err != nil {
+		return err
+	}
+
+	if !canEdit {
+		return ErrNoPermission
+	}
+
+	schemas, err := getEntityPropertySchemas(ctx, db, entityId)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range schemas {
+		if s.Name == name && s.Required {
+			return fmt.Errorf("%w: %s", ErrRequiredProperty, name)
+		}
+	}
+
+	tag, err := db.Exec(ctx, `delete from properties where entity_id = $1 and name = $2`, entityId, name)
+	if err != nil {
+		return fmt.Errorf("failed to delete property: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNoRows
+	}
+
+	return nil
+}
@@ -0,0 +1,510 @@
+package model
+
+import (
+	"context"
+	glContext "dev.hackerman.me/artheon/veverse-shared/context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgtype"
+	pgtypeuuid "github.com/jackc/pgtype/ext/gofrs-uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	PropertyTypeInt    = "int"
+	PropertyTypeFloat  = "float"
+	PropertyTypeBool   = "bool"
+	PropertyTypeString = "string"
+	PropertyTypeJson   = "json"
+	PropertyTypeVector = "vector" // "X=1 Y=2 Z=3" as written by Unreal FVector::ToString, or "1,2,3"
+	PropertyTypeColor  = "color"  // "#RRGGBB" or "#RRGGBBAA"
+)
+
+var propertyTypes = map[string]bool{
+	PropertyTypeInt:    true,
+	PropertyTypeFloat:  true,
+	PropertyTypeBool:   true,
+	PropertyTypeString: true,
+	PropertyTypeJson:   true,
+	PropertyTypeVector: true,
+	PropertyTypeColor:  true,
+}
+
+type Vector struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	Z float64 `json:"z"`
+}
+
+func (v Vector) String() string {
+	return "X=" + formatPropertyFloat(v.X) + " Y=" + formatPropertyFloat(v.Y) + " Z=" + formatPropertyFloat(v.Z)
+}
+
+type Color struct {
+	R uint8 `json:"r"`
+	G uint8 `json:"g"`
+	B uint8 `json:"b"`
+	A uint8 `json:"a"`
+}
+
+func (c Color) String() string {
+	return strings.ToUpper("#" + hex.EncodeToString([]byte{c.R, c.G, c.B, c.A}))
+}
+
+// PropertyConstraints limit values of the property, constraints not applicable to the property type are ignored
+type PropertyConstraints struct {
+	Min       *float64 `json:"min,omitempty"`       // min value of int and float properties
+	Max       *float64 `json:"max,omitempty"`       // max value of int and float properties
+	MinLength *int     `json:"minLength,omitempty"` // min number of characters of string properties
+	MaxLength *int     `json:"maxLength,omitempty"` // max number of characters of string properties
+	Pattern   *string  `json:"pattern,omitempty"`   // regular expression string properties must match
+	Enum      []string `json:"enum,omitempty"`      // allowed values of int, float and string properties
+}
+
+// PropertySchema declares a property of an entity type
+type PropertySchema struct {
+	Identifier
+	Timestamps
+
+	EntityType   string               `json:"entityType"`
+	Name         string               `json:"name"`
+	Type         string               `json:"type"`
+	DefaultValue *string              `json:"defaultValue,omitempty"` // value used if the property is not set
+	Constraints  *PropertyConstraints `json:"constraints,omitempty"`
+	Required     bool                 `json:"required,omitempty"` // every entity has a value, the default until set, required properties can not be deleted
+	Description  *string              `json:"description,omitempty"`
+}
+
+// TypedProperty is the property with its value parsed according to its type
+type TypedProperty struct {
+	Property
+	TypedValue any  `json:"typedValue"`        // int64, float64, bool, string, json.RawMessage, Vector or Color
+	Default    bool `json:"default,omitempty"` // property is not set, the value is the schema default
+}
+
+// ParsePropertyValue parses the value of the property type and returns the typed value and its canonical string form
+func ParsePropertyValue(propertyType string, value string) (typed any, canonical string, err error) {
+	switch propertyType {
+	case PropertyTypeInt:
+		i, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return nil, "", fmt.Errorf("%w: %q is not an int", ErrInvalidProperty, value)
+		}
+		return i, strconv.FormatInt(i, 10), nil
+	case PropertyTypeFloat:
+		f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, "", fmt.Errorf("%w: %q is not a float", ErrInvalidProperty, value)
+		}
+		return f, formatPropertyFloat(f), nil
+	case PropertyTypeBool:
+		b, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return nil, "", fmt.Errorf("%w: %q is not a bool", ErrInvalidProperty, value)
+		}
+		return b, strconv.FormatBool(b), nil
+	case PropertyTypeString:
+		return value, value, nil
+	case PropertyTypeJson:
+		if !json.Valid([]byte(value)) {
+			return nil, "", fmt.Errorf("%w: value is not json", ErrInvalidProperty)
+		}
+		return json.RawMessage(value), value, nil
+	case PropertyTypeVector:
+		v, err := parseVector(value)
+		if err != nil {
+			return nil, "", err
+		}
+		return v, v.String(), nil
+	case PropertyTypeColor:
+		c, err := parseColor(value)
+		if err != nil {
+			return nil, "", err
+		}
+		return c, c.String(), nil
+	default:
+		return nil, "", fmt.Errorf("%w: unknown type %q", ErrInvalidProperty, propertyType)
+	}
+}
+
+func formatPropertyFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func parseVector(value string) (v Vector, err error) {
+	s := strings.TrimSpace(value)
+
+	var parts []string
+	if strings.Contains(s, "=") {
+		fields := strings.Fields(s)
+		if len(fields) != 3 {
+			return v, fmt.Errorf("%w: %q is not a vector", ErrInvalidProperty, value)
+		}
+		for i, prefix := range []string{"X=", "Y=", "Z="} {
+			if !strings.HasPrefix(strings.ToUpper(fields[i]), prefix) {
+				return v, fmt.Errorf("%w: %q is not a vector", ErrInvalidProperty, value)
+			}
+			parts = append(parts, fields[i][2:])
+		}
+	} else {
+		parts = strings.Split(s, ",")
+	}
+
+	if len(parts) != 3 {
+		return v, fmt.Errorf("%w: %q is not a vector", ErrInvalidProperty, value)
+	}
+
+	var xyz [3]float64
+	for i, p := range parts {
+		xyz[i], err = strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil || math.IsNaN(xyz[i]) || math.IsInf(xyz[i], 0) {
+			return v, fmt.Errorf("%w: %q is not a vector", ErrInvalidProperty, value)
+		}
+	}
+
+	return Vector{X: xyz[0], Y: xyz[1], Z: xyz[2]}, nil
+}
+
+func parseColor(value string) (c Color, err error) {
+	s := strings.TrimPrefix(strings.TrimSpace(value), "#")
+	if len(s) == 6 {
+		s += "ff"
+	}
+
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != 4 {
+		return c, fmt.Errorf("%w: %q is not a color", ErrInvalidProperty, value)
+	}
+
+	return Color{R: b[0], G: b[1], B: b[2], A: b[3]}, nil
+}
+
+// Validate checks the schema declaration, including the default value. Required properties must have a default value,
+// so entities that never set them still have a valid value.
+func (s PropertySchema) Validate() error {
+	if s.EntityType == "" {
+		return fmt.Errorf("%w: entity type is not set", ErrInvalidProperty)
+	}
+
+	if s.Name == "" {
+		return fmt.Errorf("%w: name is not set", ErrInvalidProperty)
+	}
+
+	if !propertyTypes[s.Type] {
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidProperty, s.Type)
+	}
+
+	if s.Constraints != nil && s.Constraints.Pattern != nil {
+		if _, err := regexp.Compile(*s.Constraints.Pattern); err != nil {
+			return fmt.Errorf("%w: invalid pattern: %v", ErrInvalidProperty, err)
+		}
+	}
+
+	if s.Required && s.DefaultValue == nil {
+		return fmt.Errorf("%w: required property %s has no default value", ErrRequiredProperty, s.Name)
+	}
+
+	if s.DefaultValue != nil {
+		if _, _, err := s.Parse(*s.DefaultValue); err != nil {
+			return fmt.Errorf("invalid default value: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Parse parses the value of the property and checks the constraints, the canonical string form is stored
+func (s PropertySchema) Parse(value string) (typed any, canonical string, err error) {
+	typed, canonical, err = ParsePropertyValue(s.Type, value)
+	if err != nil {
+		return nil, "", fmt.Errorf("property %s: %w", s.Name, err)
+	}
+
+	c := s.Constraints
+	if c == nil {
+		return typed, canonical, nil
+	}
+
+	var number *float64
+	switch t := typed.(type) {
+	case int64:
+		f := float64(t)
+		number = &f
+	case float64:
+		number = &t
+	case string:
+		length := utf8.RuneCountInString(t)
+		if c.MinLength != nil && length < *c.MinLength {
+			return nil, "", fmt.Errorf("%w: property %s is shorter than %d characters", ErrInvalidProperty, s.Name, *c.MinLength)
+		}
+		if c.MaxLength != nil && length > *c.MaxLength {
+			return nil, "", fmt.Errorf("%w: property %s is longer than %d characters", ErrInvalidProperty, s.Name, *c.MaxLength)
+		}
+		if c.Pattern != nil {
+			if matched, err := regexp.MatchString(*c.Pattern, t); err != nil || !matched {
+				return nil, "", fmt.Errorf("%w: property %s does not match %q", ErrInvalidProperty, s.Name, *c.Pattern)
+			}
+		}
+	}
+
+	if number != nil {
+		if c.Min != nil && *number < *c.Min {
+			return nil, "", fmt.Errorf("%w: property %s is less than %v", ErrInvalidProperty, s.Name, *c.Min)
+		}
+		if c.Max != nil && *number > *c.Max {
+			return nil, "", fmt.Errorf("%w: property %s is greater than %v", ErrInvalidProperty, s.Name, *c.Max)
+		}
+	}
+
+	if len(c.Enum) > 0 && (number != nil || s.Type == PropertyTypeString) {
+		allowed := false
+		for _, e := range c.Enum {
+			if _, ec, err := ParsePropertyValue(s.Type, e); err == nil && ec == canonical {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return nil, "", fmt.Errorf("%w: property %s is not one of %v", ErrInvalidProperty, s.Name, c.Enum)
+		}
+	}
+
+	return typed, canonical, nil
+}
+
+// ValidateProperties validates the properties against the schemas of the entity type. Properties without a schema are
+// validated by their own type, properties with a schema take the schema type.
+func ValidateProperties(schemas []PropertySchema, properties []InsertProperty) (typed []TypedProperty, err error) {
+	byName := make(map[string]PropertySchema, len(schemas))
+	for _, s := range schemas {
+		byName[s.Name] = s
+	}
+
+	seen := map[string]bool{}
+	for _, p := range properties {
+		if p.Name == "" {
+			return nil, fmt.Errorf("%w: name is not set", ErrInvalidProperty)
+		}
+
+		if seen[p.Name] {
+			return nil, fmt.Errorf("%w: duplicate property %s", ErrInvalidProperty, p.Name)
+		}
+		seen[p.Name] = true
+
+		schema, ok := byName[p.Name]
+		if !ok {
+			if p.Type == "" {
+				p.Type = PropertyTypeString
+			}
+			schema = PropertySchema{Name: p.Name, Type: p.Type}
+		} else if p.Type != "" && p.Type != schema.Type {
+			return nil, fmt.Errorf("%w: property %s is %s, not %s", ErrInvalidProperty, p.Name, schema.Type, p.Type)
+		}
+
+		value, canonical, err := schema.Parse(p.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		typed = append(typed, TypedProperty{Property: Property{Type: schema.Type, Name: p.Name, Value: canonical}, TypedValue: value})
+	}
+
+	return typed, nil
+}
+
+// withPropertyDefaults parses stored properties and adds schema defaults of the properties that are not set
+func withPropertyDefaults(schemas []PropertySchema, properties []Property) []TypedProperty {
+	typed := make([]TypedProperty, 0, len(properties))
+	set := map[string]bool{}
+	for _, p := range properties {
+		set[p.Name] = true
+		value, _, err := ParsePropertyValue(p.Type, p.Value)
+		if err != nil {
+			// values stored before the schema was declared are returned as strings
+			value = p.Value
+		}
+		typed = append(typed, TypedProperty{Property: p, TypedValue: value})
+	}
+
+	for _, s := range schemas {
+		if set[s.Name] || s.DefaultValue == nil {
+			continue
+		}
+		value, canonical, err := ParsePropertyValue(s.Type, *s.DefaultValue)
+		if err != nil {
+			continue
+		}
+		typed = append(typed, TypedProperty{Property: Property{Type: s.Type, Name: s.Name, Value: canonical}, TypedValue: value, Default: true})
+	}
+
+	return typed
+}
+
+const propertySchemaColumns = `s.id,
+       s.created_at,
+       s.updated_at,
+       s.entity_type,
+       s.name,
+       s.type,
+       s.default_value,
+       s.constraints,
+       s.required,
+       s.description`
+
+func scanPropertySchema(row pgx.Row) (s *PropertySchema, err error) {
+	var (
+		id                   pgtypeuuid.UUID
+		createdAt, updatedAt pgtype.Timestamp
+		entityType           pgtype.Text
+		name                 pgtype.Text
+		propertyType         pgtype.Text
+		defaultValue         pgtype.Text
+		constraints          []byte
+		required             pgtype.Bool
+		description          pgtype.Text
+	)
+
+	err = row.Scan(&id, &createdAt, &updatedAt, &entityType, &name, &propertyType, &defaultValue, &constraints, &required, &description)
+	if err != nil {
+		return nil, err
+	}
+
+	s = &PropertySchema{EntityType: entityType.String, Name: name.String, Type: propertyType.String, Required: required.Bool}
+	s.Id = id.UUID
+	if createdAt.Status == pgtype.Present {
+		s.CreatedAt = createdAt.Time
+	}
+	if updatedAt.Status == pgtype.Present {
+		s.UpdatedAt = &updatedAt.Time
+	}
+	if defaultValue.Status == pgtype.Present {
+		s.DefaultValue = &defaultValue.String
+	}
+	if len(constraints) > 0 {
+		s.Constraints = &PropertyConstraints{}
+		if err = json.Unmarshal(constraints, s.Constraints); err != nil {
+			return nil, fmt.Errorf("invalid constraints of property %s: %w", s.Name, err)
+		}
+	}
+	if description.Status == pgtype.Present {
+		s.Description = &description.String
+	}
+
+	return s, nil
+}
+
+// SetPropertySchema creates or replaces the declaration of the property of the entity type
+func SetPropertySchema(ctx context.Context, requester *User, schema PropertySchema) (result *PropertySchema, err error) {
+	if requester == nil || requester.Id == uuid.Nil {
+		return nil, ErrNoRequester
+	}
+
+	if !requester.IsAdmin {
+		return nil, ErrNoPermission
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return nil, ErrNoDatabase
+	}
+
+	if err = schema.Validate(); err != nil {
+		return nil, err
+	}
+
+	var constraints []byte
+	if schema.Constraints != nil {
+		if constraints, err = json.Marshal(schema.Constraints); err != nil {
+			return nil, err
+		}
+	}
+
+	q := `insert into property_schema_v2 as s (entity_type, name, type, default_value, constraints, required, description)
+values ($1, $2, $3, $4, $5, $6, $7)
+on conflict (entity_type, name) do update set type          = excluded.type,
+                                              default_value = excluded.default_value,
+                                              constraints   = excluded.constraints,
+                                              required      = excluded.required,
+                                              description   = excluded.description,
+                                              updated_at    = now()
+returning ` + propertySchemaColumns
+
+	result, err = scanPropertySchema(db.QueryRow(ctx, q, schema.EntityType, schema.Name, schema.Type, schema.DefaultValue, constraints, schema.Required, schema.Description))
+	if err != nil {
+		return nil, fmt.Errorf("failed to set property schema: %w", err)
+	}
+
+	return result, nil
+}
+
+// DeletePropertySchema removes the declaration of the property, values of the property are kept
+func DeletePropertySchema(ctx context.Context, requester *User, entityType string, name string) (err error) {
+	if requester == nil || requester.Id == uuid.Nil {
+		return ErrNoRequester
+	}
+
+	if !requester.IsAdmin {
+		return ErrNoPermission
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return ErrNoDatabase
+	}
+
+	tag, err := db.Exec(ctx, `delete from property_schema_v2 where entity_type = $1 and name = $2`, entityType, name)
+	if err != nil {
+		return fmt.Errorf("failed to delete property schema: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNoRows
+	}
+
+	return nil
+}
+
+// IndexPropertySchemas returns the declared properties of the entity type ordered by name
+func IndexPropertySchemas(ctx context.Context, requester *User, entityType string) (schemas []PropertySchema, err error) {
+	if requester == nil || requester.Id == uuid.Nil {
+		return nil, ErrNoRequester
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return nil, ErrNoDatabase
+	}
+
+	return getPropertySchemas(ctx, db, entityType)
+}
+
+func getPropertySchemas(ctx context.Context, db *pgxpool.Pool, entityType string) (schemas []PropertySchema, err error) {
+	rows, err := db.Query(ctx, `select `+propertySchemaColumns+` from property_schema_v2 s where s.entity_type = $1 order by s.name`, entityType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get property schemas: %w", err)
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var s *PropertySchema
+		s, err = scanPropertySchema(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get property schemas: %w", err)
+		}
+		schemas = append(schemas, *s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get property schemas: %w", err)
+	}
+
+	return schemas, nil
+}
@@ -1,6 +1,476 @@
 package model
 
+import (
+	"context"
+	glContext "dev.hackerman.me/artheon/veverse-shared/context"
+	"fmt"
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgtype"
+	pgtypeuuid "github.com/jackc/pgtype/ext/gofrs-uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Region is a deployment region hosting game servers and pixel streaming instances
 type Region struct {
 	Identifier
-	Name string `json:"name"`
+	Timestamps
+
+	Name      string   `json:"name"`
+	Latitude  *float64 `json:"latitude,omitempty"`  // geo latitude of the region datacenter (optional)
+	Longitude *float64 `json:"longitude,omitempty"` // geo longitude of the region datacenter (optional)
+	ProbeHost string   `json:"probeHost,omitempty"` // host clients ping to measure the region latency
+	ProbePort uint16   `json:"probePort,omitempty"` // port clients ping to measure the region latency
+	Enabled   bool     `json:"enabled"`             // disabled regions are never selected for new servers or sessions
+}
+
+func (r *Region) String() string {
+	var out = r.Identifier.String()
+	out += r.Timestamps.String()
+	out += fmt.Sprintf("name: %v, ", r.Name)
+	if r.Latitude != nil {
+		out += fmt.Sprintf("latitude: %v, ", *r.Latitude)
+	}
+	if r.Longitude != nil {
+		out += fmt.Sprintf("longitude: %v, ", *r.Longitude)
+	}
+	out += fmt.Sprintf("probeHost: %v, ", r.ProbeHost)
+	out += fmt.Sprintf("probePort: %v, ", r.ProbePort)
+	out += fmt.Sprintf("enabled: %v, ", r.Enabled)
+	return out
+}
+
+type RegionBatch Batch[Region]
+
+// RegionPing is a client-reported latency measurement to the region probe endpoint
+type RegionPing struct {
+	RegionId uuid.UUID `json:"regionId"`
+	Latency  int64     `json:"latency"` // round trip time in milliseconds
+}
+
+type IndexRegionRequest struct {
+	Offset  *int64 `json:"offset,omitempty"`
+	Limit   *int64 `json:"limit,omitempty"`
+	Enabled *bool  `json:"enabled,omitempty"` // filter by the enabled flag (optional)
+}
+
+func IndexRegion(ctx context.Context, requester *User, request IndexRegionRequest) (entities *RegionBatch, err error) {
+	if requester == nil || requester.Id == uuid.Nil {
+		return nil, ErrNoRequester
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return nil, ErrNoDatabase
+	}
+
+	var batch = RegionBatch{
+		Offset: 0,
+		Limit:  100,
+		Total:  0,
+	}
+
+	if request.Offset != nil && *request.Offset >= 0 {
+		batch.Offset = *request.Offset
+	}
+
+	if request.Limit != nil && *request.Limit > 0 && *request.Limit <= 100 {
+		batch.Limit = *request.Limit
+	}
+
+	var (
+		qt      string
+		q       string
+		qArgs   = make([]any, 0)
+		qArgNum = 0
+		rows    pgx.Rows
+	)
+
+	qt = `select count(*) from region r`
+	q = `select r.id, r.created_at, r.updated_at, r.name, r.latitude, r.longitude, r.probe_host, r.probe_port, r.enabled from region r`
+
+	if request.Enabled != nil {
+		qArgNum++
+		qArgs = append(qArgs, *request.Enabled)
+		qt += ` where r.enabled = $` + strconv.Itoa(qArgNum)
+		q += ` where r.enabled = $` + strconv.Itoa(qArgNum)
+	}
+
+	err = db.QueryRow(ctx, qt, qArgs...).Scan(&batch.Total)
+	if err != nil {
+		return nil, err
+	}
+
+	q += ` order by r.name offset $` + strconv.Itoa(qArgNum+1) + ` limit $` + strconv.Itoa(qArgNum+2)
+	qArgs = append(qArgs, batch.Offset, batch.Limit)
+
+	rows, err = db.Query(ctx, q, qArgs...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var region *Region
+		region, err = scanRegion(rows)
+		if err != nil {
+			return nil, err
+		}
+		if region != nil {
+			batch.Entities = append(batch.Entities, *region)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &batch, nil
+}
+
+func GetRegion(ctx context.Context, requester *User, id uuid.UUID) (region *Region, err error) {
+	if requester == nil {
+		return nil, ErrNoRequester
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return nil, ErrNoDatabase
+	}
+
+	q := `select r.id, r.created_at, r.updated_at, r.name, r.latitude, r.longitude, r.probe_host, r.probe_port, r.enabled from region r where r.id = $1`
+
+	rows, err := db.Query(ctx, q, id)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	if rows.Next() {
+		region, err = scanRegion(rows)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if region == nil {
+		return nil, ErrNoRows
+	}
+
+	return region, nil
+}
+
+type CreateRegionRequest struct {
+	Name      string   `json:"name"`                // name of the region (required)
+	Latitude  *float64 `json:"latitude,omitempty"`  // geo latitude (optional)
+	Longitude *float64 `json:"longitude,omitempty"` // geo longitude (optional)
+	ProbeHost string   `json:"probeHost,omitempty"` // latency probe host (optional)
+	ProbePort uint16   `json:"probePort,omitempty"` // latency probe port (optional)
+	Enabled   bool     `json:"enabled"`             // available for the region selection
+}
+
+func CreateRegion(ctx context.Context, requester *User, request CreateRegionRequest) (region *Region, err error) {
+	if requester == nil {
+		return nil, ErrNoRequester
+	}
+
+	if !requester.IsAdmin {
+		return nil, ErrNoPermission
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return nil, ErrNoDatabase
+	}
+
+	if request.Name == "" {
+		return nil, fmt.Errorf("name is not set")
+	}
+
+	if err = validateRegionCoordinates(request.Latitude, request.Longitude); err != nil {
+		return nil, err
+	}
+
+	q := `insert into region (id, created_at, name, latitude, longitude, probe_host, probe_port, enabled)
+values (gen_random_uuid(), now(), $1, $2, $3, $4, $5, $6)
+returning id`
+
+	var id pgtypeuuid.UUID
+	err = db.QueryRow(ctx, q, request.Name, request.Latitude, request.Longitude, request.ProbeHost, int32(request.ProbePort), request.Enabled).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create region: %w", err)
+	}
+
+	return GetRegion(ctx, requester, id.UUID)
+}
+
+type UpdateRegionRequest struct {
+	Name      *string  `json:"name,omitempty"`
+	Latitude  *float64 `json:"latitude,omitempty"`
+	Longitude *float64 `json:"longitude,omitempty"`
+	ProbeHost *string  `json:"probeHost,omitempty"`
+	ProbePort *uint16  `json:"probePort,omitempty"`
+	Enabled   *bool    `json:"enabled,omitempty"`
+}
+
+func UpdateRegion(ctx context.Context, requester *User, id uuid.UUID, request UpdateRegionRequest) (region *Region, err error) {
+	if requester == nil {
+		return nil, ErrNoRequester
+	}
+
+	if !requester.IsAdmin {
+		return nil, ErrNoPermission
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return nil, ErrNoDatabase
+	}
+
+	if request.Name != nil && *request.Name == "" {
+		return nil, fmt.Errorf("name is empty")
+	}
+
+	if err = validateRegionCoordinates(request.Latitude, request.Longitude); err != nil {
+		return nil, err
+	}
+
+	var probePort *int32
+	if request.ProbePort != nil {
+		p := int32(*request.ProbePort)
+		probePort = &p
+	}
+
+	q := `update region
+set name       = coalesce($2, name),
+    latitude   = coalesce($3, latitude),
+    longitude  = coalesce($4, longitude),
+    probe_host = coalesce($5, probe_host),
+    probe_port = coalesce($6, probe_port),
+    enabled    = coalesce($7, enabled),
+    updated_at = now()
+where id = $1`
+
+	tag, err := db.Exec(ctx, q, id, request.Name, request.Latitude, request.Longitude, request.ProbeHost, probePort, request.Enabled)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update region: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return nil, ErrNoRows
+	}
+
+	return GetRegion(ctx, requester, id)
+}
+
+func DeleteRegion(ctx context.Context, requester *User, id uuid.UUID) (err error) {
+	if requester == nil {
+		return ErrNoRequester
+	}
+
+	if !requester.IsAdmin {
+		return ErrNoPermission
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return ErrNoDatabase
+	}
+
+	tag, err := db.Exec(ctx, `delete from region where id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete region: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNoRows
+	}
+
+	return nil
+}
+
+type SelectRegionRequest struct {
+	Pings []RegionPing `json:"pings,omitempty"` // client-reported latency measurements (optional)
+}
+
+// SelectRegion returns the best enabled region for the requester. Client-reported pings are preferred, if there are no
+// usable pings the region nearest to User.GeoLocation is used, otherwise the first enabled region is returned.
+func SelectRegion(ctx context.Context, requester *User, request SelectRegionRequest) (region *Region, err error) {
+	if requester == nil {
+		return nil, ErrNoRequester
+	}
+
+	var enabled = true
+
+	// regions are rarely counted in hundreds, so the first page is enough
+	regions, err := IndexRegion(ctx, requester, IndexRegionRequest{Enabled: &enabled})
+	if err != nil {
+		return nil, fmt.Errorf("failed to index regions: %w", err)
+	}
+
+	region = PickRegion(regions.Entities, request.Pings, requester.GeoLocation)
+	if region == nil {
+		return nil, ErrNoRegion
+	}
+
+	return region, nil
+}
+
+// PickRegion selects the best region from the list. Only enabled regions are considered. The region with the lowest
+// positive latency wins, then the region nearest to the geo location formatted as "latitude,longitude", then the first
+// enabled region.
+func PickRegion(regions []Region, pings []RegionPing, geoLocation *string) *Region {
+	var (
+		best        *Region
+		bestLatency int64 = math.MaxInt64
+	)
+
+	for _, p := range pings {
+		if p.Latency <= 0 || p.Latency >= bestLatency {
+			continue
+		}
+		for i := range regions {
+			if regions[i].Id == p.RegionId && regions[i].Enabled {
+				best = &regions[i]
+				bestLatency = p.Latency
+				break
+			}
+		}
+	}
+
+	if best != nil {
+		return best
+	}
+
+	if lat, lon, ok := parseGeoLocation(geoLocation); ok {
+		var bestDistance = math.MaxFloat64
+		for i := range regions {
+			if !regions[i].Enabled || regions[i].Latitude == nil || regions[i].Longitude == nil {
+				continue
+			}
+			d := haversineDistance(lat, lon, *regions[i].Latitude, *regions[i].Longitude)
+			if d < bestDistance {
+				best = &regions[i]
+				bestDistance = d
+			}
+		}
+	}
+
+	if best != nil {
+		return best
+	}
+
+	for i := range regions {
+		if regions[i].Enabled {
+			return &regions[i]
+		}
+	}
+
+	return nil
+}
+
+func validateRegionCoordinates(latitude *float64, longitude *float64) error {
+	if latitude != nil && (*latitude < -90 || *latitude > 90) {
+		return fmt.Errorf("latitude is out of range")
+	}
+	if longitude != nil && (*longitude < -180 || *longitude > 180) {
+		return fmt.Errorf("longitude is out of range")
+	}
+	return nil
+}
+
+// parseGeoLocation parses the "latitude,longitude" string
+func parseGeoLocation(geoLocation *string) (lat float64, lon float64, ok bool) {
+	if geoLocation == nil {
+		return 0, 0, false
+	}
+
+	parts := strings.Split(*geoLocation, ",")
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+
+	lat, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return 0, 0, false
+	}
+
+	lon, err = strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil || lon < -180 || lon > 180 {
+		return 0, 0, false
+	}
+
+	return lat, lon, true
+}
+
+// haversineDistance returns the great-circle distance between two points in kilometers
+func haversineDistance(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadius = 6371.0
+	const rad = math.Pi / 180
+
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return 2 * earthRadius * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+func scanRegion(rows pgx.Rows) (region *Region, err error) {
+	var (
+		id        pgtypeuuid.UUID
+		createdAt pgtype.Timestamptz
+		updatedAt pgtype.Timestamptz
+		name      pgtype.Text
+		latitude  pgtype.Float8
+		longitude pgtype.Float8
+		probeHost pgtype.Text
+		probePort pgtype.Int4
+		enabled   pgtype.Bool
+	)
+
+	err = rows.Scan(&id, &createdAt, &updatedAt, &name, &latitude, &longitude, &probeHost, &probePort, &enabled)
+	if err != nil {
+		return nil, err
+	}
+
+	if id.Status != pgtype.Present {
+		return nil, nil
+	}
+
+	region = &Region{}
+	region.Id = id.UUID
+	if createdAt.Status == pgtype.Present {
+		region.CreatedAt = createdAt.Time
+	}
+	if updatedAt.Status == pgtype.Present {
+		region.UpdatedAt = &updatedAt.Time
+	}
+	if name.Status == pgtype.Present {
+		region.Name = name.String
+	}
+	if latitude.Status == pgtype.Present {
+		region.Latitude = &latitude.Float
+	}
+	if longitude.Status == pgtype.Present {
+		region.Longitude = &longitude.Float
+	}
+	if probeHost.Status == pgtype.Present {
+		region.ProbeHost = probeHost.String
+	}
+	if probePort.Status == pgtype.Present {
+		region.ProbePort = uint16(probePort.Int)
+	}
+	if enabled.Status == pgtype.Present {
+		region.Enabled = enabled.Bool
+	}
+
+	return region, nil
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	glContext "dev.hackerman.me/artheon/veverse-shared/context"
 	"dev.hackerman.me/artheon/veverse-shared/helper"
+	"dev.hackerman.me/artheon/veverse-shared/semver"
 	"fmt"
 	"github.com/gofrs/uuid"
 	"github.com/jackc/pgtype"
@@ -414,28 +415,33 @@ type LatestReleaseRequestOptions struct {
 
 type GetLatestReleaseRequest struct {
 	AppId   uuid.UUID
+	Channel string                       `json:"channel,omitempty"` // release channel name (optional), public requests only receive fully rolled out releases of non-internal channels
 	Options *LatestReleaseRequestOptions `json:"options"`
 }
 
-func GetLatestReleaseV2Public(ctx context.Context, request GetLatestReleaseRequest) (release *ReleaseV2, err error) {
-	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
-	if !ok || db == nil {
-		return nil, ErrNoDatabase
+func (o *LatestReleaseRequestOptions) validate() error {
+	if o == nil || !o.Files {
+		return nil
 	}
 
-	// validate request options
-	if request.Options != nil {
-		if request.Options.Files {
-			if request.Options.FileOptions == nil {
-				return nil, fmt.Errorf("missing file options")
-			} else if request.Options.FileOptions.Platform == "" {
-				return nil, fmt.Errorf("missing file options platform")
-			} else if request.Options.FileOptions.Target == "" {
-				return nil, fmt.Errorf("missing file options target")
-			}
-		}
+	if o.FileOptions == nil {
+		return fmt.Errorf("missing file options")
+	} else if o.FileOptions.Platform == "" {
+		return fmt.Errorf("missing file options platform")
+	} else if o.FileOptions.Target == "" {
+		return fmt.Errorf("missing file options target")
 	}
 
+	return nil
+}
+
+func GetLatestReleaseV2Public(ctx context.Context, request GetLatestReleaseRequest) (release *ReleaseV2, err error) {
+	return getLatestReleaseV2(ctx, nil, request)
+}
+
+// getReleaseV2WithOptions returns the release with its files and owner requested by options, files are filtered by the
+// platform and the deployment target
+func getReleaseV2WithOptions(ctx context.Context, db *pgxpool.Pool, releaseId uuid.UUID, options *LatestReleaseRequestOptions) (release *ReleaseV2, err error) {
 	var (
 		q       string
 		qArgs   = make([]any, 0)
@@ -445,12 +451,12 @@ func GetLatestReleaseV2Public(ctx context.Context, request GetLatestReleaseReque
 
 	// build query
 	q = `select r.id, e.created_at, e.updated_at, e.views, e.public, r.version, r.code_version, r.content_version, r.name, r.description, r.archive` // 11
-	if request.Options != nil {
-		if request.Options.Owner {
+	if options != nil {
+		if options.Owner {
 			// add owner id and name
 			q += `, u.id, u.name, u.description, u.eth_address, u.is_banned` // 16 (+5)
 		}
-		if request.Options.Files {
+		if options.Files {
 			// add release file columns
 			q += `, f.id, f.entity_id, f.type, f.url, f.mime, f.size, f.version, f.deployment_type, f.platform, f.uploaded_by, f.created_at, f.updated_at, f.variation, f.original_path, f.hash` // 31 (+15)
 		}
@@ -458,30 +464,29 @@ func GetLatestReleaseV2Public(ctx context.Context, request GetLatestReleaseReque
 
 	// query from
 	q += ` from release_v2 r left join entities e on r.id = e.id`
-	if request.Options != nil {
-		if request.Options.Owner {
+	if options != nil {
+		if options.Owner {
 			q += ` left join accessibles a on e.id = a.entity_id and a.is_owner`
 			q += ` left join users u on a.user_id = u.id`
 		}
-		if request.Options.Files {
+		if options.Files {
 			q += ` left join files f on e.id = f.entity_id and (f.type = 'release' or f.type = 'release-archive' or f.type = 'release-archive-sdk')`
 		}
 	}
 
 	// query where
 	qArgNum++
-	qArgs = append(qArgs, request.AppId)
-	q += ` where r.entity_id = $` + strconv.Itoa(qArgNum)
-	q += ` and r.version = (select max(version) from release_v2 ri left join entities ei on ei.id = ri.id where ri.entity_id = $` + strconv.Itoa(qArgNum) + ` and ei.public)`
+	qArgs = append(qArgs, releaseId)
+	q += ` where r.id = $` + strconv.Itoa(qArgNum)
 
-	if request.Options != nil {
-		if request.Options.Files && request.Options.FileOptions != nil {
+	if options != nil {
+		if options.Files && options.FileOptions != nil {
 			qArgNum++
-			qArgs = append(qArgs, request.Options.FileOptions.Target)
+			qArgs = append(qArgs, options.FileOptions.Target)
 			q += ` and f.deployment_type = $` + strconv.Itoa(qArgNum)
 
 			qArgNum++
-			qArgs = append(qArgs, request.Options.FileOptions.Platform)
+			qArgs = append(qArgs, options.FileOptions.Platform)
 			q += ` and f.platform = $` + strconv.Itoa(qArgNum)
 		}
 	}
@@ -547,12 +552,12 @@ func GetLatestReleaseV2Public(ctx context.Context, request GetLatestReleaseReque
 			&ownerId, &ownerName, &ownerDescription, &ownerEthAddress, &ownerIsBanned,
 		}
 
-		if request.Options != nil {
-			if request.Options.Owner {
+		if options != nil {
+			if options.Owner {
 				allFields = append(allFields, ownerFields...)
 			}
 
-			if request.Options.Files {
+			if options.Files {
 				allFields = append(allFields, fileFields...)
 			}
 		}
@@ -701,3 +706,214 @@ func GetLatestReleaseV2Public(ctx context.Context, request GetLatestReleaseReque
 
 	return release, nil
 }
+
+// getLatestReleaseV2Id returns the id of the public release of the entity with the highest semantic version. Stable
+// releases are preferred over pre-releases, releases with invalid versions are ignored.
+func getLatestReleaseV2Id(ctx context.Context, db *pgxpool.Pool, entityId uuid.UUID) (id uuid.UUID, err error) {
+	q := `select r.id, r.version from release_v2 r left join entities e on e.id = r.id where r.entity_id = $1 and e.public`
+
+	rows, err := db.Query(ctx, q, entityId)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	defer rows.Close()
+
+	var (
+		found          = false
+		latest         semver.Version
+		latestStable   = false
+		releaseId      pgtypeuuid.UUID
+		releaseVersion pgtype.Text
+	)
+
+	for rows.Next() {
+		err = rows.Scan(&releaseId, &releaseVersion)
+		if err != nil {
+			return uuid.Nil, err
+		}
+
+		if releaseId.Status != pgtype.Present || releaseVersion.Status != pgtype.Present {
+			continue
+		}
+
+		v, err := semver.Parse(releaseVersion.String)
+		if err != nil {
+			continue
+		}
+
+		stable := !v.IsPreRelease()
+		if !found || (stable && !latestStable) || (stable == latestStable && v.GreaterThan(latest)) {
+			found = true
+			latest = v
+			latestStable = stable
+			id = releaseId.UUID
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return uuid.Nil, err
+	}
+
+	if !found {
+		return uuid.Nil, ErrNoRows
+	}
+
+	return id, nil
+}
+
+type CreateReleaseV2Request struct {
+	EntityId       uuid.UUID `json:"entityId"`                 // parent entity (app, launcher or sdk) id (required)
+	Version        string    `json:"version"`                  // semantic version of the release (required)
+	CodeVersion    string    `json:"codeVersion,omitempty"`    // semantic code version (optional) (default: 1.0.0)
+	ContentVersion string    `json:"contentVersion,omitempty"` // semantic content version (optional) (default: 1.0.0)
+	Name           *string   `json:"name,omitempty"`           // name of the release (optional) (default: "Release " + Version)
+	Description    *string   `json:"description,omitempty"`    // description of the release (optional)
+	Archive        bool      `json:"archive"`                  // release is distributed as an archive (optional)
+	Public         bool      `json:"public"`                   // release is visible to everyone (optional)
+}
+
+// CreateReleaseV2 creates a new release of the parent entity, the requester must be able to edit the parent entity.
+// All versions must be valid semantic versions and the version must be unique for the parent entity.
+func CreateReleaseV2(ctx context.Context, requester *User, request CreateReleaseV2Request) (release *ReleaseV2, err error) {
+	if requester == nil {
+		return nil, ErrNoRequester
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return nil, ErrNoDatabase
+	}
+
+	if request.EntityId.IsNil() {
+		return nil, fmt.Errorf("entity id is not set")
+	}
+
+	canEdit, err := RequestCanEditEntity(ctx, requester, request.EntityId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check release parent permissions: %w", err)
+	}
+
+	if !canEdit {
+		return nil, ErrNoPermission
+	}
+
+	if request.CodeVersion == "" {
+		request.CodeVersion = "1.0.0"
+	}
+
+	if request.ContentVersion == "" {
+		request.ContentVersion = "1.0.0"
+	}
+
+	if _, err = semver.Parse(request.Version); err != nil {
+		return nil, fmt.Errorf("invalid release version: %w", err)
+	}
+
+	if _, err = semver.Parse(request.CodeVersion); err != nil {
+		return nil, fmt.Errorf("invalid release code version: %w", err)
+	}
+
+	if _, err = semver.Parse(request.ContentVersion); err != nil {
+		return nil, fmt.Errorf("invalid release content version: %w", err)
+	}
+
+	if request.Name == nil || *request.Name == "" {
+		name := "Release " + request.Version
+		request.Name = &name
+	}
+
+	if request.Description == nil {
+		description := ""
+		request.Description = &description
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	// lock the parent entity, so concurrent creates of the same version (also the first release) are serialized
+	q := `select id from entities where id = $1 for update`
+	var parentId pgtypeuuid.UUID
+	err = tx.QueryRow(ctx, q, request.EntityId).Scan(&parentId)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNoRows
+		}
+		return nil, fmt.Errorf("failed to lock release parent entity: %w", err)
+	}
+
+	// versions are compared semantically, so "1.0.0" and "1.0.0+build" are the same release
+	q = `select version from release_v2 where entity_id = $1`
+	rows, err := tx.Query(ctx, q, request.EntityId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get release versions: %w", err)
+	}
+
+	version := semver.MustParse(request.Version)
+	for rows.Next() {
+		var existing pgtype.Text
+		err = rows.Scan(&existing)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to get release versions: %w", err)
+		}
+		if v, err := semver.Parse(existing.String); err == nil && v.Equal(version) {
+			rows.Close()
+			return nil, ErrReleaseVersionExists
+		}
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get release versions: %w", err)
+	}
+
+	q = `with e as (insert into entities (id, entity_type, public) values (gen_random_uuid(), 'release-v2', $1) returning id)
+insert
+into release_v2 (id, entity_id, version, code_version, content_version, name, description, archive)
+select e.id, $2, $3, $4, $5, $6, $7, $8
+from e
+returning id`
+
+	var id pgtypeuuid.UUID
+	err = tx.QueryRow(ctx, q, request.Public, request.EntityId, request.Version, request.CodeVersion, request.ContentVersion, request.Name, request.Description, request.Archive).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create release: %w", err)
+	}
+
+	q = `insert into accessibles (entity_id, user_id, is_owner, can_view, can_edit, can_delete) values ($1, $2, true, true, true, true)`
+	_, err = tx.Exec(ctx, q, id.UUID, requester.Id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set release owner: %w", err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return GetReleaseV2(ctx, requester, id.UUID)
+}
+
+// IsReleaseV2CodeCompatible reports whether the client code version satisfies the version constraint (e.g. "^1.2") of
+// the release. If the constraint is empty, the caret range of the release code version is used, so clients are
+// compatible with releases of the same major code version.
+func IsReleaseV2CodeCompatible(clientCodeVersion string, release *ReleaseV2, constraint string) (bool, error) {
+	if release == nil {
+		return false, fmt.Errorf("no release")
+	}
+
+	if constraint == "" {
+		if _, err := semver.Parse(release.CodeVersion); err != nil {
+			return false, fmt.Errorf("invalid release code version: %w", err)
+		}
+		constraint = "^" + release.CodeVersion
+	}
+
+	return semver.Satisfies(clientCodeVersion, constraint)
+}
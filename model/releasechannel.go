@@ -0,0 +1,604 @@
+package model
+
+import (
+	"context"
+	"crypto/sha256"
+	glContext "dev.hackerman.me/artheon/veverse-shared/context"
+	"dev.hackerman.me/artheon/veverse-shared/semver"
+	"encoding/binary"
+	"fmt"
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgtype"
+	pgtypeuuid "github.com/jackc/pgtype/ext/gofrs-uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"sort"
+	"time"
+)
+
+const (
+	ReleaseChannelStable   = "stable"
+	ReleaseChannelBeta     = "beta"
+	ReleaseChannelInternal = "internal"
+)
+
+// DefaultReleaseChannels are created on demand when a release is promoted to them, other channels must be created explicitly
+var DefaultReleaseChannels = []string{ReleaseChannelStable, ReleaseChannelBeta, ReleaseChannelInternal}
+
+// ReleaseChannel is a named release track of an AppV2 or LauncherV2
+type ReleaseChannel struct {
+	Identifier
+	Timestamps
+
+	EntityId uuid.UUID               `json:"entityId"`           // parent app or launcher
+	Name     string                  `json:"name"`               // name of the channel, unique for the parent entity
+	Internal bool                    `json:"internal"`           // only internal users receive releases of the channel
+	Releases []ReleaseChannelRelease `json:"releases,omitempty"` // releases promoted to the channel
+}
+
+func (c *ReleaseChannel) String() string {
+	var out = c.Identifier.String()
+	out += c.Timestamps.String()
+	out += fmt.Sprintf("entityId: %v, ", c.EntityId)
+	out += fmt.Sprintf("name: %v, ", c.Name)
+	out += fmt.Sprintf("internal: %v, ", c.Internal)
+	return out
+}
+
+// ReleaseChannelRelease is a release promoted to a channel
+type ReleaseChannelRelease struct {
+	ReleaseId         uuid.UUID  `json:"releaseId"`
+	Version           string     `json:"version"`
+	RolloutPercentage int        `json:"rolloutPercentage"` // share of the channel users receiving the release, 0-100
+	PromotedAt        *time.Time `json:"promotedAt,omitempty"`
+	PromotedBy        *uuid.UUID `json:"promotedBy,omitempty"`
+}
+
+// ReleaseRolloutBucket returns the stable rollout bucket (0-99) of the user for the release. The release id is mixed in
+// so the same users are not always the first to receive new releases.
+func ReleaseRolloutBucket(userId uuid.UUID, releaseId uuid.UUID) int {
+	h := sha256.New()
+	h.Write(userId.Bytes())
+	h.Write(releaseId.Bytes())
+	sum := h.Sum(nil)
+	return int(binary.BigEndian.Uint64(sum[:8]) % 100)
+}
+
+// IsInReleaseRollout reports whether the user receives the release rolled out to the percentage of users. Increasing
+// the percentage keeps all users that already received the release.
+func IsInReleaseRollout(userId uuid.UUID, releaseId uuid.UUID, percentage int) bool {
+	if percentage >= 100 {
+		return true
+	}
+	if percentage <= 0 {
+		return false
+	}
+	return ReleaseRolloutBucket(userId, releaseId) < percentage
+}
+
+// PickReleaseChannelRelease returns the release with the highest semantic version the user is rolled out to. Without
+// a user only fully rolled out releases are considered. Releases with invalid versions are skipped.
+func PickReleaseChannelRelease(releases []ReleaseChannelRelease, userId *uuid.UUID) *ReleaseChannelRelease {
+	type candidate struct {
+		index   int
+		version semver.Version
+	}
+
+	var candidates []candidate
+	for i, r := range releases {
+		v, err := semver.Parse(r.Version)
+		if err != nil {
+			continue
+		}
+		candidates = append(candidates, candidate{i, v})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].version.GreaterThan(candidates[j].version)
+	})
+
+	for _, c := range candidates {
+		r := releases[c.index]
+		if r.RolloutPercentage >= 100 || (userId != nil && IsInReleaseRollout(*userId, r.ReleaseId, r.RolloutPercentage)) {
+			return &r
+		}
+	}
+
+	return nil
+}
+
+// IndexReleaseChannel returns channels of the app or launcher with their releases, internal channels are listed only
+// for internal users and users who can edit the parent entity
+func IndexReleaseChannel(ctx context.Context, requester *User, entityId uuid.UUID) (channels []ReleaseChannel, err error) {
+	if requester == nil {
+		return nil, ErrNoRequester
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return nil, ErrNoDatabase
+	}
+
+	includeInternal := requester.IsInternal
+	if !includeInternal {
+		includeInternal, err = RequestCanEditEntity(ctx, requester, entityId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	q := `select c.id, c.created_at, c.updated_at, c.entity_id, c.name, c.internal
+from release_channel c
+where c.entity_id = $1 and (not c.internal or $2)
+order by c.name`
+
+	rows, err := db.Query(ctx, q, entityId, includeInternal)
+	if err != nil {
+		return nil, err
+	}
+
+	for rows.Next() {
+		var channel *ReleaseChannel
+		channel, err = scanReleaseChannel(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if channel != nil {
+			channels = append(channels, *channel)
+		}
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range channels {
+		channels[i].Releases, err = getReleaseChannelReleases(ctx, db, channels[i].Id)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return channels, nil
+}
+
+type CreateReleaseChannelRequest struct {
+	EntityId uuid.UUID `json:"entityId"` // parent app or launcher id (required)
+	Name     string    `json:"name"`     // name of the channel (required)
+	Internal bool      `json:"internal"` // restrict the channel to internal users (optional) (always set for the internal channel)
+}
+
+// CreateReleaseChannel creates a release channel of the app or launcher, the requester must be able to edit the parent entity
+func CreateReleaseChannel(ctx context.Context, requester *User, request CreateReleaseChannelRequest) (channel *ReleaseChannel, err error) {
+	if requester == nil {
+		return nil, ErrNoRequester
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return nil, ErrNoDatabase
+	}
+
+	if request.EntityId.IsNil() {
+		return nil, fmt.Errorf("entity id is not set")
+	}
+
+	if request.Name == "" {
+		return nil, fmt.Errorf("name is not set")
+	}
+
+	canEdit, err := RequestCanEditEntity(ctx, requester, request.EntityId)
+	if err != nil {
+		return nil, err
+	}
+
+	if !canEdit {
+		return nil, ErrNoPermission
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	channel, err = createReleaseChannelTx(ctx, tx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return channel, nil
+}
+
+func createReleaseChannelTx(ctx context.Context, tx pgx.Tx, request CreateReleaseChannelRequest) (channel *ReleaseChannel, err error) {
+	// channels can be attached only to apps and launchers
+	var entityType pgtype.Text
+	err = tx.QueryRow(ctx, `select entity_type from entities where id = $1`, request.EntityId).Scan(&entityType)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNoRows
+		}
+		return nil, err
+	}
+
+	if entityType.String != "app-v2" && entityType.String != "launcher-v2" {
+		return nil, fmt.Errorf("release channels are not supported for %s entities", entityType.String)
+	}
+
+	if request.Name == ReleaseChannelInternal {
+		request.Internal = true
+	}
+
+	q := `insert into release_channel (entity_id, name, internal) values ($1, $2, $3)
+returning id, created_at, updated_at, entity_id, name, internal`
+
+	rows, err := tx.Query(ctx, q, request.EntityId, request.Name, request.Internal)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create release channel: %w", err)
+	}
+
+	defer rows.Close()
+	if rows.Next() {
+		channel, err = scanReleaseChannel(rows)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to create release channel: %w", err)
+	}
+
+	if channel == nil {
+		return nil, ErrNoRows
+	}
+
+	return channel, nil
+}
+
+// DeleteReleaseChannel deletes the channel, releases stay attached to the parent entity
+func DeleteReleaseChannel(ctx context.Context, requester *User, channelId uuid.UUID) (err error) {
+	if requester == nil {
+		return ErrNoRequester
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return ErrNoDatabase
+	}
+
+	var entityId pgtypeuuid.UUID
+	err = db.QueryRow(ctx, `select entity_id from release_channel where id = $1`, channelId).Scan(&entityId)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return ErrNoReleaseChannel
+		}
+		return err
+	}
+
+	canEdit, err := RequestCanEditEntity(ctx, requester, entityId.UUID)
+	if err != nil {
+		return err
+	}
+
+	if !canEdit {
+		return ErrNoPermission
+	}
+
+	_, err = db.Exec(ctx, `delete from release_channel where id = $1`, channelId)
+	if err != nil {
+		return fmt.Errorf("failed to delete release channel: %w", err)
+	}
+
+	return nil
+}
+
+type PromoteReleaseV2Request struct {
+	ReleaseId         uuid.UUID `json:"releaseId"`                   // release to promote (required)
+	Channel           string    `json:"channel"`                     // name of the target channel (required)
+	RolloutPercentage *int      `json:"rolloutPercentage,omitempty"` // share of the channel users receiving the release (optional) (default: 100)
+}
+
+// PromoteReleaseV2 adds the release to the channel of its parent entity or updates the rollout percentage if the release
+// is already in the channel. Default channels are created on the first promotion. Promotion publishes the release to
+// the channel audience regardless of the release public flag.
+func PromoteReleaseV2(ctx context.Context, requester *User, request PromoteReleaseV2Request) (err error) {
+	if requester == nil {
+		return ErrNoRequester
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return ErrNoDatabase
+	}
+
+	if request.Channel == "" {
+		return fmt.Errorf("channel is not set")
+	}
+
+	var rollout = 100
+	if request.RolloutPercentage != nil {
+		rollout = *request.RolloutPercentage
+		if rollout < 0 || rollout > 100 {
+			return fmt.Errorf("rollout percentage must be between 0 and 100")
+		}
+	}
+
+	var entityId pgtypeuuid.UUID
+	err = db.QueryRow(ctx, `select entity_id from release_v2 where id = $1`, request.ReleaseId).Scan(&entityId)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return ErrNoRows
+		}
+		return err
+	}
+
+	canEdit, err := RequestCanEditEntity(ctx, requester, entityId.UUID)
+	if err != nil {
+		return err
+	}
+
+	if !canEdit {
+		return ErrNoPermission
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	var channelId pgtypeuuid.UUID
+	err = tx.QueryRow(ctx, `select id from release_channel where entity_id = $1 and name = $2`, entityId.UUID, request.Channel).Scan(&channelId)
+	if err != nil {
+		if err != pgx.ErrNoRows {
+			return err
+		}
+
+		if !isDefaultReleaseChannel(request.Channel) {
+			return ErrNoReleaseChannel
+		}
+
+		var channel *ReleaseChannel
+		channel, err = createReleaseChannelTx(ctx, tx, CreateReleaseChannelRequest{EntityId: entityId.UUID, Name: request.Channel})
+		if err != nil {
+			return err
+		}
+		channelId.UUID = channel.Id
+	}
+
+	q := `insert into release_channel_release (channel_id, release_id, rollout_percentage, promoted_by) values ($1, $2, $3, $4)
+on conflict (channel_id, release_id) do update set rollout_percentage = excluded.rollout_percentage, promoted_at = now(), promoted_by = excluded.promoted_by`
+
+	_, err = tx.Exec(ctx, q, channelId.UUID, request.ReleaseId, rollout, requester.Id)
+	if err != nil {
+		return fmt.Errorf("failed to promote release: %w", err)
+	}
+
+	q = `update release_channel set updated_at = now() where id = $1`
+	_, err = tx.Exec(ctx, q, channelId.UUID)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// DemoteReleaseV2 removes the release from the channel, users of the channel fall back to the previous release
+func DemoteReleaseV2(ctx context.Context, requester *User, releaseId uuid.UUID, channel string) (err error) {
+	if requester == nil {
+		return ErrNoRequester
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return ErrNoDatabase
+	}
+
+	var entityId pgtypeuuid.UUID
+	err = db.QueryRow(ctx, `select entity_id from release_v2 where id = $1`, releaseId).Scan(&entityId)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return ErrNoRows
+		}
+		return err
+	}
+
+	canEdit, err := RequestCanEditEntity(ctx, requester, entityId.UUID)
+	if err != nil {
+		return err
+	}
+
+	if !canEdit {
+		return ErrNoPermission
+	}
+
+	q := `delete from release_channel_release cr using release_channel c
+where cr.channel_id = c.id and c.entity_id = $1 and c.name = $2 and cr.release_id = $3`
+
+	tag, err := db.Exec(ctx, q, entityId.UUID, channel, releaseId)
+	if err != nil {
+		return fmt.Errorf("failed to demote release: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNoRows
+	}
+
+	return nil
+}
+
+// GetLatestReleaseV2 returns the latest release of the app or launcher for the requester. If the request has a channel,
+// the release with the highest version the requester is rolled out to is returned, internal channels require an
+// internal user. Without a channel the result matches GetLatestReleaseV2Public.
+func GetLatestReleaseV2(ctx context.Context, requester *User, request GetLatestReleaseRequest) (release *ReleaseV2, err error) {
+	if requester == nil {
+		return nil, ErrNoRequester
+	}
+
+	return getLatestReleaseV2(ctx, requester, request)
+}
+
+func getLatestReleaseV2(ctx context.Context, requester *User, request GetLatestReleaseRequest) (release *ReleaseV2, err error) {
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return nil, ErrNoDatabase
+	}
+
+	if err = request.Options.validate(); err != nil {
+		return nil, err
+	}
+
+	if request.Channel == "" {
+		// latest release is selected by the semantic version precedence, text ordering would put 1.10.0 before 1.9.0
+		latestId, err := getLatestReleaseV2Id(ctx, db, request.AppId)
+		if err != nil {
+			if err == ErrNoRows {
+				return nil, nil
+			}
+			return nil, err
+		}
+
+		return getReleaseV2WithOptions(ctx, db, latestId, request.Options)
+	}
+
+	var (
+		channelId pgtypeuuid.UUID
+		internal  pgtype.Bool
+	)
+	err = db.QueryRow(ctx, `select id, internal from release_channel where entity_id = $1 and name = $2`, request.AppId, request.Channel).Scan(&channelId, &internal)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNoReleaseChannel
+		}
+		return nil, err
+	}
+
+	if internal.Bool && (requester == nil || !requester.IsInternal) {
+		return nil, ErrNoPermission
+	}
+
+	releases, err := getReleaseChannelReleases(ctx, db, channelId.UUID)
+	if err != nil {
+		return nil, err
+	}
+
+	var userId *uuid.UUID
+	if requester != nil {
+		userId = &requester.Id
+	}
+
+	picked := PickReleaseChannelRelease(releases, userId)
+	if picked == nil {
+		return nil, nil
+	}
+
+	return getReleaseV2WithOptions(ctx, db, picked.ReleaseId, request.Options)
+}
+
+func getReleaseChannelReleases(ctx context.Context, db *pgxpool.Pool, channelId uuid.UUID) (releases []ReleaseChannelRelease, err error) {
+	q := `select cr.release_id, r.version, cr.rollout_percentage, cr.promoted_at, cr.promoted_by
+from release_channel_release cr
+    left join release_v2 r on r.id = cr.release_id
+where cr.channel_id = $1
+order by cr.promoted_at desc`
+
+	rows, err := db.Query(ctx, q, channelId)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			releaseId  pgtypeuuid.UUID
+			version    pgtype.Text
+			rollout    pgtype.Int4
+			promotedAt pgtype.Timestamptz
+			promotedBy pgtypeuuid.UUID
+		)
+
+		err = rows.Scan(&releaseId, &version, &rollout, &promotedAt, &promotedBy)
+		if err != nil {
+			return nil, err
+		}
+
+		r := ReleaseChannelRelease{
+			ReleaseId:         releaseId.UUID,
+			Version:           version.String,
+			RolloutPercentage: int(rollout.Int),
+		}
+		if promotedAt.Status == pgtype.Present {
+			r.PromotedAt = &promotedAt.Time
+		}
+		if promotedBy.Status == pgtype.Present {
+			r.PromotedBy = &promotedBy.UUID
+		}
+		releases = append(releases, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return releases, nil
+}
+
+func isDefaultReleaseChannel(name string) bool {
+	for _, c := range DefaultReleaseChannels {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+func scanReleaseChannel(rows pgx.Rows) (channel *ReleaseChannel, err error) {
+	var (
+		id        pgtypeuuid.UUID
+		createdAt pgtype.Timestamptz
+		updatedAt pgtype.Timestamptz
+		entityId  pgtypeuuid.UUID
+		name      pgtype.Text
+		internal  pgtype.Bool
+	)
+
+	err = rows.Scan(&id, &createdAt, &updatedAt, &entityId, &name, &internal)
+	if err != nil {
+		return nil, err
+	}
+
+	if id.Status != pgtype.Present {
+		return nil, nil
+	}
+
+	channel = &ReleaseChannel{}
+	channel.Id = id.UUID
+	if createdAt.Status == pgtype.Present {
+		channel.CreatedAt = createdAt.Time
+	}
+	if updatedAt.Status == pgtype.Present {
+		channel.UpdatedAt = &updatedAt.Time
+	}
+	channel.EntityId = entityId.UUID
+	channel.Name = name.String
+	channel.Internal = internal.Bool
+
+	return channel, nil
+}
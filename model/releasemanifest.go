@@ -0,0 +1,288 @@
+package model
+
+import (
+	"context"
+	glContext "dev.hackerman.me/artheon/veverse-shared/context"
+	"fmt"
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgtype"
+	pgtypeuuid "github.com/jackc/pgtype/ext/gofrs-uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"path"
+	"sort"
+	"strings"
+)
+
+const (
+	FileTypeRelease           = "release"
+	FileTypeReleaseArchive    = "release-archive"     // archive of all release files, used by releases with the archive flag
+	FileTypeReleaseChunkIndex = "release-chunk-index" // chunk.Index of the release file with the same original path
+)
+
+// ReleaseManifestChange is a release file that differs between the installed and the target release
+type ReleaseManifestChange struct {
+	File               File  `json:"file"`                         // target file
+	Previous           File  `json:"previous"`                     // installed file
+	ChunkIndex         *File `json:"chunkIndex,omitempty"`         // chunk index of the target file, if uploaded the file can be patched
+	PreviousChunkIndex *File `json:"previousChunkIndex,omitempty"` // chunk index of the installed file, clients can also build it locally
+}
+
+// ReleaseManifestDiff lists files to download and delete to update an installed release to the target release
+type ReleaseManifestDiff struct {
+	InstalledReleaseId uuid.UUID               `json:"installedReleaseId"`
+	TargetReleaseId    uuid.UUID               `json:"targetReleaseId"`
+	Added              []File                  `json:"added"`
+	Changed            []ReleaseManifestChange `json:"changed"`
+	Removed            []File                  `json:"removed"`
+	Unchanged          int                     `json:"unchanged"`    // number of files that are kept as is
+	DownloadSize       int64                   `json:"downloadSize"` // full size of added and changed files, chunk patches reduce it further
+}
+
+// ReleaseManifestPath returns the normalized relative path of the release file used to match files between releases
+func ReleaseManifestPath(f File) string {
+	if f.OriginalPath == nil {
+		return ""
+	}
+	p := strings.ReplaceAll(*f.OriginalPath, "\\", "/")
+	p = path.Clean("/" + p)
+	return strings.TrimPrefix(p, "/")
+}
+
+// DiffReleaseManifest compares files of the installed and the target releases by their original paths. Files are
+// considered changed if their hashes differ, or if any of the hashes is missing and their sizes or urls differ.
+// Chunk index files are attached to the changed files and are not listed as separate files.
+func DiffReleaseManifest(installed []File, target []File) *ReleaseManifestDiff {
+	var (
+		diff             = &ReleaseManifestDiff{}
+		installedFiles   = map[string]File{}
+		installedIndexes = map[string]File{}
+		targetFiles      = map[string]File{}
+		targetIndexes    = map[string]File{}
+		targetPaths      []string
+	)
+
+	for _, f := range installed {
+		p := ReleaseManifestPath(f)
+		if p == "" {
+			continue
+		}
+		if f.Type == FileTypeReleaseChunkIndex {
+			installedIndexes[p] = f
+		} else {
+			installedFiles[p] = f
+		}
+	}
+
+	for _, f := range target {
+		p := ReleaseManifestPath(f)
+		if p == "" {
+			continue
+		}
+		if f.Type == FileTypeReleaseChunkIndex {
+			targetIndexes[p] = f
+		} else {
+			if _, ok := targetFiles[p]; !ok {
+				targetPaths = append(targetPaths, p)
+			}
+			targetFiles[p] = f
+		}
+	}
+
+	sort.Strings(targetPaths)
+
+	for _, p := range targetPaths {
+		f := targetFiles[p]
+		previous, ok := installedFiles[p]
+		if !ok {
+			diff.Added = append(diff.Added, f)
+			diff.DownloadSize += fileSize(f)
+			continue
+		}
+
+		if !isReleaseFileChanged(previous, f) {
+			diff.Unchanged++
+			continue
+		}
+
+		change := ReleaseManifestChange{File: f, Previous: previous}
+		if index, ok := targetIndexes[p]; ok {
+			change.ChunkIndex = &index
+		}
+		if index, ok := installedIndexes[p]; ok {
+			change.PreviousChunkIndex = &index
+		}
+		diff.Changed = append(diff.Changed, change)
+		diff.DownloadSize += fileSize(f)
+	}
+
+	var removedPaths []string
+	for p := range installedFiles {
+		if _, ok := targetFiles[p]; !ok {
+			removedPaths = append(removedPaths, p)
+		}
+	}
+	sort.Strings(removedPaths)
+	for _, p := range removedPaths {
+		diff.Removed = append(diff.Removed, installedFiles[p])
+	}
+
+	return diff
+}
+
+func isReleaseFileChanged(previous File, f File) bool {
+	if previous.Hash != nil && f.Hash != nil && *previous.Hash != "" && *f.Hash != "" {
+		return !strings.EqualFold(*previous.Hash, *f.Hash)
+	}
+	return fileSize(previous) != fileSize(f) || previous.Url != f.Url
+}
+
+func fileSize(f File) int64 {
+	if f.Size == nil {
+		return 0
+	}
+	return *f.Size
+}
+
+type GetReleaseV2ManifestDiffRequest struct {
+	InstalledReleaseId uuid.UUID `json:"installedReleaseId"` // release installed on the client (required)
+	TargetReleaseId    uuid.UUID `json:"targetReleaseId"`    // release to update to (required)
+	Platform           string    `json:"platform"`           // platform of the release files (required)
+	Deployment         string    `json:"deployment"`         // deployment type of the release files (required)
+}
+
+// GetReleaseV2ManifestDiff returns the files to download and delete to update the installed release to the target one,
+// both releases must belong to the same app or launcher
+func GetReleaseV2ManifestDiff(ctx context.Context, requester *User, request GetReleaseV2ManifestDiffRequest) (diff *ReleaseManifestDiff, err error) {
+	if requester == nil {
+		return nil, ErrNoRequester
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return nil, ErrNoDatabase
+	}
+
+	if request.Platform == "" {
+		return nil, fmt.Errorf("platform is not set")
+	}
+
+	if request.Deployment == "" {
+		return nil, fmt.Errorf("deployment is not set")
+	}
+
+	var entityIds = make([]uuid.UUID, 0, 2)
+	for _, releaseId := range []uuid.UUID{request.InstalledReleaseId, request.TargetReleaseId} {
+		var entityId uuid.UUID
+		entityId, err = requestCanGetReleaseV2Files(ctx, db, requester, releaseId)
+		if err != nil {
+			return nil, err
+		}
+		entityIds = append(entityIds, entityId)
+	}
+
+	if entityIds[0] != entityIds[1] {
+		return nil, fmt.Errorf("releases belong to different entities")
+	}
+
+	installed, err := getReleaseV2Files(ctx, db, request.InstalledReleaseId, request.Platform, request.Deployment)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := getReleaseV2Files(ctx, db, request.TargetReleaseId, request.Platform, request.Deployment)
+	if err != nil {
+		return nil, err
+	}
+
+	diff = DiffReleaseManifest(installed, target)
+	diff.InstalledReleaseId = request.InstalledReleaseId
+	diff.TargetReleaseId = request.TargetReleaseId
+
+	return diff, nil
+}
+
+// requestCanGetReleaseV2Files checks that the release files are available to the requester: the release is public,
+// rolled out to the requester in a channel available to the requester or the requester can view it. Staged rollouts
+// limit access to the users of the rollout. Returns the parent entity id.
+func requestCanGetReleaseV2Files(ctx context.Context, db *pgxpool.Pool, requester *User, releaseId uuid.UUID) (entityId uuid.UUID, err error) {
+	q := `select r.entity_id,
+       e.public,
+       (select max(cr.rollout_percentage) from release_channel_release cr join release_channel c on c.id = cr.channel_id where cr.release_id = r.id and (not c.internal or $2)),
+       exists(select 1 from accessibles a where a.entity_id = r.id and a.user_id = $3 and (a.is_owner or a.can_view))
+from release_v2 r
+         left join entities e on e.id = r.id
+where r.id = $1`
+
+	var (
+		id         pgtypeuuid.UUID
+		public     pgtype.Bool
+		rollout    pgtype.Int4
+		accessible pgtype.Bool
+	)
+
+	err = db.QueryRow(ctx, q, releaseId, requester.IsInternal, requester.Id).Scan(&id, &public, &rollout, &accessible)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return uuid.Nil, ErrNoRows
+		}
+		return uuid.Nil, err
+	}
+
+	// the rollout bucket of the user is the same in all channels, so the widest rollout decides
+	promoted := rollout.Status == pgtype.Present && IsInReleaseRollout(requester.Id, releaseId, int(rollout.Int))
+
+	if !requester.IsAdmin && !public.Bool && !promoted && !accessible.Bool {
+		return uuid.Nil, ErrNoPermission
+	}
+
+	return id.UUID, nil
+}
+
+// getReleaseV2Files returns the release files and chunk indexes for the platform and the deployment type
+func getReleaseV2Files(ctx context.Context, db *pgxpool.Pool, releaseId uuid.UUID, platform string, deployment string) (files []File, err error) {
+	q := `select f.id,
+       f.entity_id,
+       f.type,
+       f.url,
+       f.mime,
+       f.size,
+       f.version,
+       f.deployment_type,
+       f.platform,
+       f.uploaded_by,
+       f.width,
+       f.height,
+       f.created_at,
+       f.updated_at,
+       f.variation,
+       f.original_path,
+       f.hash
+from files f
+where f.entity_id = $1
+  and (f.type = $2 or f.type = $3)
+  and f.platform = $4
+  and f.deployment_type = $5
+order by f.original_path, f.created_at`
+
+	rows, err := db.Query(ctx, q, releaseId, FileTypeRelease, FileTypeReleaseChunkIndex, platform, deployment)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get release files: %w", err)
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var f File
+		err = rows.Scan(&f.Id, &f.EntityId, &f.Type, &f.Url, &f.Mime, &f.Size, &f.Version, &f.Deployment, &f.Platform, &f.UploadedBy, &f.Width, &f.Height, &f.CreatedAt, &f.UpdatedAt, &f.Index, &f.OriginalPath, &f.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get release files: %w", err)
+		}
+		files = append(files, f)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get release files: %w", err)
+	}
+
+	return files, nil
+}
@@ -73,6 +73,7 @@ type IndexWorldRequest struct {
 	Search  *string              `json:"search"`
 	Sort    []IndexRequestSort   `json:"sort"`
 	Options *WorldRequestOptions `json:"options"`
+	Mode    string               `json:"mode,omitempty"` // WorldIndexModeTrending or WorldIndexModeRecommended, applied before the sort (optional)
 }
 
 func IndexWorld(ctx context.Context, requester *User, request IndexWorldRequest) (batch *WorldBatch, err error) {
@@ -117,6 +118,10 @@ func IndexWorld(ctx context.Context, requester *User, request IndexWorldRequest)
 		}
 	}
 
+	if request.Mode != "" && request.Mode != WorldIndexModeTrending && request.Mode != WorldIndexModeRecommended {
+		return nil, fmt.Errorf("unknown index mode %q", request.Mode)
+	}
+
 	var (
 		qt        string                      // total query
 		qtArgs    = make([]any, 0)            // total query args
@@ -139,7 +144,7 @@ func IndexWorld(ctx context.Context, requester *User, request IndexWorldRequest)
 	if request.Options != nil {
 		if request.Options.Likes {
 			// add like columns
-			q += `, rl.value as liked, sum(case when l.value >= 0 then l.value end) as likes, sum(case when l.value < 0 then l.value end) as dislikes` // 16
+			q += `, rl.value as liked, e.likes as likes, -e.dislikes as dislikes` // 16
 		}
 		if request.Options.Preview {
 			// add preview file columns
@@ -164,7 +169,7 @@ func IndexWorld(ctx context.Context, requester *User, request IndexWorldRequest)
 			// add like join
 			qArgNum++
 			qArgs = append(qArgs, requester.Id)
-			q += ` left join likables l on w.id = l.entity_id left join likables rl on w.id = rl.entity_id and rl.user_id = $` + strconv.Itoa(qArgNum)
+			q += ` left join likables rl on w.id = rl.entity_id and rl.user_id = $` + strconv.Itoa(qArgNum)
 		}
 		if request.Options.Preview {
 			// add preview file join
@@ -184,6 +189,22 @@ func IndexWorld(ctx context.Context, requester *User, request IndexWorldRequest)
 		}
 	}
 
+	// add index mode joins
+	switch request.Mode {
+	case WorldIndexModeTrending:
+		q += ` left join world_trending_v2 wt on w.id = wt.world_id`
+		qSort = append(qSort, `wt.score desc nulls last`)
+	case WorldIndexModeRecommended:
+		// only recommended worlds are returned
+		qArgNum++
+		qArgs = append(qArgs, requester.Id)
+		qtArgNum++
+		qtArgs = append(qtArgs, requester.Id)
+		qt += ` join ` + worldRecommendations(`$`+strconv.Itoa(qtArgNum)) + ` wr on w.id = wr.world_id`
+		q += ` join ` + worldRecommendations(`$`+strconv.Itoa(qArgNum)) + ` wr on w.id = wr.world_id`
+		qSort = append(qSort, `wr.score desc`)
+	}
+
 	// query where
 	if !requester.IsAdmin {
 		// if the requester is not an admin, only show public entities and entities the requester has access to
@@ -232,6 +253,12 @@ func IndexWorld(ctx context.Context, requester *User, request IndexWorldRequest)
 			if request.Options.Owner {
 				q += `, u.id, u.name, u.description, u.eth_address, u.is_banned`
 			}
+			switch request.Mode {
+			case WorldIndexModeTrending:
+				q += `, wt.score`
+			case WorldIndexModeRecommended:
+				q += `, wr.score`
+			}
 		}
 	}
 
@@ -254,6 +281,8 @@ func IndexWorld(ctx context.Context, requester *User, request IndexWorldRequest)
 			}
 			qSort = append(qSort, column+" "+s.Direction)
 		}
+	}
+	if len(qSort) > 0 {
 		q += ` order by ` + strings.Join(qSort, ", ")
 	}
 
@@ -674,7 +703,7 @@ func GetWorld(ctx context.Context, requester *User, request GetWorldRequest) (wo
 	if request.Options != nil {
 		if request.Options.Likes {
 			// add like columns
-			q += `, rl.value as liked, sum(case when l.value >= 0 then l.value end) as likes, sum(case when l.value < 0 then l.value end) as dislikes` // 16 (+3)
+			q += `, rl.value as liked, e.likes as likes, -e.dislikes as dislikes` // 16 (+3)
 		}
 		if request.Options.Preview {
 			// add preview file columns
@@ -701,7 +730,7 @@ func GetWorld(ctx context.Context, requester *User, request GetWorldRequest) (wo
 			// add like join
 			qArgNum++
 			qArgs = append(qArgs, requester.Id)
-			q += ` left join likables l on e.id = l.entity_id left join likables rl on w.id = rl.entity_id and rl.user_id = $` + strconv.Itoa(qArgNum)
+			q += ` left join likables rl on w.id = rl.entity_id and rl.user_id = $` + strconv.Itoa(qArgNum)
 		}
 		if request.Options.Preview {
 			// add preview file joins
@@ -1158,3 +1187,317 @@ func GetWorld(ctx context.Context, requester *User, request GetWorldRequest) (wo
 
 	return world, nil
 }
+
+const worldColumns = `e.id,
+       e.created_at,
+       e.updated_at,
+       e.entity_type,
+       e.views,
+       e.public,
+       w.name,
+       w.description,
+       w.map,
+       w.mod_id,
+       w.type,
+       w.scheduled,
+       w.game_mode`
+
+// scanWorld scans worldColumns followed by the extra columns
+func scanWorld(row pgx.Row, extra ...any) (world *World, err error) {
+	var (
+		id                   pgtypeuuid.UUID
+		createdAt, updatedAt pgtype.Timestamptz
+		entityType           pgtype.Text
+		views                pgtype.Int4
+		public               pgtype.Bool
+		name                 pgtype.Text
+		description          pgtype.Text
+		worldMap             pgtype.Text
+		modId                pgtypeuuid.UUID
+		worldType            pgtype.Text
+		scheduled            pgtype.Bool
+		gameMode             pgtype.Text
+	)
+
+	err = row.Scan(append([]any{&id, &createdAt, &updatedAt, &entityType, &views, &public, &name, &description, &worldMap, &modId, &worldType, &scheduled, &gameMode}, extra...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	world = &World{}
+	world.Id = id.UUID
+	if createdAt.Status == pgtype.Present {
+		world.CreatedAt = createdAt.Time
+	}
+	if updatedAt.Status == pgtype.Present {
+		world.UpdatedAt = &updatedAt.Time
+	}
+	if entityType.Status == pgtype.Present {
+		world.EntityType = entityType.String
+	}
+	if views.Status == pgtype.Present {
+		world.Views = views.Int
+	}
+	if public.Status == pgtype.Present {
+		world.Public = public.Bool
+	}
+	if name.Status == pgtype.Present {
+		world.Name = name.String
+	}
+	if description.Status == pgtype.Present {
+		world.Description = &description.String
+	}
+	if worldMap.Status == pgtype.Present {
+		world.Map = worldMap.String
+	}
+	if modId.Status == pgtype.Present {
+		world.PackageId = modId.UUID
+	}
+	if worldType.Status == pgtype.Present {
+		world.Type = worldType.String
+	}
+	if scheduled.Status == pgtype.Present {
+		world.Scheduled = scheduled.Bool
+	}
+	if gameMode.Status == pgtype.Present {
+		world.GameMode = gameMode.String
+	}
+
+	return world, nil
+}
+
+type CreateWorldRequest struct {
+	Name        string    `json:"name"`                  // name of the world (required)
+	Description *string   `json:"description,omitempty"` // description of the world (optional)
+	Map         string    `json:"map"`                   // map of the package loaded by the world (required)
+	PackageId   uuid.UUID `json:"modId"`                 // package containing the map (required)
+	Type        *string   `json:"type,omitempty"`        // type of the world (optional) (default: "world")
+	Scheduled   *bool     `json:"scheduled,omitempty"`   // world is only available during scheduled events (optional) (default: false)
+	GameMode    *string   `json:"gameMode,omitempty"`    // game mode of the world (optional)
+	Public      *bool     `json:"public,omitempty"`      // world is visible to all users (optional) (default: true)
+}
+
+// CreateWorld creates the world owned by the requester, the requester must be able to view the package of the world
+func CreateWorld(ctx context.Context, requester *User, request CreateWorldRequest) (world *World, err error) {
+	if requester == nil || requester.Id == uuid.Nil {
+		return nil, ErrNoRequester
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return nil, ErrNoDatabase
+	}
+
+	if request.Name == "" {
+		return nil, fmt.Errorf("name is not set")
+	}
+
+	if request.Map == "" {
+		return nil, fmt.Errorf("map is not set")
+	}
+
+	if request.PackageId.IsNil() {
+		return nil, fmt.Errorf("package id is not set")
+	}
+
+	canView, err := RequestCanViewEntity(ctx, requester, request.PackageId)
+	if err != nil {
+		return nil, err
+	}
+
+	if !canView {
+		return nil, ErrNoPermission
+	}
+
+	var (
+		worldType = "world"
+		scheduled = false
+		public    = true
+	)
+
+	if request.Type != nil && *request.Type != "" {
+		worldType = *request.Type
+	}
+
+	if request.Scheduled != nil {
+		scheduled = *request.Scheduled
+	}
+
+	if request.Public != nil {
+		public = *request.Public
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	q := `with e as (insert into entities (id, entity_type, public) values (gen_random_uuid(), 'space', $1) returning id)
+insert
+into spaces (id, name, description, map, mod_id, type, scheduled, game_mode)
+select e.id, $2, $3, $4, $5, $6, $7, $8
+from e
+returning id`
+
+	var id pgtypeuuid.UUID
+	err = tx.QueryRow(ctx, q, public, request.Name, request.Description, request.Map, request.PackageId, worldType, scheduled, request.GameMode).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create world: %w", err)
+	}
+
+	q = `insert into accessibles (entity_id, user_id, is_owner, can_view, can_edit, can_delete) values ($1, $2, true, true, true, true)`
+	_, err = tx.Exec(ctx, q, id.UUID, requester.Id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set world owner: %w", err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return GetWorld(ctx, requester, GetWorldRequest{Id: id.UUID})
+}
+
+type UpdateWorldRequest struct {
+	Name        *string    `json:"name,omitempty"`
+	Description *string    `json:"description,omitempty"`
+	Map         *string    `json:"map,omitempty"`
+	PackageId   *uuid.UUID `json:"modId,omitempty"`
+	Type        *string    `json:"type,omitempty"`
+	Scheduled   *bool      `json:"scheduled,omitempty"`
+	GameMode    *string    `json:"gameMode,omitempty"`
+	Public      *bool      `json:"public,omitempty"`
+}
+
+// UpdateWorld updates the fields of the world set in the request
+func UpdateWorld(ctx context.Context, requester *User, id uuid.UUID, request UpdateWorldRequest) (world *World, err error) {
+	if requester == nil || requester.Id == uuid.Nil {
+		return nil, ErrNoRequester
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return nil, ErrNoDatabase
+	}
+
+	if request.Name != nil && *request.Name == "" {
+		return nil, fmt.Errorf("name is empty")
+	}
+
+	if request.Map != nil && *request.Map == "" {
+		return nil, fmt.Errorf("map is empty")
+	}
+
+	canEdit, err := RequestCanEditEntity(ctx, requester, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !canEdit {
+		return nil, ErrNoPermission
+	}
+
+	if request.PackageId != nil {
+		canView, err := RequestCanViewEntity(ctx, requester, *request.PackageId)
+		if err != nil {
+			return nil, err
+		}
+
+		if !canView {
+			return nil, ErrNoPermission
+		}
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	q := `update spaces
+set name        = coalesce($2, name),
+    description = coalesce($3, description),
+    map         = coalesce($4, map),
+    mod_id      = coalesce($5, mod_id),
+    type        = coalesce($6, type),
+    scheduled   = coalesce($7, scheduled),
+    game_mode   = coalesce($8, game_mode)
+where id = $1`
+
+	tag, err := tx.Exec(ctx, q, id, request.Name, request.Description, request.Map, request.PackageId, request.Type, request.Scheduled, request.GameMode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update world: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return nil, ErrNoRows
+	}
+
+	_, err = tx.Exec(ctx, `update entities set public = coalesce($2, public), updated_at = now() where id = $1`, id, request.Public)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update world entity: %w", err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return GetWorld(ctx, requester, GetWorldRequest{Id: id})
+}
+
+// DeleteWorld deletes the world and unpublishes it from all apps
+func DeleteWorld(ctx context.Context, requester *User, id uuid.UUID) (err error) {
+	if requester == nil || requester.Id == uuid.Nil {
+		return ErrNoRequester
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return ErrNoDatabase
+	}
+
+	canDelete, err := RequestCanDeleteEntity(ctx, requester, id)
+	if err != nil {
+		return err
+	}
+
+	if !canDelete {
+		return ErrNoPermission
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	tag, err := tx.Exec(ctx, `delete from spaces where id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete world: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNoRows
+	}
+
+	_, err = tx.Exec(ctx, `delete from entities where id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete world entity: %w", err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,285 @@
+package model
+
+import (
+	"context"
+	glContext "dev.hackerman.me/artheon/veverse-shared/context"
+	"fmt"
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"strconv"
+	"strings"
+)
+
+const (
+	WorldSortRelevance = "relevance" // full-text search rank, requires the query
+	WorldSortLikes     = "likes"     // number of likes
+	WorldSortViews     = "views"     // number of views
+	WorldSortRecent    = "recent"    // last update or creation time
+)
+
+// worldFacetLimit is the max number of values returned for each facet
+const worldFacetLimit = 20
+
+const (
+	worldFacetGameMode = "gameMode"
+	worldFacetType     = "type"
+	worldFacetPlatform = "platform"
+	worldFacetOwner    = "owner"
+)
+
+type DiscoverWorldsRequest struct {
+	BatchRequestMetadata
+	GameModes  []string   `json:"gameModes,omitempty"`  // only worlds with one of the game modes
+	Types      []string   `json:"types,omitempty"`      // only worlds of one of the types
+	Platform   string     `json:"platform,omitempty"`   // only worlds having a pak file for the platform
+	Deployment string     `json:"deployment,omitempty"` // deployment of the pak file used by the platform filter and facet (Server or Client)
+	OwnerId    *uuid.UUID `json:"ownerId,omitempty"`    // only worlds of the owner
+	Sort       string     `json:"sort,omitempty"`       // WorldSortRelevance, WorldSortLikes, WorldSortViews or WorldSortRecent (default: relevance with the query, recent without)
+}
+
+// WorldFacetValue is a facet value with the number of matching worlds
+type WorldFacetValue struct {
+	Value string  `json:"value"`
+	Label *string `json:"label,omitempty"` // display name of the value, e.g. the owner name
+	Count uint64  `json:"count"`
+}
+
+// WorldFacets are counts of matching worlds by facet value, each facet is counted with all filters except its own, so
+// that other values of the facet can be offered to the user
+type WorldFacets struct {
+	GameModes []WorldFacetValue `json:"gameModes"`
+	Types     []WorldFacetValue `json:"types"`
+	Platforms []WorldFacetValue `json:"platforms"`
+	Owners    []WorldFacetValue `json:"owners"`
+}
+
+type WorldDiscoveryBatch struct {
+	WorldBatch
+	Facets WorldFacets `json:"facets"`
+}
+
+// worldDiscoveryQuery builds the from and where clauses of the discovery request with the extra conditions, the filter
+// of the excluded facet is left out for facet counts
+func worldDiscoveryQuery(requester *User, request DiscoverWorldsRequest, exclude string, extra ...string) (q string, qArgs []any, qSearchArg string) {
+	var (
+		qArgNum = 0
+		qWhere  []string
+	)
+
+	q = ` from spaces w
+         join entities e on w.id = e.id
+         left join lateral (select oa.user_id from accessibles oa where oa.entity_id = e.id and oa.is_owner limit 1) o on true
+         left join users ou on o.user_id = ou.id`
+
+	if !requester.IsAdmin {
+		qArgNum++
+		qArgs = append(qArgs, requester.Id)
+		q += ` left join accessibles a on e.id = a.entity_id and a.user_id = $` + strconv.Itoa(qArgNum)
+		qWhere = append(qWhere, `(e.public or a.is_owner or a.can_view)`)
+	}
+
+	if request.Query != "" {
+		qArgNum++
+		qArgs = append(qArgs, request.Query)
+		qSearchArg = `websearch_to_tsquery('english', $` + strconv.Itoa(qArgNum) + `)`
+		qWhere = append(qWhere, `w.search @@ `+qSearchArg)
+	}
+
+	if len(request.GameModes) > 0 && exclude != worldFacetGameMode {
+		qArgNum++
+		qArgs = append(qArgs, request.GameModes)
+		qWhere = append(qWhere, `w.game_mode = any($`+strconv.Itoa(qArgNum)+`)`)
+	}
+
+	if len(request.Types) > 0 && exclude != worldFacetType {
+		qArgNum++
+		qArgs = append(qArgs, request.Types)
+		qWhere = append(qWhere, `w.type = any($`+strconv.Itoa(qArgNum)+`)`)
+	}
+
+	if request.OwnerId != nil && exclude != worldFacetOwner {
+		qArgNum++
+		qArgs = append(qArgs, *request.OwnerId)
+		qWhere = append(qWhere, `o.user_id = $`+strconv.Itoa(qArgNum))
+	}
+
+	if exclude == worldFacetPlatform {
+		// platform facet counts worlds by the platforms of their pak files
+		q += ` join files pf on pf.entity_id = w.mod_id and pf.type = '` + FileTypePak + `'`
+		if request.Deployment != "" {
+			qArgNum++
+			qArgs = append(qArgs, request.Deployment)
+			q += ` and pf.deployment_type = $` + strconv.Itoa(qArgNum)
+		}
+	} else if request.Platform != "" {
+		qArgNum++
+		qArgs = append(qArgs, request.Platform)
+		qPak := `exists(select 1 from files pf where pf.entity_id = w.mod_id and pf.type = '` + FileTypePak + `' and pf.platform = $` + strconv.Itoa(qArgNum)
+		if request.Deployment != "" {
+			qArgNum++
+			qArgs = append(qArgs, request.Deployment)
+			qPak += ` and pf.deployment_type = $` + strconv.Itoa(qArgNum)
+		}
+		qWhere = append(qWhere, qPak+`)`)
+	}
+
+	qWhere = append(qWhere, extra...)
+	if len(qWhere) > 0 {
+		q += ` where ` + strings.Join(qWhere, ` and `)
+	}
+
+	return q, qArgs, qSearchArg
+}
+
+// DiscoverWorlds searches worlds visible to the requester by the full-text query of the name and description and the
+// facet filters, and returns the facet counts of the results
+func DiscoverWorlds(ctx context.Context, requester *User, request DiscoverWorldsRequest) (batch *WorldDiscoveryBatch, err error) {
+	if requester == nil || requester.Id == uuid.Nil {
+		return nil, ErrNoRequester
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return nil, ErrNoDatabase
+	}
+
+	if request.Sort == "" {
+		if request.Query != "" {
+			request.Sort = WorldSortRelevance
+		} else {
+			request.Sort = WorldSortRecent
+		}
+	}
+
+	var qSort string
+	switch request.Sort {
+	case WorldSortRelevance:
+		if request.Query == "" {
+			return nil, fmt.Errorf("relevance sort requires the query")
+		}
+		qSort = `rank desc`
+	case WorldSortLikes:
+		qSort = `e.likes desc`
+	case WorldSortViews:
+		qSort = `e.views desc nulls last`
+	case WorldSortRecent:
+		qSort = `coalesce(e.updated_at, e.created_at) desc`
+	default:
+		return nil, fmt.Errorf("unknown sort %q", request.Sort)
+	}
+
+	batch = &WorldDiscoveryBatch{}
+	batch.Offset = 0
+	batch.Limit = 100
+
+	if request.Offset > 0 {
+		batch.Offset = request.Offset
+	}
+
+	if request.Limit > 0 && request.Limit <= 100 {
+		batch.Limit = request.Limit
+	}
+
+	q, qArgs, qSearchArg := worldDiscoveryQuery(requester, request, "")
+
+	err = db.QueryRow(ctx, `select count(*)`+q, qArgs...).Scan(&batch.Total)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count worlds: %w", err)
+	}
+
+	qRank := `0::real`
+	if qSearchArg != "" {
+		qRank = `ts_rank(w.search, ` + qSearchArg + `)`
+	}
+
+	q = `select ` + worldColumns + `,
+       e.likes,
+       ` + qRank + ` as rank` + q + `
+order by ` + qSort + `, e.created_at desc, e.id
+offset $` + strconv.Itoa(len(qArgs)+1) + ` limit $` + strconv.Itoa(len(qArgs)+2)
+	qArgs = append(qArgs, batch.Offset, batch.Limit)
+
+	rows, err := db.Query(ctx, q, qArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get worlds: %w", err)
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			likes pgtype.Int4
+			rank  pgtype.Float4
+			w     *World
+		)
+		w, err = scanWorld(rows, &likes, &rank)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get worlds: %w", err)
+		}
+		if likes.Status == pgtype.Present {
+			w.Likes = &likes.Int
+		}
+		batch.Entities = append(batch.Entities, *w)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get worlds: %w", err)
+	}
+
+	if batch.Facets, err = getWorldFacets(ctx, db, requester, request); err != nil {
+		return nil, err
+	}
+
+	return batch, nil
+}
+
+func getWorldFacets(ctx context.Context, db *pgxpool.Pool, requester *User, request DiscoverWorldsRequest) (facets WorldFacets, err error) {
+	facetQueries := []struct {
+		facet  string
+		value  string
+		label  string
+		values *[]WorldFacetValue
+	}{
+		{worldFacetGameMode, `w.game_mode`, `null::text`, &facets.GameModes},
+		{worldFacetType, `w.type`, `null::text`, &facets.Types},
+		{worldFacetPlatform, `pf.platform`, `null::text`, &facets.Platforms},
+		{worldFacetOwner, `o.user_id::text`, `min(ou.name)`, &facets.Owners},
+	}
+
+	for _, f := range facetQueries {
+		q, qArgs, _ := worldDiscoveryQuery(requester, request, f.facet, f.value+` <> ''`)
+
+		// distinct counts each world once per platform even if it has several pak files
+		q = `select ` + f.value + `, ` + f.label + `, count(distinct w.id)` + q + ` group by 1 order by 3 desc, 1 limit ` + strconv.Itoa(worldFacetLimit)
+
+		rows, err := db.Query(ctx, q, qArgs...)
+		if err != nil {
+			return facets, fmt.Errorf("failed to get %s facet: %w", f.facet, err)
+		}
+
+		*f.values = make([]WorldFacetValue, 0)
+		for rows.Next() {
+			var (
+				value pgtype.Text
+				label pgtype.Text
+				v     WorldFacetValue
+			)
+			if err = rows.Scan(&value, &label, &v.Count); err != nil {
+				rows.Close()
+				return facets, fmt.Errorf("failed to get %s facet: %w", f.facet, err)
+			}
+			v.Value = value.String
+			if label.Status == pgtype.Present {
+				v.Label = &label.String
+			}
+			*f.values = append(*f.values, v)
+		}
+		rows.Close()
+
+		if err = rows.Err(); err != nil {
+			return facets, fmt.Errorf("failed to get %s facet: %w", f.facet, err)
+		}
+	}
+
+	return facets, nil
+}
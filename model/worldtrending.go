@@ -0,0 +1,254 @@
+package model
+
+import (
+	"context"
+	glContext "dev.hackerman.me/artheon/veverse-shared/context"
+	"fmt"
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgtype"
+	pgtypeuuid "github.com/jackc/pgtype/ext/gofrs-uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"math"
+	"time"
+)
+
+const (
+	WorldIndexModeTrending    = "trending"    // worlds ordered by the trending score
+	WorldIndexModeRecommended = "recommended" // worlds liked or visited by users with similar interests, not yet seen by the requester
+)
+
+// TrendingOptions configure the trending score, zero durations and nil weights use the defaults, set a weight to zero
+// to ignore the activity
+type TrendingOptions struct {
+	HalfLife         time.Duration // time after which the activity counts half (default: 24h)
+	InitialWindow    time.Duration // activity counted for worlds refreshed for the first time (default: 7 days)
+	RefreshInterval  time.Duration // max time concurrent players are counted for, the period of the refresh job (default: 1h)
+	VisitWeight      *float64      // score of a player session (default: 1)
+	LikeWeight       *float64      // score of a like (default: 5)
+	DislikeWeight    *float64      // score subtracted for a dislike (default: 5)
+	PlayerHourWeight *float64      // score of a concurrent player per hour (default: 2)
+}
+
+var DefaultTrendingOptions = TrendingOptions{
+	HalfLife:         24 * time.Hour,
+	InitialWindow:    7 * 24 * time.Hour,
+	RefreshInterval:  time.Hour,
+	VisitWeight:      trendingWeight(1),
+	LikeWeight:       trendingWeight(5),
+	DislikeWeight:    trendingWeight(5),
+	PlayerHourWeight: trendingWeight(2),
+}
+
+func trendingWeight(w float64) *float64 {
+	return &w
+}
+
+// WorldActivity is the activity of the world since the previous trending refresh
+type WorldActivity struct {
+	Visits   int64 // player sessions started
+	Likes    int64 // likes given
+	Dislikes int64 // dislikes given
+	Players  int64 // players connected at the moment of the refresh
+}
+
+// TrendingScore decays the previous score by the elapsed time and adds the new activity, concurrent players are
+// counted for the elapsed time up to the refresh interval, as they are only known at the moment of the refresh. The
+// score is never negative.
+func TrendingScore(previous float64, elapsed time.Duration, activity WorldActivity, options TrendingOptions) float64 {
+	options = options.withDefaults()
+
+	if elapsed < 0 {
+		elapsed = 0
+	}
+
+	playerTime := elapsed
+	if playerTime > options.RefreshInterval {
+		playerTime = options.RefreshInterval
+	}
+
+	score := previous * math.Pow(0.5, elapsed.Hours()/options.HalfLife.Hours())
+	score += float64(activity.Visits) * *options.VisitWeight
+	score += float64(activity.Likes) * *options.LikeWeight
+	score -= float64(activity.Dislikes) * *options.DislikeWeight
+	score += float64(activity.Players) * playerTime.Hours() * *options.PlayerHourWeight
+
+	return math.Max(score, 0)
+}
+
+func (o TrendingOptions) withDefaults() TrendingOptions {
+	if o.HalfLife <= 0 {
+		o.HalfLife = DefaultTrendingOptions.HalfLife
+	}
+	if o.InitialWindow <= 0 {
+		o.InitialWindow = DefaultTrendingOptions.InitialWindow
+	}
+	if o.RefreshInterval <= 0 {
+		o.RefreshInterval = DefaultTrendingOptions.RefreshInterval
+	}
+	if o.VisitWeight == nil {
+		o.VisitWeight = DefaultTrendingOptions.VisitWeight
+	}
+	if o.LikeWeight == nil {
+		o.LikeWeight = DefaultTrendingOptions.LikeWeight
+	}
+	if o.DislikeWeight == nil {
+		o.DislikeWeight = DefaultTrendingOptions.DislikeWeight
+	}
+	if o.PlayerHourWeight == nil {
+		o.PlayerHourWeight = DefaultTrendingOptions.PlayerHourWeight
+	}
+	return o
+}
+
+// RefreshWorldTrending updates trending scores of all worlds with the activity since their previous refresh, it is
+// meant to be run periodically by a job
+func RefreshWorldTrending(ctx context.Context, requester *User, options TrendingOptions) (updated int, err error) {
+	if requester == nil {
+		return 0, ErrNoRequester
+	}
+
+	if !requester.IsAdmin && !requester.IsInternal {
+		return 0, ErrNoPermission
+	}
+
+	db, ok := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if !ok || db == nil {
+		return 0, ErrNoDatabase
+	}
+
+	options = options.withDefaults()
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	// concurrent refreshes would count the same activity twice
+	_, err = tx.Exec(ctx, `select pg_advisory_xact_lock(hashtext('world_trending_v2'))`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to lock world trending: %w", err)
+	}
+
+	// now() is the transaction start time, so activity is counted up to the same moment the refresh is stamped with
+	q := `with s as (select w.id,
+                  t.score,
+                  coalesce(t.updated_at, now() - make_interval(secs => $1)) as since
+           from spaces w
+                    left join world_trending_v2 t on w.id = t.world_id)
+select s.id,
+       coalesce(s.score, 0),
+       extract(epoch from now() - s.since)::float8,
+       (select count(*)
+        from game_server_player_v2 p
+                 join game_server_v2 gs on p.server_id = gs.id
+        where gs.world_id = s.id
+          and p.created_at > s.since
+          and p.created_at <= now()),
+       (select count(*)
+        from likables l
+        where l.entity_id = s.id
+          and l.value > 0
+          and coalesce(l.updated_at, l.created_at) > s.since
+          and coalesce(l.updated_at, l.created_at) <= now()),
+       (select count(*)
+        from likables l
+        where l.entity_id = s.id
+          and l.value < 0
+          and coalesce(l.updated_at, l.created_at) > s.since
+          and coalesce(l.updated_at, l.created_at) <= now()),
+       (select count(*)
+        from game_server_player_v2 p
+                 join game_server_v2 gs on p.server_id = gs.id
+        where gs.world_id = s.id
+          and p.status = $2)
+from s`
+
+	rows, err := tx.Query(ctx, q, options.InitialWindow.Seconds(), GameServerV2PlayerStatusConnected)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get world activity: %w", err)
+	}
+
+	var (
+		ids    []uuid.UUID
+		scores []float64
+	)
+	for rows.Next() {
+		var (
+			id       pgtypeuuid.UUID
+			previous pgtype.Float8
+			elapsed  pgtype.Float8
+			activity WorldActivity
+		)
+
+		err = rows.Scan(&id, &previous, &elapsed, &activity.Visits, &activity.Likes, &activity.Dislikes, &activity.Players)
+		if err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to get world activity: %w", err)
+		}
+
+		elapsedDuration := time.Duration(elapsed.Float * float64(time.Second))
+		ids = append(ids, id.UUID)
+		scores = append(scores, TrendingScore(previous.Float, elapsedDuration, activity, options))
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to get world activity: %w", err)
+	}
+
+	q = `insert into world_trending_v2 (world_id, score, updated_at)
+select unnest($1::uuid[]), unnest($2::float8[]), now()
+on conflict (world_id) do update set score = excluded.score, updated_at = excluded.updated_at`
+
+	_, err = tx.Exec(ctx, q, ids, scores)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update world trending scores: %w", err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return len(scores), nil
+}
+
+// worldRecommendations is the subquery of recommended worlds for the user of the argument with their scores. Users
+// sharing liked or visited worlds with the user are weighted by the shared activity, worlds they liked or visited are
+// recommended unless the user has already seen or disliked them. Trending worlds are mixed in with a small weight, so
+// users without activity get recommendations too.
+func worldRecommendations(userArg string) string {
+	return `(with i as (select l.entity_id as world_id, l.user_id, 2.0 as weight
+                   from likables l
+                   where l.value > 0
+                   union all
+                   select gs.world_id, p.user_id, 1.0
+                   from game_server_player_v2 p
+                            join game_server_v2 gs on p.server_id = gs.id
+                   where gs.world_id is not null),
+          seen as (select i.world_id
+                   from i
+                   where i.user_id = ` + userArg + `
+                   union
+                   select l.entity_id
+                   from likables l
+                   where l.user_id = ` + userArg + `),
+          peers as (select i.user_id, sum(i.weight) as similarity
+                    from i
+                             join seen on i.world_id = seen.world_id
+                    where i.user_id <> ` + userArg + `
+                    group by i.user_id),
+          r as (select i.world_id, i.weight * peers.similarity as score
+                from i
+                         join peers on i.user_id = peers.user_id
+                union all
+                select wt.world_id, wt.score * 0.01
+                from world_trending_v2 wt)
+     select r.world_id, sum(r.score) as score
+     from r
+     where r.world_id not in (select seen.world_id from seen)
+     group by r.world_id)`
+}
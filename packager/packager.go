@@ -0,0 +1,372 @@
+// Package packager builds release upload sets from staged Unreal build directories.
+package packager
+
+import (
+	"crypto/sha256"
+	"dev.hackerman.me/artheon/veverse-shared/archive"
+	"dev.hackerman.me/artheon/veverse-shared/executable"
+	"dev.hackerman.me/artheon/veverse-shared/helper"
+	"dev.hackerman.me/artheon/veverse-shared/model"
+	"dev.hackerman.me/artheon/veverse-shared/unreal"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var ErrPlatformMismatch = errors.New("binary does not run on the release platform")
+
+const (
+	DeploymentClient = "Client"
+	DeploymentServer = "Server"
+)
+
+// mimeTypes of common build file extensions, a fixed table is used as mime.TypeByExtension depends on the host system,
+// files with other extensions get model.DefaultFileMime
+var mimeTypes = map[string]string{
+	".txt":  "text/plain",
+	".ini":  "text/plain",
+	".log":  "text/plain",
+	".json": "application/json",
+	".xml":  "application/xml",
+	".html": "text/html",
+	".sh":   "application/x-sh",
+	".zip":  "application/zip",
+	".gz":   "application/gzip",
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".bmp":  "image/bmp",
+	".ico":  "image/x-icon",
+	".mp4":  "video/mp4",
+	".webm": "video/webm",
+	".wav":  "audio/wav",
+	".ogg":  "audio/ogg",
+	".ttf":  "font/ttf",
+	".otf":  "font/otf",
+}
+
+// DefaultIgnore lists files of the staged build that are not distributed, patterns of Options.Ignore and the ignore
+// file are applied after them, so e.g. "!*.pdb" keeps debug symbols
+var DefaultIgnore = []string{
+	"Manifest_*.txt", // staging manifests
+	"*.pdb",
+	"*.debug",
+	"*.sym",
+	"*.dSYM/",
+	helper.IgnoreFileName,
+}
+
+type Options struct {
+	Platform       string                // unreal platform name (default: detected by the directory name, then the current platform)
+	Deployment     string                // Client or Server (default: detected by the directory name, then Client)
+	Ignore         []string              // .gitignore-style patterns in addition to DefaultIgnore and helper.IgnoreFileName in the build directory
+	Archive        bool                  // package the build as a single archive instead of separate files
+	ArchivePath    string                // archive output path, the format is detected by the extension (default: <dir>.zip next to the build directory)
+	ArchiveOptions archive.WriterOptions // archive writer options
+	// AllowForeignBinaries disables the check that executables and libraries of the build run on the release platform
+	AllowForeignBinaries bool
+}
+
+// File is a file of the release to upload
+type File struct {
+	Path         string `json:"path"`         // local path of the file
+	OriginalPath string `json:"originalPath"` // slash separated path relative to the build directory, the file is installed to this path
+	Type         string `json:"type"`         // release or release-archive
+	Mime         string `json:"mime"`
+	Platform     string `json:"platform"`
+	Deployment   string `json:"deployment"`
+	Size         int64  `json:"size"`
+	Hash         string `json:"hash"`       // hex encoded sha256 of the content
+	Executable   bool   `json:"executable"` // the file must keep the executable bits when installed
+}
+
+// UploadMetadata returns the metadata of the file upload request
+func (f File) UploadMetadata() model.FileUploadRequestMetadata {
+	size := int(f.Size)
+	hash := f.Hash
+	mimeType := f.Mime
+	return model.FileUploadRequestMetadata{
+		Type:         f.Type,
+		Mime:         &mimeType,
+		Deployment:   f.Deployment,
+		Platform:     f.Platform,
+		OriginalPath: f.OriginalPath,
+		Size:         &size,
+		Hash:         &hash,
+	}
+}
+
+// LinkMetadata returns the metadata of the file link request for the file uploaded to the url
+func (f File) LinkMetadata(url string) model.FileLinkRequestMetadata {
+	size := int(f.Size)
+	hash := f.Hash
+	mimeType := f.Mime
+	return model.FileLinkRequestMetadata{
+		Type:         f.Type,
+		Url:          url,
+		Mime:         &mimeType,
+		Size:         &size,
+		Deployment:   f.Deployment,
+		Platform:     f.Platform,
+		OriginalPath: f.OriginalPath,
+		Hash:         &hash,
+	}
+}
+
+// Release is the upload set of a new ReleaseV2
+type Release struct {
+	Root        string   `json:"root"` // build directory
+	Platform    string   `json:"platform"`
+	Deployment  string   `json:"deployment"`
+	Archive     bool     `json:"archive"`     // value of the release archive flag
+	Files       []File   `json:"files"`       // files to upload, sorted by the original path
+	Size        int64    `json:"size"`        // total size of the files
+	Executables []string `json:"executables"` // original paths of the build files that must keep the executable bits
+}
+
+// Build scans the staged build directory and returns the files to upload. In archive mode the archive is written to
+// Options.ArchivePath and is the only file of the release.
+func Build(dir string, options Options) (*Release, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+
+	release := &Release{Root: dir, Platform: options.Platform, Deployment: options.Deployment, Archive: options.Archive}
+
+	platform, deployment := DetectPlatform(dir)
+	if release.Platform == "" {
+		release.Platform = platform
+	}
+	if release.Platform == "" {
+		release.Platform = unreal.GetPlatformName()
+	}
+	if release.Deployment == "" {
+		release.Deployment = deployment
+	}
+	if release.Deployment != DeploymentClient && release.Deployment != DeploymentServer {
+		return nil, fmt.Errorf("invalid deployment %s", release.Deployment)
+	}
+
+	rules, err := loadIgnoreRules(dir, options.Ignore)
+	if err != nil {
+		return nil, err
+	}
+
+	paths, err := helper.ListFilesRecursiveWithRules(dir, rules)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := scanFiles(dir, paths, release, !options.AllowForeignBinaries)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range files {
+		if f.Executable {
+			release.Executables = append(release.Executables, f.OriginalPath)
+		}
+	}
+
+	if !options.Archive {
+		release.Files = files
+		for _, f := range files {
+			release.Size += f.Size
+		}
+		return release, nil
+	}
+
+	output := options.ArchivePath
+	if output == "" {
+		output = dir + ".zip"
+	}
+	output, err = filepath.Abs(output)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = archive.CreateArchive(output, dir, paths, options.ArchiveOptions); err != nil {
+		return nil, fmt.Errorf("failed to create archive: %w", err)
+	}
+
+	f, err := scanFile(output, filepath.Base(output))
+	if err != nil {
+		return nil, err
+	}
+
+	f.Type = model.FileTypeReleaseArchive
+	f.Platform = release.Platform
+	f.Deployment = release.Deployment
+	f.Executable = false
+
+	release.Files = []File{f}
+	release.Size = f.Size
+
+	return release, nil
+}
+
+// DetectPlatform returns the unreal platform name and deployment by the name of the staged build directory, e.g.
+// "WindowsNoEditor", "LinuxServer" or "Mac", the platform is empty if the name is unknown
+func DetectPlatform(dir string) (platform string, deployment string) {
+	name := filepath.Base(dir)
+
+	deployment = DeploymentClient
+	for _, suffix := range []string{"NoEditor", "Client", "Server"} {
+		if strings.HasSuffix(name, suffix) {
+			if suffix == "Server" {
+				deployment = DeploymentServer
+			}
+			name = strings.TrimSuffix(name, suffix)
+			break
+		}
+	}
+
+	switch name {
+	case "Windows", "Win64":
+		platform = unreal.Windows
+	case "Linux":
+		platform = unreal.Linux
+	case "Mac":
+		platform = unreal.Mac
+	}
+
+	return platform, deployment
+}
+
+// loadIgnoreRules applies the defaults, the ignore file of the build and the patterns of the options in this order
+func loadIgnoreRules(dir string, ignore []string) (*helper.IgnoreRules, error) {
+	rules, err := helper.ParseIgnoreRules(DefaultIgnore)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, helper.IgnoreFileName))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if err = rules.Add(strings.Split(string(data), "\n")...); err != nil {
+		return nil, fmt.Errorf("%s: %w", helper.IgnoreFileName, err)
+	}
+
+	if err = rules.Add(ignore...); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+// scanFiles hashes the files and checks that original paths do not collide on case-insensitive file systems
+func scanFiles(dir string, paths []string, release *Release, checkPlatform bool) ([]File, error) {
+	var (
+		files = make([]File, 0, len(paths))
+		seen  = make(map[string]string, len(paths))
+	)
+
+	for _, p := range paths {
+		originalPath := path.Clean(filepath.ToSlash(p))
+
+		key := strings.ToLower(originalPath)
+		if other, ok := seen[key]; ok {
+			return nil, fmt.Errorf("file %s conflicts with %s on case-insensitive file systems", originalPath, other)
+		}
+		seen[key] = originalPath
+
+		info, err := os.Stat(filepath.Join(dir, p))
+		if err != nil {
+			return nil, err
+		}
+		if info.IsDir() {
+			// symlinks to directories can not be distributed as separate files
+			continue
+		}
+
+		f, err := scanFile(filepath.Join(dir, p), originalPath)
+		if err != nil {
+			return nil, err
+		}
+
+		if checkPlatform && f.Executable {
+			if err = checkBinaryPlatform(f.Path, originalPath, release.Platform); err != nil {
+				return nil, err
+			}
+		}
+
+		f.Type = model.FileTypeRelease
+		f.Platform = release.Platform
+		f.Deployment = release.Deployment
+		f.Executable = f.Executable || info.Mode().Perm()&0111 != 0
+
+		files = append(files, f)
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].OriginalPath < files[j].OriginalPath
+	})
+
+	return files, nil
+}
+
+// checkBinaryPlatform fails if the file is a binary of another platform, e.g. a Win64 server in a Linux release
+func checkBinaryPlatform(filename string, originalPath string, platform string) error {
+	info, err := executable.InspectFile(filename)
+	if err != nil {
+		if errors.Is(err, executable.ErrUnknownFormat) {
+			return nil
+		}
+		return err
+	}
+
+	if info.Format == executable.FormatScript || info.Kind == executable.KindObject || info.RunsOn(platform) {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s is a %s %v binary, release platform is %s", ErrPlatformMismatch, originalPath, info.OS, info.Archs, platform)
+}
+
+func scanFile(filename string, originalPath string) (File, error) {
+	f := File{Path: filename, OriginalPath: originalPath}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return f, err
+	}
+	defer file.Close()
+
+	var (
+		head executable.HeadWriter
+		h    = sha256.New()
+	)
+	f.Size, err = io.Copy(h, io.TeeReader(file, &head))
+	if err != nil {
+		return f, fmt.Errorf("failed to read %s: %w", filename, err)
+	}
+	f.Hash = hex.EncodeToString(h.Sum(nil))
+
+	f.Executable, err = head.IsExecutable()
+	if err != nil {
+		return f, err
+	}
+
+	f.Mime = mimeTypes[strings.ToLower(path.Ext(originalPath))]
+	if f.Mime == "" {
+		f.Mime = model.DefaultFileMime
+	}
+
+	return f, nil
+}
@@ -0,0 +1,262 @@
+package semver
+
+import (
+	"fmt"
+	"strings"
+)
+
+type operator int
+
+const (
+	opEqual operator = iota
+	opGreater
+	opGreaterOrEqual
+	opLess
+	opLessOrEqual
+)
+
+type comparator struct {
+	op      operator
+	version Version
+}
+
+func (c comparator) matches(v Version) bool {
+	cmp := v.Compare(c.version)
+	switch c.op {
+	case opEqual:
+		return cmp == 0
+	case opGreater:
+		return cmp > 0
+	case opGreaterOrEqual:
+		return cmp >= 0
+	case opLess:
+		return cmp < 0
+	case opLessOrEqual:
+		return cmp <= 0
+	}
+	return false
+}
+
+// Constraint is a version range, e.g. "^1.2", "~1.2.3", ">=1.0.0 <2.0.0", "1.x || 2.1.x".
+// Space-separated comparators must all match, "||" separates alternative ranges.
+type Constraint struct {
+	raw    string
+	ranges [][]comparator
+}
+
+// ParseConstraint parses a version range. Supported forms are exact versions ("1.2.3", "=1.2.3"), comparisons
+// (">1.2", ">=1.2.3", "<2", "<=2.0.0"), caret ranges ("^1.2" allows changes that do not modify the left-most non-zero
+// number), tilde ranges ("~1.2" allows patch changes), wildcards ("1.2.x", "1.*", "*") and partial versions ("1.2").
+func ParseConstraint(s string) (Constraint, error) {
+	var c = Constraint{raw: s}
+
+	for _, r := range strings.Split(s, "||") {
+		var set []comparator
+		fields := strings.Fields(r)
+		if len(fields) == 0 {
+			// empty range matches any release version
+			fields = []string{"*"}
+		}
+		for _, f := range fields {
+			comparators, err := parseComparator(f)
+			if err != nil {
+				return c, fmt.Errorf("invalid constraint %q: %v", s, err)
+			}
+			set = append(set, comparators...)
+		}
+		c.ranges = append(c.ranges, set)
+	}
+
+	return c, nil
+}
+
+// MustParseConstraint parses the constraint and panics on error, intended for constants
+func MustParseConstraint(s string) Constraint {
+	c, err := ParseConstraint(s)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func (c Constraint) String() string {
+	return c.raw
+}
+
+// Check reports whether the version satisfies the constraint. Pre-release versions satisfy a range only if one of its
+// comparators has a pre-release version with the same major, minor and patch numbers, so "^1.2" does not match
+// "1.3.0-beta", but ">=1.3.0-alpha" does.
+func (c Constraint) Check(v Version) bool {
+	for _, set := range c.ranges {
+		if matchesSet(set, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// Satisfies parses the version and the constraint and reports whether the version satisfies the constraint
+func Satisfies(version string, constraint string) (bool, error) {
+	v, err := Parse(version)
+	if err != nil {
+		return false, err
+	}
+	c, err := ParseConstraint(constraint)
+	if err != nil {
+		return false, err
+	}
+	return c.Check(v), nil
+}
+
+func matchesSet(set []comparator, v Version) bool {
+	for _, c := range set {
+		if !c.matches(v) {
+			return false
+		}
+	}
+
+	if !v.IsPreRelease() {
+		return true
+	}
+
+	for _, c := range set {
+		cv := c.version
+		if cv.IsPreRelease() && cv.Major == v.Major && cv.Minor == v.Minor && cv.Patch == v.Patch {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseComparator(s string) ([]comparator, error) {
+	var op string
+	for _, prefix := range []string{">=", "<=", ">", "<", "=", "^", "~"} {
+		if strings.HasPrefix(s, prefix) {
+			op = prefix
+			s = strings.TrimSpace(s[len(prefix):])
+			break
+		}
+	}
+
+	v, parts, err := parsePartial(s)
+	if err != nil {
+		return nil, err
+	}
+
+	// upper bound of a partial version, e.g. 1.2 -> 1.3.0, 1 -> 2.0.0
+	next := func(v Version, parts int) Version {
+		switch parts {
+		case 1:
+			return Version{Major: v.Major + 1, PreRelease: []string{"0"}}
+		case 2:
+			return Version{Major: v.Major, Minor: v.Minor + 1, PreRelease: []string{"0"}}
+		}
+		return Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch + 1, PreRelease: []string{"0"}}
+	}
+
+	switch op {
+	case "", "=":
+		if parts == 0 {
+			return []comparator{{opGreaterOrEqual, Version{}}}, nil
+		}
+		if parts == 3 {
+			return []comparator{{opEqual, v}}, nil
+		}
+		return []comparator{{opGreaterOrEqual, v}, {opLess, next(v, parts)}}, nil
+	case ">":
+		if parts == 0 {
+			// nothing is greater than any version
+			return []comparator{{opLess, Version{}}}, nil
+		}
+		if parts == 3 {
+			return []comparator{{opGreater, v}}, nil
+		}
+		return []comparator{{opGreaterOrEqual, next(v, parts)}}, nil
+	case ">=":
+		return []comparator{{opGreaterOrEqual, v}}, nil
+	case "<":
+		if parts == 0 {
+			return []comparator{{opLess, Version{}}}, nil
+		}
+		if parts < 3 && !v.IsPreRelease() {
+			v.PreRelease = []string{"0"}
+		}
+		return []comparator{{opLess, v}}, nil
+	case "<=":
+		if parts == 0 {
+			return []comparator{{opGreaterOrEqual, Version{}}}, nil
+		}
+		if parts == 3 {
+			return []comparator{{opLessOrEqual, v}}, nil
+		}
+		return []comparator{{opLess, next(v, parts)}}, nil
+	case "~":
+		if parts == 0 {
+			return []comparator{{opGreaterOrEqual, Version{}}}, nil
+		}
+		if parts == 1 {
+			return []comparator{{opGreaterOrEqual, v}, {opLess, next(v, 1)}}, nil
+		}
+		return []comparator{{opGreaterOrEqual, v}, {opLess, next(v, 2)}}, nil
+	case "^":
+		if parts == 0 {
+			return []comparator{{opGreaterOrEqual, Version{}}}, nil
+		}
+		var upper Version
+		switch {
+		case v.Major > 0 || parts == 1:
+			upper = next(v, 1)
+		case v.Minor > 0 || parts == 2:
+			upper = next(v, 2)
+		default:
+			upper = next(v, 3)
+		}
+		return []comparator{{opGreaterOrEqual, v}, {opLess, upper}}, nil
+	}
+
+	return nil, fmt.Errorf("unknown operator %q", op)
+}
+
+// parsePartial parses a version that can miss minor and patch numbers or use wildcards, returns the number of
+// specified numbers (0 for "*")
+func parsePartial(s string) (Version, int, error) {
+	if s == "" {
+		return Version{}, 0, fmt.Errorf("empty version")
+	}
+
+	if v, err := Parse(s); err == nil {
+		return v, 3, nil
+	}
+
+	str := strings.TrimPrefix(s, "v")
+	if strings.ContainsAny(str, "-+") {
+		return Version{}, 0, fmt.Errorf("invalid version %q", s)
+	}
+
+	fields := strings.Split(str, ".")
+	if len(fields) > 3 {
+		return Version{}, 0, fmt.Errorf("invalid version %q", s)
+	}
+
+	var (
+		numbers [3]uint64
+		parts   = 0
+	)
+	for i, f := range fields {
+		if f == "x" || f == "X" || f == "*" {
+			break
+		}
+		if parts != i {
+			return Version{}, 0, fmt.Errorf("invalid version %q", s)
+		}
+		n, err := parseNumber(f)
+		if err != nil {
+			return Version{}, 0, fmt.Errorf("invalid version %q: %v", s, err)
+		}
+		numbers[i] = n
+		parts++
+	}
+
+	return Version{Major: numbers[0], Minor: numbers[1], Patch: numbers[2]}, parts, nil
+}
@@ -0,0 +1,234 @@
+// Package semver implements semantic versioning 2.0.0 parsing, precedence and range constraints.
+package semver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a parsed semantic version
+type Version struct {
+	Major      uint64
+	Minor      uint64
+	Patch      uint64
+	PreRelease []string // dot-separated pre-release identifiers (e.g. "beta", "2" for 1.0.0-beta.2)
+	Build      []string // dot-separated build metadata identifiers, ignored in precedence
+}
+
+// Parse parses a strict semantic version, an optional "v" prefix is allowed
+func Parse(s string) (Version, error) {
+	var v Version
+
+	str := strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if str == "" {
+		return v, fmt.Errorf("empty version")
+	}
+
+	if i := strings.IndexByte(str, '+'); i >= 0 {
+		build := str[i+1:]
+		str = str[:i]
+		ids, err := parseIdentifiers(build, false)
+		if err != nil {
+			return v, fmt.Errorf("invalid build metadata in version %q: %v", s, err)
+		}
+		v.Build = ids
+	}
+
+	if i := strings.IndexByte(str, '-'); i >= 0 {
+		pre := str[i+1:]
+		str = str[:i]
+		ids, err := parseIdentifiers(pre, true)
+		if err != nil {
+			return v, fmt.Errorf("invalid pre-release in version %q: %v", s, err)
+		}
+		v.PreRelease = ids
+	}
+
+	parts := strings.Split(str, ".")
+	if len(parts) != 3 {
+		return v, fmt.Errorf("invalid version %q: expected major.minor.patch", s)
+	}
+
+	var numbers [3]uint64
+	for i, p := range parts {
+		n, err := parseNumber(p)
+		if err != nil {
+			return v, fmt.Errorf("invalid version %q: %v", s, err)
+		}
+		numbers[i] = n
+	}
+
+	v.Major, v.Minor, v.Patch = numbers[0], numbers[1], numbers[2]
+
+	return v, nil
+}
+
+// MustParse parses the version and panics on error, intended for constants
+func MustParse(s string) Version {
+	v, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// IsValid reports whether the string is a valid semantic version
+func IsValid(s string) bool {
+	_, err := Parse(s)
+	return err == nil
+}
+
+func (v Version) String() string {
+	out := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if len(v.PreRelease) > 0 {
+		out += "-" + strings.Join(v.PreRelease, ".")
+	}
+	if len(v.Build) > 0 {
+		out += "+" + strings.Join(v.Build, ".")
+	}
+	return out
+}
+
+// IsPreRelease reports whether the version has pre-release identifiers
+func (v Version) IsPreRelease() bool {
+	return len(v.PreRelease) > 0
+}
+
+// Compare returns -1, 0 or 1 if the version has lower, equal or higher precedence than the other one.
+// Build metadata does not affect the precedence.
+func (v Version) Compare(o Version) int {
+	if c := compareNumbers(v.Major, o.Major); c != 0 {
+		return c
+	}
+	if c := compareNumbers(v.Minor, o.Minor); c != 0 {
+		return c
+	}
+	if c := compareNumbers(v.Patch, o.Patch); c != 0 {
+		return c
+	}
+
+	// a version without pre-release has higher precedence
+	if len(v.PreRelease) == 0 && len(o.PreRelease) == 0 {
+		return 0
+	} else if len(v.PreRelease) == 0 {
+		return 1
+	} else if len(o.PreRelease) == 0 {
+		return -1
+	}
+
+	for i := 0; i < len(v.PreRelease) && i < len(o.PreRelease); i++ {
+		if c := compareIdentifiers(v.PreRelease[i], o.PreRelease[i]); c != 0 {
+			return c
+		}
+	}
+
+	return compareNumbers(uint64(len(v.PreRelease)), uint64(len(o.PreRelease)))
+}
+
+func (v Version) LessThan(o Version) bool {
+	return v.Compare(o) < 0
+}
+
+func (v Version) GreaterThan(o Version) bool {
+	return v.Compare(o) > 0
+}
+
+func (v Version) Equal(o Version) bool {
+	return v.Compare(o) == 0
+}
+
+// Compare parses and compares two version strings
+func Compare(a, b string) (int, error) {
+	va, err := Parse(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := Parse(b)
+	if err != nil {
+		return 0, err
+	}
+	return va.Compare(vb), nil
+}
+
+// Max returns the index of the version with the highest precedence, invalid versions are skipped, -1 is returned if
+// there are no valid versions
+func Max(versions []string) int {
+	var (
+		index = -1
+		max   Version
+	)
+	for i, s := range versions {
+		v, err := Parse(s)
+		if err != nil {
+			continue
+		}
+		if index < 0 || v.GreaterThan(max) {
+			index = i
+			max = v
+		}
+	}
+	return index
+}
+
+func compareNumbers(a, b uint64) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
+
+// compareIdentifiers compares pre-release identifiers, numeric identifiers have lower precedence than alphanumeric ones
+func compareIdentifiers(a, b string) int {
+	an, aErr := strconv.ParseUint(a, 10, 64)
+	bn, bErr := strconv.ParseUint(b, 10, 64)
+
+	if aErr == nil && bErr == nil {
+		return compareNumbers(an, bn)
+	} else if aErr == nil {
+		return -1
+	} else if bErr == nil {
+		return 1
+	}
+
+	return strings.Compare(a, b)
+}
+
+func parseNumber(s string) (uint64, error) {
+	if s == "" {
+		return 0, fmt.Errorf("empty number")
+	}
+	if len(s) > 1 && s[0] == '0' {
+		return 0, fmt.Errorf("number %q has a leading zero", s)
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("%q is not a number", s)
+		}
+	}
+	return strconv.ParseUint(s, 10, 64)
+}
+
+func parseIdentifiers(s string, strictNumbers bool) ([]string, error) {
+	ids := strings.Split(s, ".")
+	for _, id := range ids {
+		if id == "" {
+			return nil, fmt.Errorf("empty identifier")
+		}
+		numeric := true
+		for _, c := range id {
+			if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '-') {
+				return nil, fmt.Errorf("invalid character %q in %q", c, id)
+			}
+			if c < '0' || c > '9' {
+				numeric = false
+			}
+		}
+		if strictNumbers && numeric && len(id) > 1 && id[0] == '0' {
+			return nil, fmt.Errorf("numeric identifier %q has a leading zero", id)
+		}
+	}
+	return ids, nil
+}
@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FileSystem stores contents in a local directory, the directory can be served by a static file server
+type FileSystem struct {
+	root    string
+	baseURL string
+}
+
+// NewFileSystem creates the storage in the root directory, urls of the contents are relative to the base url (optional),
+// file urls are returned if it is empty
+func NewFileSystem(root string, baseURL string) (*FileSystem, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = os.MkdirAll(filepath.Join(root, "tmp"), 0755); err != nil {
+		return nil, err
+	}
+
+	return &FileSystem{root: root, baseURL: strings.TrimRight(baseURL, "/")}, nil
+}
+
+func (s *FileSystem) path(hash string) (string, error) {
+	path, err := Path(hash)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(s.root, filepath.FromSlash(path)), nil
+}
+
+func (s *FileSystem) Put(ctx context.Context, r io.Reader, options PutOptions) (*Object, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if options.Hash != "" {
+		var err error
+		if options.Hash, err = NormalizeHash(options.Hash); err != nil {
+			return nil, err
+		}
+		// skip reading the content if it is already stored
+		if object, err := s.Stat(ctx, options.Hash); err == nil && (options.Size <= 0 || options.Size == object.Size) {
+			return object, nil
+		}
+	}
+
+	temp, err := os.CreateTemp(filepath.Join(s.root, "tmp"), "put-*")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = temp.Close()
+		_ = os.Remove(temp.Name())
+	}()
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(temp, h), r)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = temp.Close(); err != nil {
+		return nil, err
+	}
+
+	hash := hex.EncodeToString(h.Sum(nil))
+	if err = checkContent(hash, size, options); err != nil {
+		return nil, err
+	}
+
+	path, _ := s.path(hash)
+	if _, err = os.Stat(path); err == nil {
+		return s.Stat(ctx, hash)
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	if err = os.Chmod(temp.Name(), 0644); err != nil {
+		return nil, err
+	}
+
+	if err = os.Rename(temp.Name(), path); err != nil {
+		return nil, err
+	}
+
+	return s.Stat(ctx, hash)
+}
+
+func (s *FileSystem) Get(ctx context.Context, hash string) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	path, err := s.path(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, hash)
+	}
+
+	return file, err
+}
+
+func (s *FileSystem) Stat(ctx context.Context, hash string) (*Object, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	path, err := s.path(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, hash)
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &Object{Hash: strings.ToLower(hash), Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+func (s *FileSystem) Delete(ctx context.Context, hash string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	path, err := s.path(hash)
+	if err != nil {
+		return err
+	}
+
+	if err = os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// URL returns the url of the content relative to the base url or the file url, urls do not expire
+func (s *FileSystem) URL(_ context.Context, hash string, _ time.Duration) (string, error) {
+	path, err := s.path(hash)
+	if err != nil {
+		return "", err
+	}
+
+	if s.baseURL != "" {
+		p, _ := Path(hash)
+		return s.baseURL + "/" + p, nil
+	}
+
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(path)}
+	if !strings.HasPrefix(u.Path, "/") {
+		// windows drive letter
+		u.Path = "/" + u.Path
+	}
+
+	return u.String(), nil
+}
@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type S3Options struct {
+	Bucket  string // bucket name
+	Prefix  string // key prefix of the contents (optional), e.g. "storage/"
+	TempDir string // directory for contents of unknown hash and size, which are spooled to disk before uploading (default: os.TempDir)
+}
+
+// S3 stores contents in an S3-compatible bucket, the client is configured by the caller (e.g. endpoint and path style
+// for MinIO or DigitalOcean Spaces)
+type S3 struct {
+	client   s3iface.S3API
+	uploader *s3manager.Uploader
+	options  S3Options
+}
+
+func NewS3(client s3iface.S3API, options S3Options) (*S3, error) {
+	if options.Bucket == "" {
+		return nil, fmt.Errorf("bucket is required")
+	}
+
+	return &S3{client: client, uploader: s3manager.NewUploaderWithClient(client), options: options}, nil
+}
+
+func (s *S3) key(hash string) (string, error) {
+	path, err := Path(hash)
+	if err != nil {
+		return "", err
+	}
+	return s.options.Prefix + path, nil
+}
+
+func (s *S3) Put(ctx context.Context, r io.Reader, options PutOptions) (*Object, error) {
+	if options.Hash != "" {
+		var err error
+		if options.Hash, err = NormalizeHash(options.Hash); err != nil {
+			return nil, err
+		}
+		// skip reading the content if it is already stored
+		if object, err := s.Stat(ctx, options.Hash); err == nil && (options.Size <= 0 || options.Size == object.Size) {
+			return object, nil
+		}
+	}
+
+	// the key depends on the hash, so the content is hashed before uploading
+	content, hash, size, cleanup, err := spool(r, s.options.TempDir, options)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	if object, err := s.Stat(ctx, hash); err == nil {
+		return object, nil
+	}
+
+	key, _ := s.key(hash)
+	input := &s3manager.UploadInput{
+		Bucket:   aws.String(s.options.Bucket),
+		Key:      aws.String(key),
+		Body:     content,
+		Metadata: map[string]*string{"sha256": aws.String(hash)},
+	}
+	if options.Mime != "" {
+		input.ContentType = aws.String(options.Mime)
+	}
+	if size < s3manager.DefaultUploadPartSize {
+		// single part uploads are verified by the server
+		sum, _ := hex.DecodeString(hash)
+		input.ChecksumAlgorithm = aws.String(s3.ChecksumAlgorithmSha256)
+		input.ChecksumSHA256 = aws.String(base64.StdEncoding.EncodeToString(sum))
+	}
+
+	if _, err = s.uploader.UploadWithContext(ctx, input); err != nil {
+		return nil, fmt.Errorf("failed to upload %s: %w", hash, err)
+	}
+
+	return s.Stat(ctx, hash)
+}
+
+func (s *S3) Get(ctx context.Context, hash string) (io.ReadCloser, error) {
+	key, err := s.key(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := s.client.GetObjectWithContext(ctx, &s3.GetObjectInput{Bucket: aws.String(s.options.Bucket), Key: aws.String(key)})
+	if err != nil {
+		return nil, s.wrapError(hash, err)
+	}
+
+	return output.Body, nil
+}
+
+func (s *S3) Stat(ctx context.Context, hash string) (*Object, error) {
+	key, err := s.key(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := s.client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{Bucket: aws.String(s.options.Bucket), Key: aws.String(key)})
+	if err != nil {
+		return nil, s.wrapError(hash, err)
+	}
+
+	return &Object{
+		Hash:    strings.ToLower(hash),
+		Size:    aws.Int64Value(output.ContentLength),
+		Mime:    aws.StringValue(output.ContentType),
+		ModTime: aws.TimeValue(output.LastModified),
+	}, nil
+}
+
+func (s *S3) Delete(ctx context.Context, hash string) error {
+	key, err := s.key(hash)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{Bucket: aws.String(s.options.Bucket), Key: aws.String(key)})
+	if err = s.wrapError(hash, err); err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	return nil
+}
+
+// URL returns the presigned download url of the content
+func (s *S3) URL(_ context.Context, hash string, expires time.Duration) (string, error) {
+	key, err := s.key(hash)
+	if err != nil {
+		return "", err
+	}
+
+	request, _ := s.client.GetObjectRequest(&s3.GetObjectInput{Bucket: aws.String(s.options.Bucket), Key: aws.String(key)})
+	return request.Presign(expires)
+}
+
+func (s *S3) wrapError(hash string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var requestFailure awserr.RequestFailure
+	if errors.As(err, &requestFailure) && requestFailure.StatusCode() == http.StatusNotFound {
+		return fmt.Errorf("%w: %s", ErrNotFound, hash)
+	}
+
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) && awsErr.Code() == s3.ErrCodeNoSuchKey {
+		return fmt.Errorf("%w: %s", ErrNotFound, hash)
+	}
+
+	return err
+}
@@ -0,0 +1,159 @@
+// Package storage stores file contents addressed by their hash, identical files of different releases, packages and
+// entities are stored once and model.File.Hash identifies the content.
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"dev.hackerman.me/artheon/veverse-shared/model"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound     = errors.New("object not found")
+	ErrInvalidHash  = errors.New("invalid content hash")
+	ErrHashMismatch = errors.New("content hash mismatch")
+	ErrSizeMismatch = errors.New("content size mismatch")
+)
+
+// Object is the stored content
+type Object struct {
+	Hash    string    `json:"hash"` // hex encoded sha256 of the content
+	Size    int64     `json:"size"`
+	Mime    string    `json:"mime,omitempty"` // content type, if supported by the backend
+	ModTime time.Time `json:"modTime"`
+}
+
+type PutOptions struct {
+	Hash string // expected hash of the content (optional), the content is rejected if it does not match
+	Size int64  // expected size of the content (optional)
+	Mime string // content type (optional)
+}
+
+// Storage is a content-addressed blob store
+type Storage interface {
+	// Put stores the content under its sha256 hash, content that is already stored is not uploaded again
+	Put(ctx context.Context, r io.Reader, options PutOptions) (*Object, error)
+	// Get opens the content, ErrNotFound is returned if there is no content with the hash
+	Get(ctx context.Context, hash string) (io.ReadCloser, error)
+	// Stat returns the object without reading the content
+	Stat(ctx context.Context, hash string) (*Object, error)
+	// Delete removes the content, deleting missing content is not an error
+	Delete(ctx context.Context, hash string) error
+	// URL returns the download url of the content, valid for the duration if the backend supports presigned urls
+	URL(ctx context.Context, hash string, expires time.Duration) (string, error)
+}
+
+var (
+	_ Storage = (*FileSystem)(nil)
+	_ Storage = (*S3)(nil)
+)
+
+// Path returns the storage path of the content, two-level prefixes keep directories and listings small. The hash is
+// normalized, ErrInvalidHash is returned if it is not a hex encoded sha256 hash.
+func Path(hash string) (string, error) {
+	hash, err := NormalizeHash(hash)
+	if err != nil {
+		return "", err
+	}
+	return "files/" + hash[:2] + "/" + hash, nil
+}
+
+// NormalizeHash validates the hex encoded sha256 hash and returns it in lower case
+func NormalizeHash(hash string) (string, error) {
+	hash = strings.ToLower(hash)
+	if len(hash) != sha256.Size*2 {
+		return "", fmt.Errorf("%w: %q", ErrInvalidHash, hash)
+	}
+	if _, err := hex.DecodeString(hash); err != nil {
+		return "", fmt.Errorf("%w: %q", ErrInvalidHash, hash)
+	}
+	return hash, nil
+}
+
+// GetFile opens the content of the file by its hash
+func GetFile(ctx context.Context, s Storage, f model.File) (io.ReadCloser, error) {
+	if f.Hash == nil {
+		return nil, fmt.Errorf("%w: file %s has no hash", ErrInvalidHash, f.Id)
+	}
+	return s.Get(ctx, *f.Hash)
+}
+
+// FileURL returns the download url of the file content by its hash
+func FileURL(ctx context.Context, s Storage, f model.File, expires time.Duration) (string, error) {
+	if f.Hash == nil {
+		return "", fmt.Errorf("%w: file %s has no hash", ErrInvalidHash, f.Id)
+	}
+	return s.URL(ctx, *f.Hash, expires)
+}
+
+// spool copies the content to a temporary file in dir while hashing it, the caller must remove the file. Seekable
+// readers are hashed in place and returned as is.
+func spool(r io.Reader, dir string, options PutOptions) (content io.ReadSeeker, hash string, size int64, cleanup func(), err error) {
+	cleanup = func() {}
+
+	if options.Hash != "" {
+		if options.Hash, err = NormalizeHash(options.Hash); err != nil {
+			return nil, "", 0, cleanup, err
+		}
+	}
+
+	h := sha256.New()
+
+	if rs, ok := r.(io.ReadSeeker); ok {
+		start, err := rs.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, "", 0, cleanup, err
+		}
+		if size, err = io.Copy(h, rs); err != nil {
+			return nil, "", 0, cleanup, err
+		}
+		if _, err = rs.Seek(start, io.SeekStart); err != nil {
+			return nil, "", 0, cleanup, err
+		}
+		content = rs
+	} else {
+		file, err := os.CreateTemp(dir, "put-*")
+		if err != nil {
+			return nil, "", 0, cleanup, err
+		}
+		cleanup = func() {
+			_ = file.Close()
+			_ = os.Remove(file.Name())
+		}
+		if size, err = io.Copy(io.MultiWriter(file, h), r); err != nil {
+			cleanup()
+			return nil, "", 0, func() {}, err
+		}
+		if _, err = file.Seek(0, io.SeekStart); err != nil {
+			cleanup()
+			return nil, "", 0, func() {}, err
+		}
+		content = file
+	}
+
+	hash = hex.EncodeToString(h.Sum(nil))
+
+	if err = checkContent(hash, size, options); err != nil {
+		cleanup()
+		return nil, "", 0, func() {}, err
+	}
+
+	return content, hash, size, cleanup, nil
+}
+
+func checkContent(hash string, size int64, options PutOptions) error {
+	if options.Hash != "" && options.Hash != hash {
+		return fmt.Errorf("%w: expected %s, got %s", ErrHashMismatch, options.Hash, hash)
+	}
+	if options.Size > 0 && options.Size != size {
+		return fmt.Errorf("%w: expected %d, got %d", ErrSizeMismatch, options.Size, size)
+	}
+	return nil
+}
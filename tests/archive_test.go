@@ -0,0 +1,255 @@
+package tests
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"dev.hackerman.me/artheon/veverse-shared/archive"
+)
+
+type zipEntry struct {
+	name    string
+	content string
+	mode    os.FileMode
+}
+
+func writeTestZip(t *testing.T, path string, entries []zipEntry) {
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	w := zip.NewWriter(file)
+	for _, e := range entries {
+		header := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		if e.mode != 0 {
+			header.SetMode(e.mode)
+		}
+		writer, err := w.CreateHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = writer.Write([]byte(e.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtractZipArchive(t *testing.T) {
+	dir := t.TempDir()
+	zipPath := filepath.Join(dir, "release.zip")
+	dest := filepath.Join(dir, "out")
+
+	writeTestZip(t, zipPath, []zipEntry{
+		{name: "Game/", mode: os.ModeDir | 0755},
+		{name: "Game/Game.sh", content: "#!/bin/sh\necho game\n", mode: 0755},
+		{name: "Game/Binaries/Linux/Game", content: "\x7fELF binary"}, // no unix mode, detected by content
+		{name: "Game/Content/Paks/Game.pak", content: "pak content", mode: 0644},
+		{name: "Game/Readme.txt", content: "readme"},
+		{name: "Game/Current", content: "Binaries", mode: os.ModeSymlink | 0777},
+	})
+
+	var progress archive.ExtractProgress
+	err := archive.ExtractZipArchive(zipPath, dest, archive.ExtractZipOptions{OnProgress: func(p archive.ExtractProgress) { progress = p }})
+	if err != nil {
+		t.Fatalf("ExtractZipArchive() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dest, "Game", "Content", "Paks", "Game.pak"))
+	if err != nil || string(data) != "pak content" {
+		t.Errorf("Game.pak content = %q, error = %v", data, err)
+	}
+
+	for _, p := range []string{"Game/Game.sh", "Game/Binaries/Linux/Game"} {
+		info, err := os.Stat(filepath.Join(dest, filepath.FromSlash(p)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm()&0100 == 0 {
+			t.Errorf("%v mode = %v, expected executable", p, info.Mode())
+		}
+	}
+
+	info, err := os.Stat(filepath.Join(dest, "Game", "Readme.txt"))
+	if err != nil || info.Mode().Perm()&0111 != 0 {
+		t.Errorf("Readme.txt mode = %v, expected not executable", info.Mode())
+	}
+
+	if link, err := os.Readlink(filepath.Join(dest, "Game", "Current")); err != nil || link != "Binaries" {
+		t.Errorf("Current link = %v, error = %v", link, err)
+	}
+
+	if progress.Files != 5 || progress.TotalFiles != 5 || progress.Bytes != progress.TotalBytes {
+		t.Errorf("progress = %+v", progress)
+	}
+
+	// resumed extraction skips complete files and replaces modified ones
+	if err = os.WriteFile(filepath.Join(dest, "Game", "Readme.txt"), []byte("README"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err = archive.ExtractZipArchive(zipPath, dest, archive.ExtractZipOptions{Resume: true, OnProgress: func(p archive.ExtractProgress) { progress = p }})
+	if err != nil {
+		t.Fatalf("ExtractZipArchive() error = %v", err)
+	}
+	if progress.Skipped != 3 {
+		t.Errorf("resumed progress = %+v, expected 3 skipped files", progress)
+	}
+	if data, _ = os.ReadFile(filepath.Join(dest, "Game", "Readme.txt")); string(data) != "readme" {
+		t.Errorf("Readme.txt content = %q after resume", data)
+	}
+
+	// size limits
+	err = archive.ExtractZipArchive(zipPath, filepath.Join(dir, "limited"), archive.ExtractZipOptions{MaxSize: 16})
+	if !errors.Is(err, archive.ErrSizeExceeded) {
+		t.Errorf("ExtractZipArchive() error = %v, expected size limit", err)
+	}
+}
+
+func TestExtractZipArchiveUnsafePaths(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []zipEntry
+	}{
+		{"parent directory", []zipEntry{{name: "../evil.sh", content: "evil"}}},
+		{"nested parent directory", []zipEntry{{name: "Game/../../evil.sh", content: "evil"}}},
+		{"absolute path", []zipEntry{{name: "/tmp/evil.sh", content: "evil"}}},
+		{"windows separators", []zipEntry{{name: "..\\evil.sh", content: "evil"}}},
+		{"symlink outside", []zipEntry{{name: "Game/link", content: "../../etc", mode: os.ModeSymlink | 0777}}},
+		{"write through symlinks", []zipEntry{
+			{name: "a", content: ".", mode: os.ModeSymlink | 0777},
+			{name: "a/b", content: "..", mode: os.ModeSymlink | 0777},
+			{name: "a/b/evil.sh", content: "evil"},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			zipPath := filepath.Join(dir, "evil.zip")
+			writeTestZip(t, zipPath, tt.entries)
+
+			err := archive.ExtractZipArchive(zipPath, filepath.Join(dir, "out"), archive.ExtractZipOptions{})
+			if !errors.Is(err, archive.ErrUnsafePath) {
+				t.Errorf("ExtractZipArchive() error = %v, expected unsafe path", err)
+			}
+			if _, err = os.Stat(filepath.Join(dir, "evil.sh")); !os.IsNotExist(err) {
+				t.Errorf("file written outside of the destination")
+			}
+		})
+	}
+}
+
+func TestArchiveFormats(t *testing.T) {
+	base := t.TempDir()
+
+	content := map[string]string{
+		"Server/Binaries/Linux/Server":   "\x7fELF server",
+		"Server/Content/Paks/Server.pak": strings.Repeat("pak content ", 1000),
+		"Server/Config/Default.ini":      "[Server]\nPort=7777\n",
+	}
+
+	for name, data := range content {
+		p := filepath.Join(base, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chmod(filepath.Join(base, "Server", "Binaries", "Linux", "Server"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("Binaries/Linux/Server", filepath.Join(base, "Server", "Start")); err != nil {
+		t.Fatal(err)
+	}
+
+	files := []string{"Server/Content/Paks/Server.pak", "Server/Start", "Server/Config/Default.ini", "Server/Binaries/Linux/Server"}
+
+	for _, format := range []archive.Format{archive.FormatZip, archive.FormatTar, archive.FormatTarGz, archive.FormatTarZst} {
+		t.Run(string(format), func(t *testing.T) {
+			var first, second bytes.Buffer
+			if err := archive.WriteArchive(&first, base, files, archive.WriterOptions{Format: format}); err != nil {
+				t.Fatalf("WriteArchive() error = %v", err)
+			}
+
+			// output does not depend on file order and modification times
+			later := time.Now().Add(time.Hour)
+			if err := os.Chtimes(filepath.Join(base, "Server", "Config", "Default.ini"), later, later); err != nil {
+				t.Fatal(err)
+			}
+			reversed := []string{files[3], files[2], files[1], files[0]}
+			if err := archive.WriteArchive(&second, base, reversed, archive.WriterOptions{Format: format}); err != nil {
+				t.Fatalf("WriteArchive() error = %v", err)
+			}
+
+			if !bytes.Equal(first.Bytes(), second.Bytes()) {
+				t.Errorf("archives of identical inputs differ")
+			}
+
+			reader, err := archive.NewReader(bytes.NewReader(first.Bytes()), format)
+			if err != nil {
+				t.Fatalf("NewReader() error = %v", err)
+			}
+			defer reader.Close()
+
+			var names []string
+			for {
+				entry, err := reader.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("Next() error = %v", err)
+				}
+				names = append(names, entry.Name)
+
+				switch {
+				case entry.Mode&os.ModeSymlink != 0:
+					if entry.Link != "Binaries/Linux/Server" {
+						t.Errorf("%v link = %v", entry.Name, entry.Link)
+					}
+				default:
+					data, err := io.ReadAll(reader)
+					if err != nil {
+						t.Fatalf("Read() error = %v", err)
+					}
+					if string(data) != content[entry.Name] {
+						t.Errorf("%v content mismatch", entry.Name)
+					}
+					if entry.Name == "Server/Binaries/Linux/Server" && entry.Mode.Perm() != 0755 {
+						t.Errorf("%v mode = %v, expected 0755", entry.Name, entry.Mode)
+					}
+				}
+			}
+
+			expected := []string{"Server/Binaries/Linux/Server", "Server/Config/Default.ini", "Server/Content/Paks/Server.pak", "Server/Start"}
+			if strings.Join(names, ",") != strings.Join(expected, ",") {
+				t.Errorf("entries = %v, expected %v", names, expected)
+			}
+		})
+	}
+
+	var stored, compressed bytes.Buffer
+	if err := archive.WriteArchive(&stored, base, files, archive.WriterOptions{Format: archive.FormatZip, Store: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := archive.WriteArchive(&compressed, base, files, archive.WriterOptions{Format: archive.FormatZip, Level: 9}); err != nil {
+		t.Fatal(err)
+	}
+	if compressed.Len() >= stored.Len() {
+		t.Errorf("compressed zip size %v is not less than stored %v", compressed.Len(), stored.Len())
+	}
+}
@@ -0,0 +1,50 @@
+package tests
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"dev.hackerman.me/artheon/veverse-shared/model"
+)
+
+func TestValidateCommentText(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected string
+		valid    bool
+	}{
+		{"  hello \n", "hello", true},
+		{"", "", false},
+		{" \t\n", "", false},
+		{strings.Repeat("ж", model.MaxCommentLength), strings.Repeat("ж", model.MaxCommentLength), true},
+		{strings.Repeat("ж", model.MaxCommentLength+1), "", false},
+	}
+
+	for _, tt := range tests {
+		text, err := model.ValidateCommentText(tt.text)
+		if (err == nil) != tt.valid || text != tt.expected {
+			t.Errorf("ValidateCommentText(%.16q) = %.16q, error = %v", tt.text, text, err)
+		}
+	}
+}
+
+func TestRequestCanPostComment(t *testing.T) {
+	tests := []struct {
+		name     string
+		user     model.User
+		expected error
+	}{
+		{"user", model.User{}, nil},
+		{"muted", model.User{IsMuted: true}, model.ErrUserMuted},
+		{"banned", model.User{IsBanned: true}, model.ErrUserBanned},
+		{"muted and banned", model.User{IsMuted: true, IsBanned: true}, model.ErrUserBanned},
+		{"muted admin", model.User{IsAdmin: true, IsMuted: true}, model.ErrUserMuted},
+	}
+
+	for _, tt := range tests {
+		if err := model.RequestCanPostComment(&tt.user); !errors.Is(err, tt.expected) {
+			t.Errorf("%s: RequestCanPostComment() error = %v, expected %v", tt.name, err, tt.expected)
+		}
+	}
+}
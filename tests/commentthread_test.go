@@ -0,0 +1,127 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	glContext "dev.hackerman.me/artheon/veverse-shared/context"
+	"dev.hackerman.me/artheon/veverse-shared/model"
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func TestCommentThreads(t *testing.T) {
+	ctx, err := GetDatabaseContext(context.Background())
+	if err != nil {
+		t.Fatalf("failed to get database context: %v", err)
+	}
+
+	owner := &model.User{Entity: model.Entity{Identifier: model.Identifier{Id: uuid.FromStringOrNil("1578BA66-3334-496E-8BB8-1A0696B42C68")}}}
+	author := &model.User{Entity: model.Entity{Identifier: model.Identifier{Id: uuid.Must(uuid.NewV4())}}}
+	stranger := &model.User{Entity: model.Entity{Identifier: model.Identifier{Id: uuid.Must(uuid.NewV4())}}}
+	admin := &model.User{Entity: model.Entity{Identifier: model.Identifier{Id: uuid.Must(uuid.NewV4())}}, IsAdmin: true}
+
+	// the owner of the public world moderates its comments
+	name := "test-comments-" + uuid.Must(uuid.NewV4()).String()
+	pkg, err := model.CreatePackage(ctx, owner, model.PackageCreateMetadata{Name: name})
+	if err != nil {
+		t.Fatalf("CreatePackage() error = %v", err)
+	}
+
+	world, err := model.CreateWorld(ctx, owner, model.CreateWorldRequest{Name: name, Map: "TestMap", PackageId: pkg.Id})
+	if err != nil {
+		t.Fatalf("CreateWorld() error = %v", err)
+	}
+
+	parent, err := model.CreateComment(ctx, author, world.Id, model.CreateCommentRequest{Text: "parent"})
+	if err != nil {
+		t.Fatalf("CreateComment() error = %v", err)
+	}
+
+	reply, err := model.CreateComment(ctx, owner, world.Id, model.CreateCommentRequest{ParentId: &parent.Id, Text: "reply"})
+	if err != nil {
+		t.Fatalf("CreateComment() error = %v", err)
+	}
+
+	batch, err := model.IndexComments(ctx, stranger, world.Id, model.IndexCommentsRequest{})
+	if err != nil || batch.Total != 1 || batch.Entities[0].Replies != 1 {
+		t.Fatalf("IndexComments() = %v, error = %v, expected the parent with a reply", batch, err)
+	}
+
+	batch, err = model.IndexComments(ctx, stranger, world.Id, model.IndexCommentsRequest{ParentId: &parent.Id})
+	if err != nil || batch.Total != 1 || batch.Entities[0].Id != reply.Id {
+		t.Fatalf("IndexComments() = %v, error = %v, expected the reply", batch, err)
+	}
+
+	// hidden comments can not be replied to and are visible to their authors only
+	if err = model.HideComment(ctx, stranger, parent.Id, true); !errors.Is(err, model.ErrNoPermission) {
+		t.Errorf("HideComment() error = %v, expected no permission for a stranger", err)
+	}
+
+	if err = model.HideComment(ctx, owner, parent.Id, true); err != nil {
+		t.Fatalf("HideComment() error = %v", err)
+	}
+
+	if _, err = model.CreateComment(ctx, stranger, world.Id, model.CreateCommentRequest{ParentId: &parent.Id, Text: "blocked"}); !errors.Is(err, model.ErrNoPermission) {
+		t.Errorf("CreateComment() error = %v, expected no permission for a reply to a hidden comment", err)
+	}
+
+	if batch, err = model.IndexComments(ctx, stranger, world.Id, model.IndexCommentsRequest{}); err != nil || batch.Total != 0 {
+		t.Errorf("IndexComments() = %v, error = %v, expected no comments for a stranger", batch, err)
+	}
+
+	if batch, err = model.IndexComments(ctx, author, world.Id, model.IndexCommentsRequest{}); err != nil || batch.Total != 1 {
+		t.Errorf("IndexComments() = %v, error = %v, expected the hidden comment for its author", batch, err)
+	}
+
+	if err = model.HideComment(ctx, owner, parent.Id, false); err != nil {
+		t.Fatalf("HideComment() error = %v", err)
+	}
+
+	// deleted comments keep their place in the thread without the text
+	if err = model.DeleteComment(ctx, stranger, reply.Id); !errors.Is(err, model.ErrNoPermission) {
+		t.Errorf("DeleteComment() error = %v, expected no permission for a stranger", err)
+	}
+
+	if err = model.DeleteComment(ctx, owner, reply.Id); err != nil {
+		t.Fatalf("DeleteComment() error = %v", err)
+	}
+
+	batch, err = model.IndexComments(ctx, stranger, world.Id, model.IndexCommentsRequest{ParentId: &parent.Id})
+	if err != nil || batch.Total != 1 {
+		t.Fatalf("IndexComments() = %v, error = %v, expected the deleted reply", batch, err)
+	}
+
+	if deleted := batch.Entities[0]; deleted.DeletedAt == nil || deleted.Text != "" {
+		t.Errorf("deleted reply = %v, expected no text", deleted)
+	}
+
+	if batch, err = model.IndexComments(ctx, stranger, world.Id, model.IndexCommentsRequest{}); err != nil || batch.Total != 1 || batch.Entities[0].Replies != 0 {
+		t.Errorf("IndexComments() = %v, error = %v, expected no visible replies", batch, err)
+	}
+
+	// reporting again does not reopen a dismissed report
+	if err = model.ReportComment(ctx, stranger, parent.Id, ptr("spam")); err != nil {
+		t.Fatalf("ReportComment() error = %v", err)
+	}
+
+	if err = model.ResolveCommentReports(ctx, admin, parent.Id, model.CommentReportStatusDismissed, false); err != nil {
+		t.Fatalf("ResolveCommentReports() error = %v", err)
+	}
+
+	if err = model.ReportComment(ctx, stranger, parent.Id, ptr("still spam")); err != nil {
+		t.Fatalf("ReportComment() error = %v", err)
+	}
+
+	var status string
+	db := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	err = db.QueryRow(ctx, `select status from comment_report_v2 where comment_id = $1 and user_id = $2`, parent.Id, stranger.Id).Scan(&status)
+	if err != nil {
+		t.Fatalf("failed to get comment report: %v", err)
+	}
+
+	if status != model.CommentReportStatusDismissed {
+		t.Errorf("report status = %v, expected %v", status, model.CommentReportStatusDismissed)
+	}
+}
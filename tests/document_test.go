@@ -0,0 +1,51 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+
+	"dev.hackerman.me/artheon/veverse-shared/document"
+	"dev.hackerman.me/artheon/veverse-shared/model"
+)
+
+// testRenderer renders white pages of the size
+func testRenderer(pages int, width, height int) document.Renderer {
+	return document.RendererFunc(func(ctx context.Context, filename string, options document.RenderOptions, page func(int, image.Image) error) error {
+		for i := 0; i < pages; i++ {
+			if err := page(i, testImage(width, height, color.White)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func TestProcessDocument(t *testing.T) {
+	pages, err := document.Process(context.Background(), testRenderer(3, 1240, 1754), "document.pdf", document.Options{RenderOptions: document.RenderOptions{Size: 1024}})
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	if len(pages) != 3 {
+		t.Fatalf("Process() returned %d pages", len(pages))
+	}
+
+	for i, p := range pages {
+		if p.Index != i || p.Width != 724 || p.Height != 1024 || p.Mime != "image/jpeg" || p.Hash == "" {
+			t.Errorf("page %d = %v %v %dx%d", i, p.Index, p.Mime, p.Width, p.Height)
+		}
+	}
+
+	metadata := pages[2].LinkMetadata("https://example.com/page-2.jpg")
+	if metadata.Type != model.FileTypeDocumentPage || metadata.Index != 2 || metadata.Width != 724 || *metadata.Hash != pages[2].Hash {
+		t.Errorf("link metadata = %+v", metadata)
+	}
+
+	_, err = document.Process(context.Background(), testRenderer(5, 100, 100), "document.pdf", document.Options{RenderOptions: document.RenderOptions{MaxPages: 4}})
+	if !errors.Is(err, document.ErrTooManyPages) {
+		t.Errorf("Process() error = %v, expected too many pages", err)
+	}
+}
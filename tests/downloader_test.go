@@ -0,0 +1,224 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"dev.hackerman.me/artheon/veverse-shared/downloader"
+	"dev.hackerman.me/artheon/veverse-shared/model"
+)
+
+func TestDownload(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	content := map[string][]byte{}
+	for _, name := range []string{"Game.exe", "Game.pak", "Interrupted.pak", "Resumed.pak"} {
+		data := make([]byte, 100*1024+random.Intn(1024))
+		random.Read(data)
+		content[name] = data
+	}
+
+	var (
+		mu          sync.Mutex
+		ranges      = map[string][]string{}
+		interrupted = false
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, "/")
+		data, ok := content[name]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		mu.Lock()
+		ranges[name] = append(ranges[name], r.Header.Get("Range"))
+		interrupt := name == "Interrupted.pak" && !interrupted
+		interrupted = interrupted || interrupt
+		mu.Unlock()
+
+		if interrupt {
+			// close the connection after a half of the file
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+			_, _ = w.Write(data[:len(data)/2])
+			return
+		}
+
+		http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(data))
+	}))
+	defer server.Close()
+
+	file := func(name string, path string) model.File {
+		sum := sha256.Sum256(content[name])
+		hash := hex.EncodeToString(sum[:])
+		size := int64(len(content[name]))
+		return model.File{Type: model.FileTypeRelease, Url: server.URL + "/" + name, OriginalPath: &path, Hash: &hash, Size: &size}
+	}
+
+	dir := t.TempDir()
+
+	files := []model.File{
+		file("Game.exe", "Game/Binaries/Win64/Game.exe"),
+		file("Game.pak", "Game/Content/Paks/Game.pak"),
+		file("Interrupted.pak", "Game/Content/Paks/Interrupted.pak"),
+		file("Resumed.pak", "Game/Content/Paks/Resumed.pak"),
+	}
+
+	// partial file left by a previous run
+	resumed := filepath.Join(dir, "Game", "Content", "Paks", "Resumed.pak"+downloader.TempSuffix)
+	if err := os.MkdirAll(filepath.Dir(resumed), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(resumed, content["Resumed.pak"][:1000], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	progress := make(chan downloader.Progress, 1024)
+
+	var (
+		last downloader.Progress
+		done = make(chan struct{})
+	)
+	go func() {
+		for p := range progress {
+			last = p
+		}
+		close(done)
+	}()
+
+	results, err := downloader.Download(context.Background(), files, dir, downloader.Options{Concurrency: 2, Progress: progress})
+	if err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+	<-done
+
+	for _, r := range results {
+		data, err := os.ReadFile(r.Path)
+		if err != nil {
+			t.Fatalf("failed to read %v: %v", r.Path, err)
+		}
+		sum := sha256.Sum256(data)
+		if hex.EncodeToString(sum[:]) != *r.File.Hash {
+			t.Errorf("%v content mismatch", r.Path)
+		}
+		if _, err := os.Stat(r.Path + downloader.TempSuffix); !os.IsNotExist(err) {
+			t.Errorf("%v temp file is not removed", r.Path)
+		}
+	}
+
+	if r := ranges["Resumed.pak"]; len(r) != 1 || r[0] != "bytes=1000-" {
+		t.Errorf("Resumed.pak requests = %v, expected a range request", r)
+	}
+
+	if r := ranges["Interrupted.pak"]; len(r) != 2 || r[0] != "" || !strings.HasPrefix(r[1], "bytes=") {
+		t.Errorf("Interrupted.pak requests = %v, expected a resumed range request", r)
+	}
+
+	if last.Files != len(files) || last.Bytes != last.TotalBytes {
+		t.Errorf("last progress = %+v", last)
+	}
+
+	// valid files are not downloaded again
+	results, err = downloader.Download(context.Background(), files[:1], dir, downloader.Options{})
+	if err != nil || !results[0].Skipped {
+		t.Errorf("Download() skipped = %v, error = %v", results[0].Skipped, err)
+	}
+
+	// corrupted downloads are rejected
+	broken := file("Game.pak", "Broken.pak")
+	wrong := strings.Repeat("0", 64)
+	broken.Hash = &wrong
+	results, err = downloader.Download(context.Background(), []model.File{broken}, dir, downloader.Options{Retries: -1})
+	if !errors.Is(err, downloader.ErrHashMismatch) {
+		t.Errorf("Download() error = %v, expected hash mismatch", err)
+	}
+	if _, err := os.Stat(results[0].Path); !os.IsNotExist(err) {
+		t.Errorf("corrupted file must not be moved to the destination")
+	}
+}
+
+func TestDownloadWrongContentRange(t *testing.T) {
+	data := make([]byte, 10*1024)
+	rand.New(rand.NewSource(2)).Read(data)
+
+	var (
+		mu     sync.Mutex
+		ranges []string
+	)
+
+	// a proxy answering range requests with the wrong part of the file
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		mu.Unlock()
+
+		if r.Header.Get("Range") != "" {
+			w.Header().Set("Content-Range", "bytes 0-"+strconv.Itoa(len(data)-1001)+"/"+strconv.Itoa(len(data)))
+			w.WriteHeader(http.StatusPartialContent)
+			_, _ = w.Write(data[:len(data)-1000])
+			return
+		}
+
+		_, _ = w.Write(data)
+	}))
+	defer server.Close()
+
+	// without a hash the wrong bytes of the right size could only be detected by the content range
+	size := int64(len(data))
+	path := "Wrong.pak"
+	files := []model.File{{Type: model.FileTypeRelease, Url: server.URL + "/Wrong.pak", OriginalPath: &path, Size: &size}}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, path+downloader.TempSuffix), data[:1000], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := downloader.Download(context.Background(), files, dir, downloader.Options{})
+	if err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+
+	if downloaded, err := os.ReadFile(results[0].Path); err != nil || !bytes.Equal(downloaded, data) {
+		t.Errorf("downloaded content mismatch, error = %v", err)
+	}
+
+	if len(ranges) != 2 || ranges[0] != "bytes=1000-" || ranges[1] != "" {
+		t.Errorf("requests = %v, expected a range request and a full download", ranges)
+	}
+}
+
+func TestDownloadBandwidthLimit(t *testing.T) {
+	data := make([]byte, 64*1024)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(data))
+	}))
+	defer server.Close()
+
+	path := "file.bin"
+	size := int64(len(data))
+	files := []model.File{{Url: server.URL + "/file", OriginalPath: &path, Size: &size}}
+
+	start := time.Now()
+	_, err := downloader.Download(context.Background(), files, t.TempDir(), downloader.Options{BytesPerSecond: 128 * 1024})
+	if err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Errorf("Download() took %v, expected at least 400ms at 128 KiB/s", elapsed)
+	}
+}
@@ -0,0 +1,55 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	glContext "dev.hackerman.me/artheon/veverse-shared/context"
+	"dev.hackerman.me/artheon/veverse-shared/model"
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func TestEntityPermissions(t *testing.T) {
+	ctx, err := GetDatabaseContext(context.Background())
+	if err != nil {
+		t.Fatalf("failed to get database context: %v", err)
+	}
+
+	// test_objects.sql makes the user the owner of the entity
+	entityId := uuid.FromStringOrNil("21023FA4-B853-4E85-BE78-7C290C557AF8")
+	owner := &model.User{Entity: model.Entity{Identifier: model.Identifier{Id: uuid.FromStringOrNil("1578BA66-3334-496E-8BB8-1A0696B42C68")}}}
+	stranger := &model.User{Entity: model.Entity{Identifier: model.Identifier{Id: uuid.Must(uuid.NewV4())}}}
+
+	checks := []struct {
+		name  string
+		check func(context.Context, *model.User, uuid.UUID) (bool, error)
+	}{
+		{"view", model.RequestCanViewEntity},
+		{"edit", model.RequestCanEditEntity},
+		{"delete", model.RequestCanDeleteEntity},
+	}
+
+	for _, c := range checks {
+		if ok, err := c.check(ctx, owner, entityId); err != nil || !ok {
+			t.Errorf("owner can %s = %v, error = %v, expected true", c.name, ok, err)
+		}
+	}
+
+	for _, c := range checks[1:] {
+		if ok, err := c.check(ctx, stranger, entityId); err != nil || ok {
+			t.Errorf("stranger can %s = %v, error = %v, expected false", c.name, ok, err)
+		}
+	}
+
+	// public entities are visible to users without an access row
+	var publicId uuid.UUID
+	db := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	if err = db.QueryRow(ctx, `select id from entities where public limit 1`).Scan(&publicId); err != nil {
+		t.Fatalf("failed to get public entity: %v", err)
+	}
+
+	if ok, err := model.RequestCanViewEntity(ctx, stranger, publicId); err != nil || !ok {
+		t.Errorf("stranger can view public entity = %v, error = %v, expected true", ok, err)
+	}
+}
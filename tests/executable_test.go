@@ -0,0 +1,125 @@
+package tests
+
+import (
+	"bytes"
+	"debug/macho"
+	"debug/pe"
+	"encoding/binary"
+	"errors"
+	"os"
+	"runtime"
+	"testing"
+
+	"dev.hackerman.me/artheon/veverse-shared/executable"
+	"dev.hackerman.me/artheon/veverse-shared/unreal"
+)
+
+// testPE returns a minimal PE image header without sections, padded to the smallest file size the reader accepts
+func testPE(machine uint16, characteristics uint16) []byte {
+	var b bytes.Buffer
+	dos := make([]byte, 0x40)
+	copy(dos, "MZ")
+	binary.LittleEndian.PutUint32(dos[0x3c:], 0x40)
+	b.Write(dos)
+	b.WriteString("PE\x00\x00")
+	_ = binary.Write(&b, binary.LittleEndian, pe.FileHeader{Machine: machine, Characteristics: characteristics})
+	b.Write(make([]byte, 512-b.Len()))
+	return b.Bytes()
+}
+
+// testMachO returns a minimal 64-bit Mach-O header without load commands
+func testMachO(cpu macho.Cpu, fileType macho.Type) []byte {
+	var b bytes.Buffer
+	_ = binary.Write(&b, binary.LittleEndian, macho.FileHeader{Magic: macho.Magic64, Cpu: cpu, Type: fileType})
+	b.Write(make([]byte, 4)) // reserved field of the 64-bit header
+	return b.Bytes()
+}
+
+// testFat returns a universal binary of the Mach-O headers, each aligned to 4096 bytes
+func testFat(binaries ...[]byte) []byte {
+	var b bytes.Buffer
+	_ = binary.Write(&b, binary.BigEndian, []uint32{macho.MagicFat, uint32(len(binaries))})
+	for i, data := range binaries {
+		cpu := binary.LittleEndian.Uint32(data[4:])
+		_ = binary.Write(&b, binary.BigEndian, []uint32{cpu, 0, uint32(4096 * (i + 1)), uint32(len(data)), 12})
+	}
+	for i, data := range binaries {
+		b.Write(make([]byte, 4096*(i+1)-b.Len()))
+		b.Write(data)
+	}
+	return b.Bytes()
+}
+
+func TestInspect(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		format   executable.Format
+		kind     executable.Kind
+		archs    []executable.Arch
+		platform string
+	}{
+		{"win64 exe", testPE(pe.IMAGE_FILE_MACHINE_AMD64, pe.IMAGE_FILE_EXECUTABLE_IMAGE), executable.FormatPE, executable.KindExecutable, []executable.Arch{executable.ArchAmd64}, unreal.Windows},
+		{"win64 dll", testPE(pe.IMAGE_FILE_MACHINE_AMD64, pe.IMAGE_FILE_EXECUTABLE_IMAGE|pe.IMAGE_FILE_DLL), executable.FormatPE, executable.KindSharedLibrary, []executable.Arch{executable.ArchAmd64}, unreal.Windows},
+		{"win32 exe", testPE(pe.IMAGE_FILE_MACHINE_I386, pe.IMAGE_FILE_EXECUTABLE_IMAGE), executable.FormatPE, executable.KindExecutable, []executable.Arch{executable.ArchX86}, ""},
+		{"mac dylib", testMachO(macho.CpuArm64, macho.TypeDylib), executable.FormatMachO, executable.KindSharedLibrary, []executable.Arch{executable.ArchArm64}, unreal.Mac},
+		{"mac universal", testFat(testMachO(macho.CpuAmd64, macho.TypeExec), testMachO(macho.CpuArm64, macho.TypeExec)), executable.FormatFat, executable.KindExecutable, []executable.Arch{executable.ArchAmd64, executable.ArchArm64}, unreal.Mac},
+		{"script", []byte("#!/bin/sh\r\necho\n"), executable.FormatScript, executable.KindExecutable, nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := executable.Inspect(bytes.NewReader(tt.data))
+			if err != nil {
+				t.Fatalf("Inspect() error = %v", err)
+			}
+			if info.Format != tt.format || info.Kind != tt.kind || info.Platform != tt.platform || len(info.Archs) != len(tt.archs) {
+				t.Fatalf("Inspect() = %+v", info)
+			}
+			for i := range tt.archs {
+				if info.Archs[i] != tt.archs[i] {
+					t.Errorf("archs = %v, expected %v", info.Archs, tt.archs)
+				}
+			}
+		})
+	}
+
+	info, err := executable.Inspect(bytes.NewReader([]byte("#!/bin/sh\r\n")))
+	if err != nil || info.Interpreter != "/bin/sh" || !info.RunsOn(unreal.Linux) || info.RunsOn(unreal.Windows) {
+		t.Errorf("script info = %+v, error = %v", info, err)
+	}
+
+	info, err = executable.Inspect(bytes.NewReader(testPE(pe.IMAGE_FILE_MACHINE_AMD64, pe.IMAGE_FILE_EXECUTABLE_IMAGE)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.RunsOn(unreal.Linux) || !info.RunsOn(unreal.Windows) {
+		t.Errorf("win64 binary runs on linux")
+	}
+
+	for _, data := range []string{"", "text", "\x7fELF truncated", "\xca\xfe\xba\xbe\x00\x00\x00\x00"} {
+		if _, err = executable.Inspect(bytes.NewReader([]byte(data))); !errors.Is(err, executable.ErrUnknownFormat) {
+			t.Errorf("Inspect(%q) error = %v, expected unknown format", data, err)
+		}
+	}
+}
+
+func TestInspectFile(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the test binary is not an ELF file")
+	}
+
+	path, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := executable.InspectFile(path)
+	if err != nil {
+		t.Fatalf("InspectFile() error = %v", err)
+	}
+
+	if info.Format != executable.FormatELF || info.Kind != executable.KindExecutable || info.OS != runtime.GOOS || string(info.Arch) != runtime.GOARCH || info.Platform != unreal.Linux {
+		t.Errorf("InspectFile() = %+v", info)
+	}
+}
@@ -0,0 +1,60 @@
+package tests
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"dev.hackerman.me/artheon/veverse-shared/model"
+	"github.com/gofrs/uuid"
+)
+
+func TestLinkFile(t *testing.T) {
+	ctx, err := GetDatabaseContext(context.Background())
+	if err != nil {
+		t.Fatalf("failed to get database context: %v", err)
+	}
+
+	entityId := uuid.FromStringOrNil("21023FA4-B853-4E85-BE78-7C290C557AF8")
+	user := &model.User{Entity: model.Entity{Identifier: model.Identifier{Id: uuid.FromStringOrNil("1578BA66-3334-496E-8BB8-1A0696B42C68")}}}
+	fileType := "test-" + uuid.Must(uuid.NewV4()).String()
+
+	// concurrent links of the same file create one current file with a version per link
+	const links = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, links)
+	for i := 0; i < links; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := model.LinkFile(ctx, user, entityId, model.FileLinkRequestMetadata{Type: fileType, Url: "https://veverse.com/test.bin", Platform: "Win64"})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err = range errs {
+		if err != nil {
+			t.Fatalf("LinkFile() error = %v", err)
+		}
+	}
+
+	batch, err := model.IndexEntityFiles(ctx, user, entityId, model.FileBatchRequestMetadata{Type: fileType})
+	if err != nil {
+		t.Fatalf("IndexEntityFiles() error = %v", err)
+	}
+
+	if batch.Total != 1 || len(batch.Entities) != 1 || batch.Entities[0].Version != links {
+		t.Fatalf("IndexEntityFiles() = %+v, expected one file of version %d", batch, links)
+	}
+
+	empty := ""
+	file, err := model.ReplaceFile(ctx, user, batch.Entities[0].Id, model.FileLinkRequestMetadata{Url: "https://veverse.com/test2.bin", Mime: &empty})
+	if err != nil {
+		t.Fatalf("ReplaceFile() error = %v", err)
+	}
+
+	if file.Mime == nil || *file.Mime != model.DefaultFileMime {
+		t.Errorf("ReplaceFile() mime = %v, expected %s", file.Mime, model.DefaultFileMime)
+	}
+}
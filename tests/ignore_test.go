@@ -0,0 +1,158 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"dev.hackerman.me/artheon/veverse-shared/helper"
+)
+
+func TestIgnoreRules(t *testing.T) {
+	rules, err := helper.ParseIgnoreRules([]string{
+		"# build output",
+		"Saved",
+		"Intermediate/",
+		"**/*.pdb",
+		"!Binaries/Win64/Game.pdb",
+		"/Build",
+		"Plugins/**/Cache",
+		"*.log",
+		"!Important.log",
+		"DerivedDataCache/**",
+	})
+	if err != nil {
+		t.Fatalf("ParseIgnoreRules() error = %v", err)
+	}
+
+	tests := []struct {
+		path     string
+		isDir    bool
+		expected bool
+	}{
+		{"Saved", true, true},
+		{"Game/Saved/Logs/Game.log", false, true},
+		{"SavedGames.txt", false, false},
+		{"Intermediate", true, true},
+		{"Intermediate", false, false},
+		{"Game/Intermediate/Build/file.obj", false, true},
+		{"Binaries/Win64/Editor.pdb", false, true},
+		{"Binaries/Win64/Game.pdb", false, false},
+		{"Build", true, true},
+		{"Game/Build", true, false},
+		{"Plugins/Cache", true, true},
+		{"Plugins/Web/Source/Cache", true, true},
+		{"Plugins/Web/Source/Cached.h", false, false},
+		{"Logs/Server.log", false, true},
+		{"Logs/Important.log", false, false},
+		{"DerivedDataCache/a/b.ddp", false, true},
+		{"Content/Paks/Game.pak", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := rules.Match(tt.path, tt.isDir); got != tt.expected {
+				t.Errorf("Match(%v, %v) = %v, expected %v", tt.path, tt.isDir, got, tt.expected)
+			}
+		})
+	}
+
+	if _, err = helper.ParseIgnoreRules([]string{"[a-"}); err == nil {
+		t.Errorf("ParseIgnoreRules() expected error for invalid pattern")
+	}
+}
+
+func TestListFilesRecursiveIgnoreFile(t *testing.T) {
+	root := t.TempDir()
+
+	for _, p := range []string{
+		"Game.uproject",
+		"SavedGames.txt",
+		"Saved/Config/Game.ini",
+		"Intermediate/Build/file.obj",
+		"Binaries/Win64/Game.exe",
+		"Binaries/Win64/Game.pdb",
+		"Binaries/Win64/Editor.pdb",
+		"Plugins/Web/Intermediate/file.obj",
+	} {
+		file := filepath.Join(root, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(p), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ignoreFile := filepath.Join(root, helper.IgnoreFileName)
+	if err := os.WriteFile(ignoreFile, []byte("Saved/\nIntermediate/\n*.pdb\n!Game.pdb\n"+helper.IgnoreFileName+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err := helper.LoadIgnoreFile(ignoreFile)
+	if err != nil {
+		t.Fatalf("LoadIgnoreFile() error = %v", err)
+	}
+
+	files, err := helper.ListFilesRecursiveWithRules(root, rules)
+	if err != nil {
+		t.Fatalf("ListFilesRecursiveWithRules() error = %v", err)
+	}
+
+	for i := range files {
+		files[i] = filepath.ToSlash(files[i])
+	}
+	sort.Strings(files)
+
+	expected := []string{"Binaries/Win64/Game.exe", "Binaries/Win64/Game.pdb", "Game.uproject", "SavedGames.txt"}
+	if strings.Join(files, ",") != strings.Join(expected, ",") {
+		t.Errorf("ListFilesRecursiveWithRules() = %v, expected %v", files, expected)
+	}
+}
+
+func TestIgnoreRulesTrailingDoubleStar(t *testing.T) {
+	rules, err := helper.ParseIgnoreRules([]string{"Saved/**", "!Saved/Config/", "!Saved/Config/**"})
+	if err != nil {
+		t.Fatalf("ParseIgnoreRules() error = %v", err)
+	}
+
+	tests := []struct {
+		path     string
+		isDir    bool
+		expected bool
+	}{
+		{"Saved", true, false},
+		{"Saved/Logs", true, true},
+		{"Saved/Logs/Game.log", false, true},
+		{"Saved/Config", true, false},
+		{"Saved/Config/Game.ini", false, false},
+	}
+
+	for _, tt := range tests {
+		if got := rules.Match(tt.path, tt.isDir); got != tt.expected {
+			t.Errorf("Match(%v, %v) = %v, expected %v", tt.path, tt.isDir, got, tt.expected)
+		}
+	}
+
+	root := t.TempDir()
+	for _, p := range []string{"Saved/Config/Game.ini", "Saved/Logs/Game.log"} {
+		file := filepath.Join(root, filepath.FromSlash(p))
+		if err = os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err = os.WriteFile(file, []byte(p), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := helper.ListFilesRecursiveWithRules(root, rules)
+	if err != nil {
+		t.Fatalf("ListFilesRecursiveWithRules() error = %v", err)
+	}
+
+	if len(files) != 1 || filepath.ToSlash(files[0]) != "Saved/Config/Game.ini" {
+		t.Errorf("ListFilesRecursiveWithRules() = %v, expected [Saved/Config/Game.ini]", files)
+	}
+}
@@ -0,0 +1,153 @@
+package tests
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"dev.hackerman.me/artheon/veverse-shared/imaging"
+	"dev.hackerman.me/artheon/veverse-shared/model"
+)
+
+func testImage(width, height int, c color.Color) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestImageSizes(t *testing.T) {
+	fitTests := []struct{ w, h, maxW, maxH, expectedW, expectedH int }{
+		{1920, 1080, 512, 512, 512, 288},
+		{1080, 1920, 512, 512, 288, 512},
+		{256, 128, 512, 512, 256, 128},
+		{4000, 1, 512, 512, 512, 1},
+	}
+	for _, tt := range fitTests {
+		if w, h := imaging.Fit(tt.w, tt.h, tt.maxW, tt.maxH); w != tt.expectedW || h != tt.expectedH {
+			t.Errorf("Fit(%d, %d) = %d, %d, expected %d, %d", tt.w, tt.h, w, h, tt.expectedW, tt.expectedH)
+		}
+	}
+
+	potTests := []struct{ size, max, expected int }{
+		{1920, 2048, 2048},
+		{1080, 2048, 1024},
+		{1500, 2048, 2048},
+		{1400, 2048, 1024},
+		{8192, 2048, 2048},
+		{1, 2048, 1},
+	}
+	for _, tt := range potTests {
+		if p := imaging.PowerOfTwo(tt.size, tt.max); p != tt.expected {
+			t.Errorf("PowerOfTwo(%d, %d) = %d, expected %d", tt.size, tt.max, p, tt.expected)
+		}
+	}
+}
+
+func TestResize(t *testing.T) {
+	// half black, half white averages to gray
+	src := testImage(4, 2, color.White)
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 2; x++ {
+			src.Set(x, y, color.Black)
+		}
+	}
+
+	dst := imaging.Resize(src, 1, 1)
+	if r, _, _, a := dst.At(0, 0).RGBA(); r>>8 < 126 || r>>8 > 129 || a>>8 != 255 {
+		t.Errorf("Resize() color = %v", dst.At(0, 0))
+	}
+
+	// transparent pixels do not darken opaque ones
+	src = testImage(2, 1, color.NRGBA{R: 255, A: 255})
+	src.Set(1, 0, color.NRGBA{})
+	dst = imaging.Resize(src, 1, 1)
+	if c := color.NRGBAModel.Convert(dst.At(0, 0)).(color.NRGBA); c.R < 254 || c.A < 127 || c.A > 128 {
+		t.Errorf("Resize() color = %v", c)
+	}
+
+	dst = imaging.Resize(src, 8, 3)
+	if dst.Bounds().Dx() != 8 || dst.Bounds().Dy() != 3 {
+		t.Errorf("Resize() size = %v", dst.Bounds())
+	}
+}
+
+func TestProcessImage(t *testing.T) {
+	var opaque bytes.Buffer
+	if err := jpeg.Encode(&opaque, testImage(1920, 1080, color.NRGBA{R: 200, G: 100, B: 50, A: 255}), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	variants, err := imaging.Process(bytes.NewReader(opaque.Bytes()), imaging.Options{})
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	expected := []struct {
+		t             model.UploadImageType
+		mime          string
+		width, height int
+	}{
+		{model.ImageFull, "image/jpeg", 1920, 1080},
+		{model.ImagePreview, "image/jpeg", 512, 288},
+		{model.ImageTexture, "image/png", 2048, 1024},
+	}
+
+	if len(variants) != len(expected) {
+		t.Fatalf("Process() returned %d variants", len(variants))
+	}
+
+	for i, e := range expected {
+		v := variants[i]
+		if v.Type != e.t || v.Mime != e.mime || v.Width != e.width || v.Height != e.height {
+			t.Errorf("variant %d = %v %v %dx%d, expected %v %v %dx%d", i, v.Type, v.Mime, v.Width, v.Height, e.t, e.mime, e.width, e.height)
+		}
+		sum := sha256.Sum256(v.Data)
+		if v.Hash != hex.EncodeToString(sum[:]) {
+			t.Errorf("variant %d hash mismatch", i)
+		}
+		config, _, err := image.DecodeConfig(bytes.NewReader(v.Data))
+		if err != nil || config.Width != e.width || config.Height != e.height {
+			t.Errorf("variant %d decoded size = %dx%d, error = %v", i, config.Width, config.Height, err)
+		}
+	}
+
+	if !bytes.Equal(variants[0].Data, opaque.Bytes()) {
+		t.Errorf("full image is not the uploaded image")
+	}
+
+	metadata := variants[1].LinkMetadata("https://example.com/preview.jpg")
+	if metadata.Type != model.FileTypeImagePreview || metadata.Width != 512 || *metadata.Size != len(variants[1].Data) || *metadata.Hash != variants[1].Hash {
+		t.Errorf("link metadata = %+v", metadata)
+	}
+
+	// transparent previews keep the alpha channel
+	var transparent bytes.Buffer
+	if err = png.Encode(&transparent, testImage(64, 64, color.NRGBA{A: 128})); err != nil {
+		t.Fatal(err)
+	}
+	variants, err = imaging.Process(bytes.NewReader(transparent.Bytes()), imaging.Options{})
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	if variants[1].Mime != "image/png" || variants[1].Width != 64 {
+		t.Errorf("transparent preview = %v %dx%d", variants[1].Mime, variants[1].Width, variants[1].Height)
+	}
+
+	if _, err = imaging.Process(bytes.NewReader(opaque.Bytes()), imaging.Options{MaxPixels: 1000}); !errors.Is(err, imaging.ErrImageTooLarge) {
+		t.Errorf("Process() error = %v, expected image too large", err)
+	}
+
+	if _, err = imaging.Process(bytes.NewReader([]byte("not an image")), imaging.Options{}); err == nil {
+		t.Errorf("Process() expected decode error")
+	}
+}
@@ -0,0 +1,147 @@
+package tests
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"dev.hackerman.me/artheon/veverse-shared/install"
+	"dev.hackerman.me/artheon/veverse-shared/model"
+)
+
+func TestVerifyInstall(t *testing.T) {
+	root := t.TempDir()
+
+	write := func(path string, content string) {
+		p := filepath.Join(root, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	file := func(path string, content string) model.File {
+		sum := sha256.Sum256([]byte(content))
+		hash := hex.EncodeToString(sum[:])
+		size := int64(len(content))
+		return model.File{Type: model.FileTypeRelease, OriginalPath: &path, Hash: &hash, Size: &size}
+	}
+
+	files := []model.File{
+		file("Game/Binaries/Win64/Game.exe", "binary"),
+		file("Game/Content/Paks/Game.pak", "pak content"),
+		file("Game/Content/Paks/Patch.pak", "patch"),
+		file("Game/Content/Movies/Intro.mp4", "movie"),
+	}
+
+	write("Game/Binaries/Win64/Game.exe", "binary")
+	write("Game/Content/Paks/Game.pak", "pak CONTENT") // same size, different hash
+	write("Game/Content/Movies/Intro.mp4", "movie, but longer")
+	write("Game/Saved/Config/Game.ini", "settings")
+	write("Game/Content/Paks/Old.pak", "old")
+
+	var (
+		calls    int
+		progress install.Progress
+	)
+
+	report, err := install.Verify(context.Background(), root, files, install.Options{
+		Workers: 2,
+		Ignore:  []string{"Game/Saved"},
+		OnProgress: func(p install.Progress) {
+			calls++
+			progress = p
+		},
+	})
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+
+	statuses := map[string]install.Status{}
+	for _, f := range report.Files {
+		statuses[f.Path] = f.Status
+	}
+
+	expected := map[string]install.Status{
+		"Game/Binaries/Win64/Game.exe":  install.StatusOk,
+		"Game/Content/Paks/Game.pak":    install.StatusCorrupted,
+		"Game/Content/Paks/Patch.pak":   install.StatusMissing,
+		"Game/Content/Movies/Intro.mp4": install.StatusCorrupted,
+		"Game/Content/Paks/Old.pak":     install.StatusExtra,
+	}
+
+	if len(statuses) != len(expected) {
+		t.Errorf("Verify() files = %v, expected %v", statuses, expected)
+	}
+
+	for p, s := range expected {
+		if statuses[p] != s {
+			t.Errorf("Verify() %v = %v, expected %v", p, statuses[p], s)
+		}
+	}
+
+	if report.Ok() {
+		t.Errorf("Ok() = true, expected false")
+	}
+
+	var download = map[string]bool{}
+	for _, f := range report.Repair.Download {
+		download[*f.OriginalPath] = true
+	}
+	if len(download) != 3 || !download["Game/Content/Paks/Game.pak"] || !download["Game/Content/Paks/Patch.pak"] || !download["Game/Content/Movies/Intro.mp4"] {
+		t.Errorf("Repair.Download = %v", download)
+	}
+
+	if report.Repair.DownloadSize != int64(len("pak content")+len("patch")+len("movie")) {
+		t.Errorf("Repair.DownloadSize = %v", report.Repair.DownloadSize)
+	}
+
+	if len(report.Repair.Extra) != 1 || report.Repair.Extra[0] != "Game/Content/Paks/Old.pak" {
+		t.Errorf("Repair.Extra = %v", report.Repair.Extra)
+	}
+
+	if calls == 0 || progress.Files != len(files) || progress.TotalFiles != len(files) {
+		t.Errorf("progress = %+v after %v calls", progress, calls)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = install.Verify(ctx, root, files, install.Options{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Verify() error = %v, expected context.Canceled", err)
+	}
+}
+
+func TestVerifyRelease(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "Game.exe"), []byte("binary"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	file := func(path string, fileType string, platform string, deployment string) model.File {
+		size := int64(len("binary"))
+		return model.File{Type: fileType, OriginalPath: &path, Size: &size, Platform: platform, Deployment: deployment}
+	}
+
+	// only release files of the platform and deployment are installed
+	release := &model.ReleaseV2{Entity: model.Entity{Files: &model.FileBatch{Entities: []model.File{
+		file("Game.exe", model.FileTypeRelease, "Win64", "Client"),
+		file("Game.app", model.FileTypeRelease, "Mac", "Client"),
+		file("GameServer.exe", model.FileTypeRelease, "Win64", "Server"),
+		file("Game.zip", model.FileTypeReleaseArchive, "Win64", "Client"),
+	}}}}
+
+	report, err := install.VerifyRelease(context.Background(), root, release, "Win64", "Client", install.Options{})
+	if err != nil {
+		t.Fatalf("VerifyRelease() error = %v", err)
+	}
+
+	if !report.Ok() || len(report.Files) != 1 || report.Files[0].Path != "Game.exe" {
+		t.Errorf("VerifyRelease() files = %+v, expected only Game.exe", report.Files)
+	}
+}
@@ -0,0 +1,56 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"dev.hackerman.me/artheon/veverse-shared/model"
+	"github.com/gofrs/uuid"
+)
+
+func TestRateEntity(t *testing.T) {
+	ctx, err := GetDatabaseContext(context.Background())
+	if err != nil {
+		t.Fatalf("failed to get database context: %v", err)
+	}
+
+	entityId := uuid.FromStringOrNil("21023FA4-B853-4E85-BE78-7C290C557AF8")
+	user := &model.User{Entity: model.Entity{Identifier: model.Identifier{Id: uuid.FromStringOrNil("1578BA66-3334-496E-8BB8-1A0696B42C68")}}}
+
+	initial, err := model.UnrateEntity(ctx, user, entityId)
+	if err != nil {
+		t.Fatalf("UnrateEntity() error = %v", err)
+	}
+
+	tests := []struct {
+		name            string
+		rate            func(context.Context, *model.User, uuid.UUID) (*model.Rating, error)
+		likes, dislikes int32
+		liked           *int32
+	}{
+		{"like", model.LikeEntity, 1, 0, ptr[int32](1)},
+		{"like again", model.LikeEntity, 1, 0, ptr[int32](1)},
+		{"dislike", model.DislikeEntity, 0, 1, ptr[int32](-1)},
+		{"unrate", model.UnrateEntity, 0, 0, nil},
+		{"unrate again", model.UnrateEntity, 0, 0, nil},
+	}
+
+	for _, tt := range tests {
+		rating, err := tt.rate(ctx, user, entityId)
+		if err != nil {
+			t.Fatalf("%s: error = %v", tt.name, err)
+		}
+
+		if rating.TotalLikes != initial.TotalLikes+tt.likes || rating.TotalDislikes != initial.TotalDislikes+tt.dislikes {
+			t.Errorf("%s: rating = %d/%d, expected %d/%d", tt.name, rating.TotalLikes, rating.TotalDislikes, initial.TotalLikes+tt.likes, initial.TotalDislikes+tt.dislikes)
+		}
+
+		if (rating.Liked == nil) != (tt.liked == nil) || rating.Liked != nil && *rating.Liked != *tt.liked {
+			t.Errorf("%s: liked = %v, expected %v", tt.name, rating.Liked, tt.liked)
+		}
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
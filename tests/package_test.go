@@ -0,0 +1,75 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"dev.hackerman.me/artheon/veverse-shared/model"
+	"github.com/gofrs/uuid"
+)
+
+func TestPackagePublishing(t *testing.T) {
+	ctx, err := GetDatabaseContext(context.Background())
+	if err != nil {
+		t.Fatalf("failed to get database context: %v", err)
+	}
+
+	owner := &model.User{Entity: model.Entity{Identifier: model.Identifier{Id: uuid.FromStringOrNil("1578BA66-3334-496E-8BB8-1A0696B42C68")}}}
+	stranger := &model.User{Entity: model.Entity{Identifier: model.Identifier{Id: uuid.Must(uuid.NewV4())}}}
+
+	name := "test-package-" + uuid.Must(uuid.NewV4()).String()
+	pkg, err := model.CreatePackage(ctx, owner, model.PackageCreateMetadata{Name: name})
+	if err != nil {
+		t.Fatalf("CreatePackage() error = %v", err)
+	}
+
+	request := model.PackageRequestMetadata{IdRequestMetadata: model.IdRequestMetadata{Id: pkg.Id}}
+	index := model.PackageBatchRequestMetadata{BatchRequestMetadata: model.BatchRequestMetadata{Query: name}}
+
+	// drafts are visible to the owner only
+	if _, err = model.GetPackage(ctx, stranger, request); !errors.Is(err, model.ErrNoPermission) {
+		t.Errorf("GetPackage() error = %v, expected no permission for a draft", err)
+	}
+
+	if batch, err := model.IndexPackages(ctx, stranger, index); err != nil || batch.Total != 0 {
+		t.Errorf("IndexPackages() = %v, error = %v, expected no drafts", batch, err)
+	}
+
+	if batch, err := model.IndexPackages(ctx, owner, index); err != nil || batch.Total != 1 {
+		t.Errorf("IndexPackages() = %v, error = %v, expected the owned draft", batch, err)
+	}
+
+	if _, err = model.PublishPackage(ctx, owner, pkg.Id); !errors.Is(err, model.ErrNoFile) {
+		t.Errorf("PublishPackage() error = %v, expected no pak file", err)
+	}
+
+	_, err = model.LinkFile(ctx, owner, pkg.Id, model.FileLinkRequestMetadata{Type: model.FileTypePak, Url: "https://veverse.com/test.pak", Platform: "Win64", Deployment: "Client"})
+	if err != nil {
+		t.Fatalf("LinkFile() error = %v", err)
+	}
+
+	if _, err = model.PublishPackage(ctx, owner, pkg.Id); err != nil {
+		t.Fatalf("PublishPackage() error = %v", err)
+	}
+
+	if batch, err := model.IndexPackages(ctx, stranger, index); err != nil || batch.Total != 1 {
+		t.Errorf("IndexPackages() = %v, error = %v, expected the published package", batch, err)
+	}
+
+	// each user is counted once, downloads reference existing users
+	for i := 0; i < 2; i++ {
+		if err = model.IncrementPackageDownloads(ctx, owner, pkg.Id); err != nil {
+			t.Fatalf("IncrementPackageDownloads() error = %v", err)
+		}
+	}
+
+	pkg, err = model.GetPackage(ctx, stranger, request)
+	if err != nil {
+		t.Fatalf("GetPackage() error = %v", err)
+	}
+
+	if pkg.Downloads == nil || *pkg.Downloads != 1 {
+		t.Errorf("downloads = %v, expected 1", pkg.Downloads)
+	}
+}
@@ -0,0 +1,115 @@
+package tests
+
+import (
+	"testing"
+
+	"dev.hackerman.me/artheon/veverse-shared/model"
+	"github.com/gofrs/uuid"
+)
+
+func testPackage(id uuid.UUID, version string, files []model.File, dependencies ...model.PackageDependency) *model.Package {
+	p := &model.Package{Version: version, Dependencies: dependencies}
+	p.Id = id
+	p.Files = &model.FileBatch{Entities: files}
+	return p
+}
+
+func testPak(platform, path string) model.File {
+	return model.File{Type: model.FileTypePak, Platform: platform, Deployment: "Client", Url: "https://example.com/" + path, OriginalPath: &path}
+}
+
+func TestResolvePaks(t *testing.T) {
+	var (
+		rootId   = uuid.FromStringOrNil("6F1A2B3C-4D5E-4F60-8172-93A4B5C6D701")
+		baseId   = uuid.FromStringOrNil("6F1A2B3C-4D5E-4F60-8172-93A4B5C6D702")
+		sharedId = uuid.FromStringOrNil("6F1A2B3C-4D5E-4F60-8172-93A4B5C6D703")
+	)
+
+	extraPath := "Extra/Readme.txt"
+	packages := map[uuid.UUID]*model.Package{
+		rootId: testPackage(rootId, "1.0.0", []model.File{
+			testPak("Win64", "Root-Win64.pak"),
+			testPak("Linux", "Root-Linux.pak"),
+			{Type: model.FileTypePakExtraContent, Url: "https://example.com/readme", OriginalPath: &extraPath},
+		}, model.PackageDependency{PackageId: baseId, Constraint: "^1.2"}, model.PackageDependency{PackageId: sharedId}),
+		baseId: testPackage(baseId, "1.3.0", []model.File{
+			testPak("Win64", "Base-Win64.pak"),
+			testPak("Linux", "Base-Linux.pak"),
+		}, model.PackageDependency{PackageId: sharedId}),
+		sharedId: testPackage(sharedId, "2.0.0", []model.File{
+			testPak("Win64", "Shared-Win64.pak"),
+		}),
+	}
+	packages[rootId].SdkConstraint = "^1.0"
+
+	r := model.ResolvePaks(rootId, packages, "1.4.0", nil, "Client")
+
+	expectedOrder := []uuid.UUID{sharedId, baseId, rootId}
+	if len(r.Packages) != len(expectedOrder) {
+		t.Fatalf("ResolvePaks() packages = %v, expected %v", r.Packages, expectedOrder)
+	}
+	for i, id := range expectedOrder {
+		if r.Packages[i] != id {
+			t.Errorf("ResolvePaks() package %d = %v, expected %v", i, r.Packages[i], id)
+		}
+	}
+
+	if len(r.Conflicts) != 0 {
+		t.Errorf("ResolvePaks() conflicts = %+v", r.Conflicts)
+	}
+
+	win := r.Platforms["Win64"]
+	expectedWin := []string{"Shared-Win64.pak", "Base-Win64.pak", "Root-Win64.pak", extraPath}
+	if len(win) != len(expectedWin) {
+		t.Fatalf("ResolvePaks() Win64 files = %d, expected %d", len(win), len(expectedWin))
+	}
+	for i, path := range expectedWin {
+		if *win[i].OriginalPath != path {
+			t.Errorf("ResolvePaks() Win64 file %d = %v, expected %v", i, *win[i].OriginalPath, path)
+		}
+	}
+
+	if len(r.Missing) != 1 || r.Missing[0].PackageId != sharedId || r.Missing[0].Platform != "Linux" {
+		t.Errorf("ResolvePaks() missing = %+v, expected shared package on Linux", r.Missing)
+	}
+	if r.Ok() {
+		t.Errorf("ResolvePaks() is ok with missing builds")
+	}
+
+	// unsatisfied dependency and sdk constraints
+	r = model.ResolvePaks(rootId, packages, "2.0.0", []string{"Win64"}, "Client")
+	if len(r.Conflicts) != 1 || r.Conflicts[0].Kind != model.PakConflictSdk || r.Conflicts[0].PackageId != rootId {
+		t.Errorf("ResolvePaks() conflicts = %+v, expected sdk conflict", r.Conflicts)
+	}
+	if len(r.Missing) != 0 || r.Ok() {
+		t.Errorf("ResolvePaks() missing = %+v", r.Missing)
+	}
+
+	packages[baseId].Version = "2.0.0"
+	r = model.ResolvePaks(rootId, packages, "", []string{"Win64"}, "Client")
+	if len(r.Conflicts) != 1 || r.Conflicts[0].Kind != model.PakConflictVersion || *r.Conflicts[0].DependencyId != baseId {
+		t.Errorf("ResolvePaks() conflicts = %+v, expected version conflict", r.Conflicts)
+	}
+	packages[baseId].Version = "1.3.0"
+
+	// the same path mounted by two packages
+	packages[sharedId].Files.Entities = append(packages[sharedId].Files.Entities, testPak("Win64", "root-win64.PAK"))
+	r = model.ResolvePaks(rootId, packages, "", []string{"Win64"}, "Client")
+	if len(r.Conflicts) != 1 || r.Conflicts[0].Kind != model.PakConflictFile || r.Conflicts[0].PackageId != rootId || *r.Conflicts[0].DependencyId != sharedId {
+		t.Errorf("ResolvePaks() conflicts = %+v, expected file conflict", r.Conflicts)
+	}
+	packages[sharedId].Files.Entities = packages[sharedId].Files.Entities[:1]
+
+	// cycles and missing packages
+	missingId := uuid.FromStringOrNil("6F1A2B3C-4D5E-4F60-8172-93A4B5C6D704")
+	packages[sharedId].Dependencies = []model.PackageDependency{{PackageId: rootId}, {PackageId: missingId}}
+	r = model.ResolvePaks(rootId, packages, "", []string{"Win64"}, "Client")
+
+	kinds := map[model.PakConflictKind]uuid.UUID{}
+	for _, c := range r.Conflicts {
+		kinds[c.Kind] = c.PackageId
+	}
+	if len(r.Conflicts) != 2 || kinds[model.PakConflictCycle] != rootId || kinds[model.PakConflictMissingPackage] != missingId {
+		t.Errorf("ResolvePaks() conflicts = %+v, expected cycle and missing package", r.Conflicts)
+	}
+}
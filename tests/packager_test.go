@@ -0,0 +1,179 @@
+package tests
+
+import (
+	"crypto/sha256"
+	"debug/pe"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"dev.hackerman.me/artheon/veverse-shared/archive"
+	"dev.hackerman.me/artheon/veverse-shared/helper"
+	"dev.hackerman.me/artheon/veverse-shared/model"
+	"dev.hackerman.me/artheon/veverse-shared/packager"
+	"dev.hackerman.me/artheon/veverse-shared/unreal"
+)
+
+func writeTestBuild(t *testing.T, root string, files map[string]string) {
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestPackagerBuild(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "LinuxServer")
+	writeTestBuild(t, dir, map[string]string{
+		"MetaverseServer.sh":                               "#!/bin/sh\n",
+		"Metaverse/Binaries/Linux/MetaverseServer":         "\x7fELF server",
+		"Metaverse/Binaries/Linux/MetaverseServer.debug":   "debug symbols",
+		"Metaverse/Content/Paks/Metaverse-LinuxServer.pak": "pak",
+		"Metaverse/Saved/Logs/Metaverse.log":               "log",
+		"Manifest_NonUFSFiles_LinuxServer.txt":             "manifest",
+		"Metaverse/Config/DefaultGame.ini":                 "[Game]\n",
+		helper.IgnoreFileName:                              "Saved/\n",
+	})
+
+	release, err := packager.Build(dir, packager.Options{})
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	if release.Platform != unreal.Linux || release.Deployment != packager.DeploymentServer {
+		t.Errorf("platform = %v, deployment = %v", release.Platform, release.Deployment)
+	}
+
+	var paths []string
+	for _, f := range release.Files {
+		paths = append(paths, f.OriginalPath)
+	}
+	expected := []string{
+		"Metaverse/Binaries/Linux/MetaverseServer",
+		"Metaverse/Config/DefaultGame.ini",
+		"Metaverse/Content/Paks/Metaverse-LinuxServer.pak",
+		"MetaverseServer.sh",
+	}
+	if strings.Join(paths, ",") != strings.Join(expected, ",") {
+		t.Fatalf("files = %v, expected %v", paths, expected)
+	}
+
+	if strings.Join(release.Executables, ",") != "Metaverse/Binaries/Linux/MetaverseServer,MetaverseServer.sh" {
+		t.Errorf("executables = %v", release.Executables)
+	}
+
+	pak := release.Files[2]
+	sum := sha256.Sum256([]byte("pak"))
+	if pak.Hash != hex.EncodeToString(sum[:]) || pak.Size != 3 || pak.Type != model.FileTypeRelease {
+		t.Errorf("pak file = %+v", pak)
+	}
+
+	metadata := pak.UploadMetadata()
+	if metadata.OriginalPath != pak.OriginalPath || metadata.Platform != unreal.Linux || metadata.Deployment != packager.DeploymentServer || *metadata.Hash != pak.Hash || *metadata.Size != 3 {
+		t.Errorf("upload metadata = %+v", metadata)
+	}
+
+	link := pak.LinkMetadata("https://example.com/pak")
+	if link.Url != "https://example.com/pak" || link.OriginalPath != pak.OriginalPath || *link.Mime != pak.Mime {
+		t.Errorf("link metadata = %+v", link)
+	}
+
+	if release.Files[0].Mime != model.DefaultFileMime || release.Files[1].Mime != "text/plain" || release.Files[3].Mime != "application/x-sh" {
+		t.Errorf("mime types = %v, %v, %v", release.Files[0].Mime, release.Files[1].Mime, release.Files[3].Mime)
+	}
+
+	// options override detected values and default rules
+	release, err = packager.Build(dir, packager.Options{Platform: unreal.Windows, Deployment: packager.DeploymentClient, Ignore: []string{"!*.debug"}})
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if release.Platform != unreal.Windows || release.Deployment != packager.DeploymentClient || len(release.Files) != 5 {
+		t.Errorf("release = %+v", release)
+	}
+}
+
+func TestPackagerBuildArchive(t *testing.T) {
+	base := t.TempDir()
+	dir := filepath.Join(base, "WindowsNoEditor")
+	writeTestBuild(t, dir, map[string]string{
+		"Metaverse.exe":                              "MZ launcher",
+		"Metaverse/Binaries/Win64/Metaverse.exe":     "MZ game",
+		"Metaverse/Content/Paks/Metaverse-Win64.pak": "pak",
+	})
+
+	output := filepath.Join(base, "release.tar.zst")
+	release, err := packager.Build(dir, packager.Options{Archive: true, ArchivePath: output})
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	if !release.Archive || len(release.Files) != 1 || release.Platform != unreal.Windows || release.Deployment != packager.DeploymentClient {
+		t.Fatalf("release = %+v", release)
+	}
+
+	f := release.Files[0]
+	if f.Path != output || f.OriginalPath != "release.tar.zst" || f.Type != model.FileTypeReleaseArchive {
+		t.Errorf("archive file = %+v", f)
+	}
+
+	file, err := os.Open(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	reader, err := archive.NewReader(file, archive.FormatTarZst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	count := 0
+	for {
+		if _, err = reader.Next(); err != nil {
+			break
+		}
+		count++
+	}
+	if count != 3 {
+		t.Errorf("archive entries = %v, expected 3", count)
+	}
+}
+
+func TestPackagerCaseConflict(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "Linux")
+	writeTestBuild(t, dir, map[string]string{
+		"Metaverse/Content/Game.pak": "pak",
+		"Metaverse/content/game.pak": "pak",
+	})
+
+	if _, err := packager.Build(dir, packager.Options{}); err == nil {
+		t.Errorf("Build() expected case conflict error")
+	}
+}
+
+func TestPackagerPlatformMismatch(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "LinuxServer")
+	writeTestBuild(t, dir, map[string]string{
+		"Metaverse/Binaries/Win64/MetaverseServer.exe": string(testPE(pe.IMAGE_FILE_MACHINE_AMD64, pe.IMAGE_FILE_EXECUTABLE_IMAGE)),
+	})
+
+	if _, err := packager.Build(dir, packager.Options{}); !errors.Is(err, packager.ErrPlatformMismatch) {
+		t.Errorf("Build() error = %v, expected platform mismatch", err)
+	}
+
+	if _, err := packager.Build(dir, packager.Options{AllowForeignBinaries: true}); err != nil {
+		t.Errorf("Build() error = %v", err)
+	}
+
+	if _, err := packager.Build(dir, packager.Options{Platform: unreal.Windows}); err != nil {
+		t.Errorf("Build() error = %v", err)
+	}
+}
@@ -0,0 +1,33 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"dev.hackerman.me/artheon/veverse-shared/model"
+)
+
+func TestEstimatePixelStreamingWait(t *testing.T) {
+	tests := []struct {
+		name           string
+		position       int64
+		instances      int64
+		averageSession time.Duration
+		expected       time.Duration
+	}{
+		{"not waiting", 0, 4, 5 * time.Minute, 0},
+		{"first in queue", 1, 4, 5 * time.Minute, 5 * time.Minute},
+		{"last of the first round", 4, 4, 5 * time.Minute, 5 * time.Minute},
+		{"second round", 5, 4, 5 * time.Minute, 10 * time.Minute},
+		{"no instances counted as one", 3, 0, 5 * time.Minute, 15 * time.Minute},
+		{"no session history uses default", 1, 1, 0, 10 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := model.EstimatePixelStreamingWait(tt.position, tt.instances, tt.averageSession); got != tt.expected {
+				t.Errorf("EstimatePixelStreamingWait() = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
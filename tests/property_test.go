@@ -0,0 +1,145 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"dev.hackerman.me/artheon/veverse-shared/model"
+)
+
+func TestParsePropertyValue(t *testing.T) {
+	tests := []struct {
+		propertyType string
+		value        string
+		expected     any
+		canonical    string
+	}{
+		{model.PropertyTypeInt, " 42 ", int64(42), "42"},
+		{model.PropertyTypeFloat, "1.50", 1.5, "1.5"},
+		{model.PropertyTypeBool, "TRUE", true, "true"},
+		{model.PropertyTypeString, " text ", " text ", " text "},
+		{model.PropertyTypeVector, "X=1.0 Y=-2 Z=3.5", model.Vector{X: 1, Y: -2, Z: 3.5}, "X=1 Y=-2 Z=3.5"},
+		{model.PropertyTypeVector, "1, -2, 3.5", model.Vector{X: 1, Y: -2, Z: 3.5}, "X=1 Y=-2 Z=3.5"},
+		{model.PropertyTypeColor, "#ff8000", model.Color{R: 255, G: 128, A: 255}, "#FF8000FF"},
+		{model.PropertyTypeColor, "ff800080", model.Color{R: 255, G: 128, A: 128}, "#FF800080"},
+	}
+	for _, tt := range tests {
+		typed, canonical, err := model.ParsePropertyValue(tt.propertyType, tt.value)
+		if err != nil || typed != tt.expected || canonical != tt.canonical {
+			t.Errorf("ParsePropertyValue(%s, %q) = %v, %q, %v, expected %v, %q", tt.propertyType, tt.value, typed, canonical, err, tt.expected, tt.canonical)
+		}
+	}
+
+	typed, _, err := model.ParsePropertyValue(model.PropertyTypeJson, `{"a": [1, 2]}`)
+	if raw, ok := typed.(json.RawMessage); err != nil || !ok || string(raw) != `{"a": [1, 2]}` {
+		t.Errorf("ParsePropertyValue(json) = %v, %v", typed, err)
+	}
+
+	invalid := []struct{ propertyType, value string }{
+		{model.PropertyTypeInt, "1.5"},
+		{model.PropertyTypeFloat, "NaN"},
+		{model.PropertyTypeBool, "yes"},
+		{model.PropertyTypeJson, "{"},
+		{model.PropertyTypeVector, "X=1 Y=2"},
+		{model.PropertyTypeVector, "Y=1 X=2 Z=3"},
+		{model.PropertyTypeColor, "#fff"},
+		{"date", "2023-03-31"},
+	}
+	for _, tt := range invalid {
+		if _, _, err = model.ParsePropertyValue(tt.propertyType, tt.value); !errors.Is(err, model.ErrInvalidProperty) {
+			t.Errorf("ParsePropertyValue(%s, %q) error = %v, expected invalid property", tt.propertyType, tt.value, err)
+		}
+	}
+}
+
+func TestPropertySchema(t *testing.T) {
+	min, max, maxLength, pattern := 1.0, 10.0, 5, "^[a-z]+$"
+
+	count := model.PropertySchema{EntityType: "space", Name: "count", Type: model.PropertyTypeInt, Constraints: &model.PropertyConstraints{Min: &min, Max: &max}}
+	for value, valid := range map[string]bool{"1": true, "10": true, "0": false, "11": false} {
+		if _, _, err := count.Parse(value); (err == nil) != valid {
+			t.Errorf("count.Parse(%q) error = %v", value, err)
+		}
+	}
+
+	tag := model.PropertySchema{EntityType: "space", Name: "tag", Type: model.PropertyTypeString, Constraints: &model.PropertyConstraints{MaxLength: &maxLength, Pattern: &pattern}}
+	for value, valid := range map[string]bool{"abc": true, "abcdef": false, "ABC": false} {
+		if _, _, err := tag.Parse(value); (err == nil) != valid {
+			t.Errorf("tag.Parse(%q) error = %v", value, err)
+		}
+	}
+
+	mode := model.PropertySchema{EntityType: "space", Name: "mode", Type: model.PropertyTypeFloat, Constraints: &model.PropertyConstraints{Enum: []string{"0.5", "1"}}}
+	for value, valid := range map[string]bool{"0.50": true, "1.0": true, "2": false} {
+		if _, _, err := mode.Parse(value); (err == nil) != valid {
+			t.Errorf("mode.Parse(%q) error = %v", value, err)
+		}
+	}
+
+	invalidDefault := "0"
+	count.DefaultValue = &invalidDefault
+	if err := count.Validate(); !errors.Is(err, model.ErrInvalidProperty) {
+		t.Errorf("Validate() error = %v, expected invalid default value", err)
+	}
+
+	invalidPattern := "["
+	tag.Constraints.Pattern = &invalidPattern
+	if err := tag.Validate(); !errors.Is(err, model.ErrInvalidProperty) {
+		t.Errorf("Validate() error = %v, expected invalid pattern", err)
+	}
+
+	required := model.PropertySchema{EntityType: "space", Name: "capacity", Type: model.PropertyTypeInt, Required: true}
+	if err := required.Validate(); !errors.Is(err, model.ErrRequiredProperty) {
+		t.Errorf("Validate() error = %v, expected required property without default", err)
+	}
+
+	capacity := "16"
+	required.DefaultValue = &capacity
+	if err := required.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	if err := mode.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+}
+
+func TestValidateProperties(t *testing.T) {
+	schemas := []model.PropertySchema{
+		{EntityType: "space", Name: "spawn", Type: model.PropertyTypeVector},
+		{EntityType: "space", Name: "tint", Type: model.PropertyTypeColor},
+	}
+
+	typed, err := model.ValidateProperties(schemas, []model.InsertProperty{
+		{Name: "spawn", Value: "0,0,100"},
+		{Name: "note", Value: "hello"},
+		{Type: model.PropertyTypeBool, Name: "private", Value: "1"},
+	})
+	if err != nil {
+		t.Fatalf("ValidateProperties() error = %v", err)
+	}
+
+	expected := []struct{ propertyType, value string }{
+		{model.PropertyTypeVector, "X=0 Y=0 Z=100"},
+		{model.PropertyTypeString, "hello"},
+		{model.PropertyTypeBool, "true"},
+	}
+	for i, e := range expected {
+		if typed[i].Type != e.propertyType || typed[i].Value != e.value {
+			t.Errorf("property %d = %s %q, expected %s %q", i, typed[i].Type, typed[i].Value, e.propertyType, e.value)
+		}
+	}
+
+	invalid := [][]model.InsertProperty{
+		{{Name: "tint", Value: "red"}},
+		{{Type: model.PropertyTypeString, Name: "tint", Value: "#ffffff"}},
+		{{Name: "note", Value: "a"}, {Name: "note", Value: "b"}},
+		{{Value: "unnamed"}},
+	}
+	for _, properties := range invalid {
+		if _, err = model.ValidateProperties(schemas, properties); !errors.Is(err, model.ErrInvalidProperty) {
+			t.Errorf("ValidateProperties(%v) error = %v, expected invalid property", properties, err)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package tests
+
+import (
+	"testing"
+
+	"dev.hackerman.me/artheon/veverse-shared/model"
+	"github.com/gofrs/uuid"
+)
+
+func TestPickRegion(t *testing.T) {
+	var (
+		euId = uuid.FromStringOrNil("5B7E1F5C-3C4B-4D51-9F0A-6C1B2C3D4E01")
+		usId = uuid.FromStringOrNil("5B7E1F5C-3C4B-4D51-9F0A-6C1B2C3D4E02")
+		asId = uuid.FromStringOrNil("5B7E1F5C-3C4B-4D51-9F0A-6C1B2C3D4E03")
+
+		euLat, euLon = 50.11, 8.68
+		usLat, usLon = 39.04, -77.49
+		asLat, asLon = 35.68, 139.69
+	)
+
+	regions := []model.Region{
+		{Identifier: model.Identifier{Id: euId}, Name: "eu", Latitude: &euLat, Longitude: &euLon, Enabled: true},
+		{Identifier: model.Identifier{Id: usId}, Name: "us", Latitude: &usLat, Longitude: &usLon, Enabled: true},
+		{Identifier: model.Identifier{Id: asId}, Name: "as", Latitude: &asLat, Longitude: &asLon, Enabled: false},
+	}
+
+	newYork := "40.71,-74.00"
+	tokyo := "35.68, 139.69"
+	invalid := "somewhere"
+
+	tests := []struct {
+		name        string
+		pings       []model.RegionPing
+		geoLocation *string
+		expected    uuid.UUID
+	}{
+		{"lowest ping", []model.RegionPing{{RegionId: euId, Latency: 120}, {RegionId: usId, Latency: 40}}, nil, usId},
+		{"ping to disabled region is ignored", []model.RegionPing{{RegionId: asId, Latency: 5}, {RegionId: euId, Latency: 90}}, nil, euId},
+		{"invalid pings fall back to geo location", []model.RegionPing{{RegionId: euId, Latency: 0}}, &newYork, usId},
+		{"nearest enabled region", nil, &tokyo, euId},
+		{"invalid geo location falls back to first enabled", nil, &invalid, euId},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			region := model.PickRegion(regions, tt.pings, tt.geoLocation)
+			if region == nil {
+				t.Fatalf("PickRegion() returned nil")
+			}
+			if region.Id != tt.expected {
+				t.Errorf("PickRegion() = %v, expected %v", region.Name, tt.expected)
+			}
+		})
+	}
+
+	if region := model.PickRegion(regions[2:], nil, nil); region != nil {
+		t.Errorf("PickRegion() = %v, expected nil for disabled regions", region.Name)
+	}
+}
@@ -0,0 +1,111 @@
+package tests
+
+import (
+	"strconv"
+	"testing"
+
+	"dev.hackerman.me/artheon/veverse-shared/model"
+	"github.com/gofrs/uuid"
+)
+
+func TestIsInReleaseRollout(t *testing.T) {
+	releaseId := uuid.FromStringOrNil("0D4C6A2E-8F3B-4C1D-9E5A-7B6C5D4E3F01")
+
+	var (
+		users   = 10000
+		rolled  = map[int]int{}
+		percent = []int{0, 10, 50, 100}
+	)
+
+	for i := 0; i < users; i++ {
+		userId := uuid.NewV5(uuid.NamespaceOID, "user"+strconv.Itoa(i))
+
+		bucket := model.ReleaseRolloutBucket(userId, releaseId)
+		if bucket < 0 || bucket > 99 {
+			t.Fatalf("ReleaseRolloutBucket() = %v, expected 0-99", bucket)
+		}
+		if bucket != model.ReleaseRolloutBucket(userId, releaseId) {
+			t.Fatalf("ReleaseRolloutBucket() is not deterministic")
+		}
+
+		previous := false
+		for _, p := range percent {
+			in := model.IsInReleaseRollout(userId, releaseId, p)
+			if previous && !in {
+				t.Fatalf("user left the rollout when the percentage increased to %v", p)
+			}
+			if in {
+				rolled[p]++
+			}
+			previous = in
+		}
+	}
+
+	if rolled[0] != 0 {
+		t.Errorf("0%% rollout reached %v users", rolled[0])
+	}
+	if rolled[100] != users {
+		t.Errorf("100%% rollout reached %v of %v users", rolled[100], users)
+	}
+	if rolled[10] < users*8/100 || rolled[10] > users*12/100 {
+		t.Errorf("10%% rollout reached %v of %v users", rolled[10], users)
+	}
+	if rolled[50] < users*46/100 || rolled[50] > users*54/100 {
+		t.Errorf("50%% rollout reached %v of %v users", rolled[50], users)
+	}
+}
+
+func TestPickReleaseChannelRelease(t *testing.T) {
+	var (
+		stableId = uuid.FromStringOrNil("0D4C6A2E-8F3B-4C1D-9E5A-7B6C5D4E3F11")
+		stagedId = uuid.FromStringOrNil("0D4C6A2E-8F3B-4C1D-9E5A-7B6C5D4E3F12")
+		brokenId = uuid.FromStringOrNil("0D4C6A2E-8F3B-4C1D-9E5A-7B6C5D4E3F13")
+	)
+
+	releases := []model.ReleaseChannelRelease{
+		{ReleaseId: stableId, Version: "1.9.0", RolloutPercentage: 100},
+		{ReleaseId: stagedId, Version: "1.10.0", RolloutPercentage: 10},
+		{ReleaseId: brokenId, Version: "latest", RolloutPercentage: 100},
+	}
+
+	// find users inside and outside the staged rollout
+	var inside, outside *uuid.UUID
+	for i := 0; i < 1000 && (inside == nil || outside == nil); i++ {
+		userId := uuid.NewV5(uuid.NamespaceOID, "player"+strconv.Itoa(i))
+		if model.IsInReleaseRollout(userId, stagedId, 10) {
+			inside = &userId
+		} else {
+			outside = &userId
+		}
+	}
+
+	if inside == nil || outside == nil {
+		t.Fatalf("failed to find users inside and outside the rollout")
+	}
+
+	tests := []struct {
+		name     string
+		userId   *uuid.UUID
+		expected uuid.UUID
+	}{
+		{"user in the staged rollout", inside, stagedId},
+		{"user outside the staged rollout", outside, stableId},
+		{"anonymous user", nil, stableId},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := model.PickReleaseChannelRelease(releases, tt.userId)
+			if r == nil {
+				t.Fatalf("PickReleaseChannelRelease() = nil, expected %v", tt.expected)
+			}
+			if r.ReleaseId != tt.expected {
+				t.Errorf("PickReleaseChannelRelease() = %v, expected %v", r.ReleaseId, tt.expected)
+			}
+		})
+	}
+
+	if r := model.PickReleaseChannelRelease(releases[1:2], outside); r != nil {
+		t.Errorf("PickReleaseChannelRelease() = %v, expected nil", r.ReleaseId)
+	}
+}
@@ -0,0 +1,154 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand"
+	"testing"
+
+	"dev.hackerman.me/artheon/veverse-shared/chunk"
+	"dev.hackerman.me/artheon/veverse-shared/model"
+)
+
+func TestDiffReleaseManifest(t *testing.T) {
+	file := func(fileType, path, hash string, size int64) model.File {
+		return model.File{Type: fileType, OriginalPath: &path, Hash: &hash, Size: &size, Url: "https://cdn/" + hash}
+	}
+
+	installed := []model.File{
+		file(model.FileTypeRelease, "Game/Content/Paks/Game.pak", "a1", 1000),
+		file(model.FileTypeReleaseChunkIndex, "Game/Content/Paks/Game.pak", "a1i", 10),
+		file(model.FileTypeRelease, "Game/Binaries/Win64/Game.exe", "b1", 100),
+		file(model.FileTypeRelease, "Game/Content/Movies/Intro.mp4", "c1", 50),
+	}
+
+	target := []model.File{
+		file(model.FileTypeRelease, "Game\\Content\\Paks\\Game.pak", "a2", 1200),
+		file(model.FileTypeReleaseChunkIndex, "Game/Content/Paks/Game.pak", "a2i", 12),
+		file(model.FileTypeRelease, "Game/Binaries/Win64/Game.exe", "B1", 100),
+		file(model.FileTypeRelease, "Game/Content/Paks/Patch.pak", "d1", 300),
+	}
+
+	diff := model.DiffReleaseManifest(installed, target)
+
+	if len(diff.Added) != 1 || *diff.Added[0].OriginalPath != "Game/Content/Paks/Patch.pak" {
+		t.Errorf("Added = %v, expected Patch.pak", diff.Added)
+	}
+
+	if len(diff.Changed) != 1 {
+		t.Fatalf("Changed = %v, expected Game.pak", diff.Changed)
+	}
+
+	change := diff.Changed[0]
+	if *change.File.Hash != "a2" || *change.Previous.Hash != "a1" {
+		t.Errorf("Changed = %v -> %v, expected a1 -> a2", *change.Previous.Hash, *change.File.Hash)
+	}
+	if change.ChunkIndex == nil || *change.ChunkIndex.Hash != "a2i" || change.PreviousChunkIndex == nil || *change.PreviousChunkIndex.Hash != "a1i" {
+		t.Errorf("chunk indexes are not attached to the changed file")
+	}
+
+	if len(diff.Removed) != 1 || *diff.Removed[0].OriginalPath != "Game/Content/Movies/Intro.mp4" {
+		t.Errorf("Removed = %v, expected Intro.mp4", diff.Removed)
+	}
+
+	if diff.Unchanged != 1 {
+		t.Errorf("Unchanged = %v, expected 1", diff.Unchanged)
+	}
+
+	if diff.DownloadSize != 1500 {
+		t.Errorf("DownloadSize = %v, expected 1500", diff.DownloadSize)
+	}
+}
+
+func TestChunkPatch(t *testing.T) {
+	options := chunk.Options{MinSize: 2 * 1024, AvgSize: 8 * 1024, MaxSize: 32 * 1024}
+
+	random := rand.New(rand.NewSource(1))
+	original := make([]byte, 1024*1024)
+	random.Read(original)
+
+	// insert data in the middle and modify the tail of the file
+	insert := make([]byte, 5000)
+	random.Read(insert)
+	modified := append(append(append([]byte{}, original[:400000]...), insert...), original[400000:]...)
+	copy(modified[len(modified)-100:], bytes.Repeat([]byte{0xFF}, 100))
+
+	local, err := chunk.Split(bytes.NewReader(original), options)
+	if err != nil {
+		t.Fatalf("Split() error = %v", err)
+	}
+
+	target, err := chunk.Split(bytes.NewReader(modified), options)
+	if err != nil {
+		t.Fatalf("Split() error = %v", err)
+	}
+
+	if target.Size != int64(len(modified)) {
+		t.Fatalf("Split() size = %v, expected %v", target.Size, len(modified))
+	}
+
+	// chunk boundaries must not depend on the reader buffering
+	again, err := chunk.Split(io.MultiReader(bytes.NewReader(modified[:1000]), bytes.NewReader(modified[1000:])), options)
+	if err != nil || again.Hash != target.Hash || len(again.Chunks) != len(target.Chunks) {
+		t.Fatalf("Split() is not deterministic")
+	}
+
+	var encoded bytes.Buffer
+	if err = target.Write(&encoded); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if target, err = chunk.ReadIndex(&encoded); err != nil {
+		t.Fatalf("ReadIndex() error = %v", err)
+	}
+
+	plan := chunk.Diff(local, target)
+	if plan.DownloadSize == 0 || plan.DownloadSize > int64(len(modified))/10 {
+		t.Errorf("Diff() download size = %v of %v", plan.DownloadSize, len(modified))
+	}
+	if plan.DownloadSize+plan.ReuseSize < target.Size {
+		t.Errorf("Diff() does not cover the target file")
+	}
+
+	chunks := map[string][]byte{}
+	for _, c := range target.Chunks {
+		chunks[c.Hash] = modified[c.Offset : c.Offset+c.Size]
+	}
+
+	var downloaded int64
+	fetch := func(ctx context.Context, c chunk.Chunk) (io.ReadCloser, error) {
+		downloaded += c.Size
+		return io.NopCloser(bytes.NewReader(chunks[c.Hash])), nil
+	}
+
+	var out bytes.Buffer
+	if err = chunk.Apply(context.Background(), plan, bytes.NewReader(original), fetch, &out); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	if !bytes.Equal(out.Bytes(), modified) {
+		t.Errorf("Apply() result does not match the target file")
+	}
+
+	if downloaded != plan.DownloadSize {
+		t.Errorf("Apply() downloaded %v, expected %v", downloaded, plan.DownloadSize)
+	}
+}
+
+func TestChunkOptions(t *testing.T) {
+	invalid := []chunk.Options{
+		{MinSize: 1, AvgSize: 1, MaxSize: 1},
+		{MinSize: 1, AvgSize: 3, MaxSize: 4},
+		{MinSize: 4, AvgSize: 2, MaxSize: 8},
+		{MinSize: 0, AvgSize: 2, MaxSize: 4},
+	}
+	for _, o := range invalid {
+		if _, err := chunk.Split(bytes.NewReader([]byte("data")), o); err == nil {
+			t.Errorf("Split() with %+v expected error", o)
+		}
+	}
+
+	if _, err := chunk.Split(bytes.NewReader([]byte("data")), chunk.Options{MinSize: 1, AvgSize: 2, MaxSize: 4}); err != nil {
+		t.Errorf("Split() error = %v", err)
+	}
+}
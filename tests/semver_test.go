@@ -0,0 +1,150 @@
+package tests
+
+import (
+	"testing"
+
+	"dev.hackerman.me/artheon/veverse-shared/model"
+	"dev.hackerman.me/artheon/veverse-shared/semver"
+)
+
+func TestSemverParse(t *testing.T) {
+	tests := []struct {
+		version  string
+		valid    bool
+		expected string
+	}{
+		{"1.2.3", true, "1.2.3"},
+		{"v1.2.3", true, "1.2.3"},
+		{"1.0.0-beta.2+build.15", true, "1.0.0-beta.2+build.15"},
+		{"0.0.0", true, "0.0.0"},
+		{"1.2", false, ""},
+		{"01.2.3", false, ""},
+		{"1.2.3-01", false, ""},
+		{"1.2.3-", false, ""},
+		{"1.2.3-beta..1", false, ""},
+		{"latest", false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			v, err := semver.Parse(tt.version)
+			if (err == nil) != tt.valid {
+				t.Fatalf("Parse() error = %v, expected valid = %v", err, tt.valid)
+			}
+			if tt.valid && v.String() != tt.expected {
+				t.Errorf("Parse() = %v, expected %v", v.String(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestSemverCompare(t *testing.T) {
+	// sorted by precedence as in the semver specification
+	ordered := []string{
+		"1.0.0-alpha",
+		"1.0.0-alpha.1",
+		"1.0.0-alpha.beta",
+		"1.0.0-beta",
+		"1.0.0-beta.2",
+		"1.0.0-beta.11",
+		"1.0.0-rc.1",
+		"1.0.0",
+		"1.9.0",
+		"1.10.0",
+		"2.0.0",
+	}
+
+	for i := 0; i < len(ordered)-1; i++ {
+		c, err := semver.Compare(ordered[i], ordered[i+1])
+		if err != nil {
+			t.Fatalf("Compare() error = %v", err)
+		}
+		if c != -1 {
+			t.Errorf("Compare(%v, %v) = %v, expected -1", ordered[i], ordered[i+1], c)
+		}
+	}
+
+	if c, _ := semver.Compare("1.0.0+build.1", "1.0.0+build.2"); c != 0 {
+		t.Errorf("build metadata must not affect precedence")
+	}
+
+	if i := semver.Max([]string{"1.9.0", "invalid", "1.10.0", "1.2.0"}); i != 2 {
+		t.Errorf("Max() = %v, expected 2", i)
+	}
+}
+
+func TestSemverConstraint(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    string
+		expected   bool
+	}{
+		{"^1.2", "1.2.0", true},
+		{"^1.2", "1.9.3", true},
+		{"^1.2", "2.0.0", false},
+		{"^1.2", "1.1.9", false},
+		{"^1.2", "1.3.0-beta", false},
+		{"^0.2.3", "0.2.9", true},
+		{"^0.2.3", "0.3.0", false},
+		{"^0.0.3", "0.0.4", false},
+		{"~1.2.3", "1.2.9", true},
+		{"~1.2.3", "1.3.0", false},
+		{"~1", "1.9.0", true},
+		{">=1.0.0 <2", "1.5.0", true},
+		{">=1.0.0 <2", "2.0.0-alpha", false},
+		{">=1.3.0-alpha", "1.3.0-beta", true},
+		{"1.2.x", "1.2.7", true},
+		{"1.2.x", "1.3.0", false},
+		{"1.x || 3.x", "3.1.0", true},
+		{"1.x || 3.x", "2.1.0", false},
+		{"*", "4.5.6", true},
+		{">1.2", "1.2.9", false},
+		{">1.2", "1.3.0", true},
+		{"<=1.2", "1.2.9", true},
+		{"=1.2.3", "1.2.3", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.constraint+" "+tt.version, func(t *testing.T) {
+			ok, err := semver.Satisfies(tt.version, tt.constraint)
+			if err != nil {
+				t.Fatalf("Satisfies() error = %v", err)
+			}
+			if ok != tt.expected {
+				t.Errorf("Satisfies() = %v, expected %v", ok, tt.expected)
+			}
+		})
+	}
+
+	if _, err := semver.ParseConstraint("^1.2.a"); err == nil {
+		t.Errorf("ParseConstraint() expected error for invalid version")
+	}
+}
+
+func TestIsReleaseV2CodeCompatible(t *testing.T) {
+	release := &model.ReleaseV2{CodeVersion: "1.4.0"}
+
+	tests := []struct {
+		name       string
+		client     string
+		constraint string
+		expected   bool
+	}{
+		{"same major", "1.6.2", "", true},
+		{"older minor", "1.3.0", "", false},
+		{"next major", "2.0.0", "", false},
+		{"explicit constraint", "1.3.0", "^1.2", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := model.IsReleaseV2CodeCompatible(tt.client, release, tt.constraint)
+			if err != nil {
+				t.Fatalf("IsReleaseV2CodeCompatible() error = %v", err)
+			}
+			if ok != tt.expected {
+				t.Errorf("IsReleaseV2CodeCompatible() = %v, expected %v", ok, tt.expected)
+			}
+		})
+	}
+}
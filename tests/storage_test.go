@@ -0,0 +1,154 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"dev.hackerman.me/artheon/veverse-shared/model"
+	"dev.hackerman.me/artheon/veverse-shared/storage"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// testS3Server is an in-memory bucket supporting single part uploads
+func testS3Server(t *testing.T) *httptest.Server {
+	var (
+		mu      sync.Mutex
+		objects = map[string][]byte{}
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		data, ok := objects[r.URL.Path]
+		switch r.Method {
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			objects[r.URL.Path] = body
+			w.Header().Set("ETag", `"etag"`)
+		case http.MethodHead, http.MethodGet:
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				if r.Method == http.MethodGet {
+					_, _ = io.WriteString(w, `<Error><Code>NoSuchKey</Code></Error>`)
+				}
+				return
+			}
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+			w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+			if r.Method == http.MethodGet {
+				_, _ = w.Write(data)
+			}
+		case http.MethodDelete:
+			delete(objects, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestStorage(t *testing.T) {
+	fs, err := storage.NewFileSystem(t.TempDir(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := testS3Server(t)
+	sess := session.Must(session.NewSession(&aws.Config{
+		Endpoint:         aws.String(server.URL),
+		Region:           aws.String("us-east-1"),
+		Credentials:      credentials.NewStaticCredentials("key", "secret", ""),
+		S3ForcePathStyle: aws.Bool(true),
+	}))
+	bucket, err := storage.NewS3(s3.New(sess), storage.S3Options{Bucket: "test", Prefix: "storage/", TempDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, s := range map[string]storage.Storage{"filesystem": fs, "s3": bucket} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			content := []byte("release file content")
+			sum := sha256.Sum256(content)
+			hash := hex.EncodeToString(sum[:])
+
+			// plain readers are spooled, seekable readers are hashed in place
+			object, err := s.Put(ctx, io.LimitReader(bytes.NewReader(content), int64(len(content))), storage.PutOptions{})
+			if err != nil {
+				t.Fatalf("Put() error = %v", err)
+			}
+			if object.Hash != hash || object.Size != int64(len(content)) {
+				t.Errorf("Put() = %+v", object)
+			}
+
+			if _, err = s.Put(ctx, bytes.NewReader(content), storage.PutOptions{Hash: strings.ToUpper(hash)}); err != nil {
+				t.Errorf("Put() of stored content error = %v", err)
+			}
+
+			other := sha256.Sum256([]byte("other"))
+			_, err = s.Put(ctx, bytes.NewReader([]byte("tampered")), storage.PutOptions{Hash: hex.EncodeToString(other[:])})
+			if !errors.Is(err, storage.ErrHashMismatch) {
+				t.Errorf("Put() error = %v, expected hash mismatch", err)
+			}
+
+			reader, err := storage.GetFile(ctx, s, model.File{Hash: &hash})
+			if err != nil {
+				t.Fatalf("GetFile() error = %v", err)
+			}
+			data, _ := io.ReadAll(reader)
+			_ = reader.Close()
+			if !bytes.Equal(data, content) {
+				t.Errorf("GetFile() content = %q", data)
+			}
+
+			path, err := storage.Path(hash)
+			if err != nil {
+				t.Fatalf("Path() error = %v", err)
+			}
+
+			url, err := s.URL(ctx, hash, time.Hour)
+			if err != nil || !strings.Contains(url, path) {
+				t.Errorf("URL() = %v, error = %v", url, err)
+			}
+
+			if err = s.Delete(ctx, hash); err != nil {
+				t.Fatalf("Delete() error = %v", err)
+			}
+			if _, err = s.Stat(ctx, hash); !errors.Is(err, storage.ErrNotFound) {
+				t.Errorf("Stat() error = %v, expected not found", err)
+			}
+			if _, err = s.Get(ctx, hash); !errors.Is(err, storage.ErrNotFound) {
+				t.Errorf("Get() error = %v, expected not found", err)
+			}
+			if err = s.Delete(ctx, hash); err != nil {
+				t.Errorf("Delete() of missing content error = %v", err)
+			}
+
+			if _, err = s.Stat(ctx, "../../etc/passwd"); !errors.Is(err, storage.ErrInvalidHash) {
+				t.Errorf("Stat() error = %v, expected invalid hash", err)
+			}
+		})
+	}
+
+	for _, hash := range []string{"", "a", "zz"} {
+		if _, err := storage.Path(hash); !errors.Is(err, storage.ErrInvalidHash) {
+			t.Errorf("Path(%q) error = %v, expected invalid hash", hash, err)
+		}
+	}
+}
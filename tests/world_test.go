@@ -0,0 +1,102 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	glContext "dev.hackerman.me/artheon/veverse-shared/context"
+	"dev.hackerman.me/artheon/veverse-shared/model"
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func TestWorldAppPublishing(t *testing.T) {
+	ctx, err := GetDatabaseContext(context.Background())
+	if err != nil {
+		t.Fatalf("failed to get database context: %v", err)
+	}
+
+	owner := &model.User{Entity: model.Entity{Identifier: model.Identifier{Id: uuid.FromStringOrNil("1578BA66-3334-496E-8BB8-1A0696B42C68")}}}
+	appOwner := &model.User{Entity: model.Entity{Identifier: model.Identifier{Id: uuid.Must(uuid.NewV4())}}}
+	stranger := &model.User{Entity: model.Entity{Identifier: model.Identifier{Id: uuid.Must(uuid.NewV4())}}}
+
+	name := "test-world-" + uuid.Must(uuid.NewV4()).String()
+	pkg, err := model.CreatePackage(ctx, owner, model.PackageCreateMetadata{Name: name})
+	if err != nil {
+		t.Fatalf("CreatePackage() error = %v", err)
+	}
+
+	world, err := model.CreateWorld(ctx, owner, model.CreateWorldRequest{Name: name, Map: "TestMap", PackageId: pkg.Id})
+	if err != nil {
+		t.Fatalf("CreateWorld() error = %v", err)
+	}
+
+	private, err := model.CreateWorld(ctx, owner, model.CreateWorldRequest{Name: name + "-private", Map: "TestMap", PackageId: pkg.Id, Public: ptr(false)})
+	if err != nil {
+		t.Fatalf("CreateWorld() error = %v", err)
+	}
+
+	if _, err = model.UpdateWorld(ctx, stranger, world.Id, model.UpdateWorldRequest{Name: ptr("renamed")}); !errors.Is(err, model.ErrNoPermission) {
+		t.Errorf("UpdateWorld() error = %v, expected no permission for a stranger", err)
+	}
+
+	if err = model.DeleteWorld(ctx, stranger, world.Id); !errors.Is(err, model.ErrNoPermission) {
+		t.Errorf("DeleteWorld() error = %v, expected no permission for a stranger", err)
+	}
+
+	app, err := model.CreateAppV2(ctx, owner, model.CreateAppV2Request{Name: name})
+	if err != nil {
+		t.Fatalf("CreateAppV2() error = %v", err)
+	}
+
+	// apps are created without an owner
+	db := ctx.Value(glContext.Database).(*pgxpool.Pool)
+	_, err = db.Exec(ctx, `insert into accessibles (entity_id, user_id, is_owner, can_view, can_edit, can_delete) values ($1, $2, true, true, true, true)`, app.Id, appOwner.Id)
+	if err != nil {
+		t.Fatalf("failed to set app owner: %v", err)
+	}
+
+	if err = model.PublishWorldToApp(ctx, stranger, app.Id, world.Id); !errors.Is(err, model.ErrNoPermission) {
+		t.Errorf("PublishWorldToApp() error = %v, expected no permission for a stranger", err)
+	}
+
+	for _, w := range []*model.World{world, private} {
+		if err = model.PublishWorldToApp(ctx, owner, app.Id, w.Id); err != nil {
+			t.Fatalf("PublishWorldToApp() error = %v", err)
+		}
+	}
+
+	index := model.BatchRequestMetadata{Query: name}
+
+	if batch, err := model.IndexAppWorlds(ctx, owner, app.Id, index); err != nil || batch.Total != 2 {
+		t.Errorf("IndexAppWorlds() = %v, error = %v, expected both worlds for the owner", batch, err)
+	}
+
+	// private worlds are hidden from strangers
+	batch, err := model.IndexAppWorlds(ctx, stranger, app.Id, index)
+	if err != nil || batch.Total != 1 {
+		t.Fatalf("IndexAppWorlds() = %v, error = %v, expected the public world", batch, err)
+	}
+
+	if batch.Entities[0].Id != world.Id {
+		t.Errorf("IndexAppWorlds() world = %v, expected %v", batch.Entities[0].Id, world.Id)
+	}
+
+	if err = model.UnpublishWorldFromApp(ctx, stranger, app.Id, world.Id); !errors.Is(err, model.ErrNoPermission) {
+		t.Errorf("UnpublishWorldFromApp() error = %v, expected no permission for a stranger", err)
+	}
+
+	// the app owner can remove worlds of other users
+	if err = model.UnpublishWorldFromApp(ctx, appOwner, app.Id, world.Id); err != nil {
+		t.Fatalf("UnpublishWorldFromApp() error = %v", err)
+	}
+
+	if batch, err := model.IndexAppWorlds(ctx, stranger, app.Id, index); err != nil || batch.Total != 0 {
+		t.Errorf("IndexAppWorlds() = %v, error = %v, expected no public worlds", batch, err)
+	}
+
+	if err = model.DeleteWorld(ctx, owner, private.Id); err != nil {
+		t.Errorf("DeleteWorld() error = %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package tests
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"dev.hackerman.me/artheon/veverse-shared/model"
+	"github.com/gofrs/uuid"
+)
+
+func TestDiscoverWorlds(t *testing.T) {
+	ctx, err := GetDatabaseContext(context.Background())
+	if err != nil {
+		t.Fatalf("failed to get database context: %v", err)
+	}
+
+	owner := &model.User{Entity: model.Entity{Identifier: model.Identifier{Id: uuid.FromStringOrNil("1578BA66-3334-496E-8BB8-1A0696B42C68")}}}
+
+	// unique word matched by the full-text query
+	token := "discovery" + strings.ReplaceAll(uuid.Must(uuid.NewV4()).String(), "-", "")
+
+	packages := map[string]uuid.UUID{}
+	for _, platform := range []string{"Win64", "Mac"} {
+		pkg, err := model.CreatePackage(ctx, owner, model.PackageCreateMetadata{Name: token + "-" + platform})
+		if err != nil {
+			t.Fatalf("CreatePackage() error = %v", err)
+		}
+
+		_, err = model.LinkFile(ctx, owner, pkg.Id, model.FileLinkRequestMetadata{Type: model.FileTypePak, Url: "https://veverse.com/" + token + ".pak", Platform: platform, Deployment: "Client"})
+		if err != nil {
+			t.Fatalf("LinkFile() error = %v", err)
+		}
+
+		packages[platform] = pkg.Id
+	}
+
+	worlds := []struct {
+		gameMode string
+		platform string
+	}{
+		{"race", "Win64"},
+		{"race", "Mac"},
+		{"arena", "Win64"},
+	}
+
+	var expected uuid.UUID
+	for i, w := range worlds {
+		world, err := model.CreateWorld(ctx, owner, model.CreateWorldRequest{Name: "Test " + token, Map: "TestMap", PackageId: packages[w.platform], GameMode: ptr(w.gameMode)})
+		if err != nil {
+			t.Fatalf("CreateWorld() error = %v", err)
+		}
+
+		if i == 0 {
+			expected = world.Id
+		}
+	}
+
+	request := model.DiscoverWorldsRequest{
+		BatchRequestMetadata: model.BatchRequestMetadata{Query: token},
+		GameModes:            []string{"race"},
+		Platform:             "Win64",
+		Deployment:           "Client",
+	}
+
+	batch, err := model.DiscoverWorlds(ctx, owner, request)
+	if err != nil {
+		t.Fatalf("DiscoverWorlds() error = %v", err)
+	}
+
+	if batch.Total != 1 || len(batch.Entities) != 1 || batch.Entities[0].Id != expected {
+		t.Fatalf("DiscoverWorlds() = %v, expected the race world on Win64", batch.Entities)
+	}
+
+	// each facet is counted without its own filter
+	facets := []struct {
+		name     string
+		values   []model.WorldFacetValue
+		expected map[string]uint64
+	}{
+		{"game mode", batch.Facets.GameModes, map[string]uint64{"race": 1, "arena": 1}},
+		{"platform", batch.Facets.Platforms, map[string]uint64{"Win64": 1, "Mac": 1}},
+		{"type", batch.Facets.Types, map[string]uint64{"world": 1}},
+	}
+
+	for _, f := range facets {
+		counts := map[string]uint64{}
+		for _, v := range f.values {
+			counts[v.Value] = v.Count
+		}
+
+		if len(counts) != len(f.expected) {
+			t.Errorf("%s facet = %v, expected %v", f.name, counts, f.expected)
+			continue
+		}
+
+		for value, count := range f.expected {
+			if counts[value] != count {
+				t.Errorf("%s facet = %v, expected %v", f.name, counts, f.expected)
+				break
+			}
+		}
+	}
+}
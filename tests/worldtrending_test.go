@@ -0,0 +1,50 @@
+package tests
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"dev.hackerman.me/artheon/veverse-shared/model"
+)
+
+func TestTrendingScore(t *testing.T) {
+	options := model.TrendingOptions{HalfLife: 24 * time.Hour, RefreshInterval: time.Hour, VisitWeight: ptr(1.0), LikeWeight: ptr(5.0), DislikeWeight: ptr(5.0), PlayerHourWeight: ptr(2.0)}
+
+	tests := []struct {
+		name     string
+		previous float64
+		elapsed  time.Duration
+		activity model.WorldActivity
+		expected float64
+	}{
+		{"no activity decays by half life", 100, 24 * time.Hour, model.WorldActivity{}, 50},
+		{"two half lives", 100, 48 * time.Hour, model.WorldActivity{}, 25},
+		{"new activity", 0, time.Hour, model.WorldActivity{Visits: 3, Likes: 2, Dislikes: 1}, 3 + 10 - 5},
+		{"concurrent players count per hour", 0, 30 * time.Minute, model.WorldActivity{Players: 4}, 4},
+		{"concurrent players count up to the refresh interval", 0, 7 * 24 * time.Hour, model.WorldActivity{Players: 10}, 20},
+		{"dislikes do not make the score negative", 1, 0, model.WorldActivity{Dislikes: 10}, 0},
+		{"negative elapsed time does not grow the score", 10, -time.Hour, model.WorldActivity{}, 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if score := model.TrendingScore(tt.previous, tt.elapsed, tt.activity, options); math.Abs(score-tt.expected) > 1e-9 {
+				t.Errorf("TrendingScore() = %v, expected %v", score, tt.expected)
+			}
+		})
+	}
+
+	// zero weights ignore the activity instead of using the default
+	options.DislikeWeight = ptr(0.0)
+	if score := model.TrendingScore(10, 0, model.WorldActivity{Dislikes: 10}, options); math.Abs(score-10) > 1e-9 {
+		t.Errorf("TrendingScore() with zero dislike weight = %v, expected 10", score)
+	}
+
+	// refreshing twice with no activity decays the same as refreshing once
+	once := model.TrendingScore(100, 12*time.Hour, model.WorldActivity{}, model.TrendingOptions{})
+	twice := model.TrendingScore(model.TrendingScore(100, 6*time.Hour, model.WorldActivity{}, model.TrendingOptions{}), 6*time.Hour, model.WorldActivity{}, model.TrendingOptions{})
+	if math.Abs(once-twice) > 1e-9 {
+		t.Errorf("TrendingScore() is not incremental: %v != %v", once, twice)
+	}
+}